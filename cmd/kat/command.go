@@ -1,20 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v2"
 
 	"github.com/BolajiOlajide/kat/internal/config"
 	"github.com/BolajiOlajide/kat/internal/database"
-	"github.com/BolajiOlajide/kat/internal/loggr"
 	"github.com/BolajiOlajide/kat/internal/migration"
 	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/types"
 	updatepkg "github.com/BolajiOlajide/kat/internal/update"
 	"github.com/BolajiOlajide/kat/internal/version"
 )
@@ -28,7 +31,33 @@ func addExec(c *cli.Context) error {
 		return cli.Exit("too many arguments", 1)
 	}
 
-	return migration.Add(c, args[0])
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return err
+	}
+
+	m, err := migration.Add(c, args[0])
+	if err != nil {
+		return err
+	}
+
+	result := addResult{
+		Name:     m.Name,
+		Up:       m.Up,
+		Down:     m.Down,
+		Metadata: m.Metadata,
+	}
+	return render(c, result, func() error {
+		fmt.Printf("%sMigration created successfully!%s\n", output.StyleSuccess, output.StyleReset)
+		if cfg.Verbose && m.Up == m.Metadata {
+			fmt.Printf("%sMigration file: %s%s\n", output.StyleInfo, m.Up, output.StyleReset)
+		} else if cfg.Verbose {
+			fmt.Printf("%sUp query file: %s%s\n", output.StyleInfo, m.Up, output.StyleReset)
+			fmt.Printf("%sDown query file: %s%s\n", output.StyleInfo, m.Down, output.StyleReset)
+			fmt.Printf("%sMetadata file: %s%s\n", output.StyleInfo, m.Metadata, output.StyleReset)
+		}
+		return nil
+	})
 }
 
 func upExec(c *cli.Context) error {
@@ -40,12 +69,17 @@ func upExec(c *cli.Context) error {
 	// Get command flags
 	dryRun := c.Bool("dry-run")
 
-	if dryRun {
+	if dryRun && !isJSON(c) {
 		fmt.Printf("%sDRY RUN: Migrations will not be applied%s\n", output.StyleInfo, output.StyleReset)
 	}
 
 	// Note: Retry is not used for migrations, only for the ping command
-	return migration.Up(c, cfg, dryRun)
+	results, err := migration.Up(c, cfg, dryRun)
+	result := newMigrationRunResult(types.UpMigrationOperation, dryRun, results)
+	if err != nil {
+		return renderFailure(c, result, migrationRunError(results, err))
+	}
+	return render(c, result, noText)
 }
 
 func downExec(c *cli.Context) error {
@@ -57,27 +91,357 @@ func downExec(c *cli.Context) error {
 	// Get command flags
 	dryRun := c.Bool("dry-run")
 
-	if dryRun {
+	if dryRun && !isJSON(c) {
 		fmt.Printf("%sDRY RUN: Migrations will not be rolled back%s\n", output.StyleInfo, output.StyleReset)
 	}
 
 	// Note: Retry is not used for migrations, only for the ping command
-	return migration.Down(c, cfg, dryRun)
+	results, err := migration.Down(c, cfg, dryRun)
+	result := newMigrationRunResult(types.DownMigrationOperation, dryRun, results)
+	if err != nil {
+		return renderFailure(c, result, migrationRunError(results, err))
+	}
+	return render(c, result, noText)
+}
+
+func statusExec(c *cli.Context) error {
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migration.Status(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	return render(c, newStatusResult(statuses), func() error {
+		return migration.PrintStatus(os.Stdout, statuses)
+	})
+}
+
+func validateExec(c *cli.Context) error {
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return err
+	}
+
+	problems, count, err := migration.Validate(cfg)
+	if err != nil {
+		return err
+	}
+
+	result := newValidateResult(count, problems)
+	if len(problems) > 0 {
+		err := &codedError{code: errCodeValidationFailed, err: errors.Newf("found %d problem(s) in %d migration(s)", len(problems), count)}
+		if isJSON(c) {
+			return renderFailure(c, result, err)
+		}
+		for _, p := range problems {
+			if p.Migration == "" {
+				fmt.Printf("%s%s%s\n", output.StyleFailure, p.Message, output.StyleReset)
+				continue
+			}
+			fmt.Printf("%s%s: %s%s\n", output.StyleFailure, p.Migration, p.Message, output.StyleReset)
+		}
+		return err
+	}
+
+	return render(c, result, func() error {
+		fmt.Printf("%s%d migration(s) are valid.%s\n", output.StyleSuccess, count, output.StyleReset)
+		return nil
+	})
+}
+
+func headsExec(c *cli.Context) error {
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return err
+	}
+
+	heads, err := migration.Heads(cfg)
+	if err != nil {
+		return err
+	}
+
+	return render(c, newHeadsResult(heads), func() error {
+		return migration.PrintHeads(os.Stdout, heads)
+	})
+}
+
+func mergeExec(c *cli.Context) error {
+	args := c.Args().Slice()
+	if len(args) < 2 {
+		return cli.Exit("at least two heads must be specified", 1)
+	}
+
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return err
+	}
+
+	timestamps, err := parseTimestamps(args)
+	if err != nil {
+		return err
+	}
+
+	m, err := migration.Merge(c, timestamps)
+	if err != nil {
+		return err
+	}
+
+	result := addResult{
+		Name:     m.Name,
+		Up:       m.Up,
+		Down:     m.Down,
+		Metadata: m.Metadata,
+	}
+	return render(c, result, func() error {
+		fmt.Printf("%sMerge migration %s created successfully!%s\n", output.StyleSuccess, result.Name, output.StyleReset)
+		if cfg.Verbose {
+			fmt.Printf("%sMetadata file: %s%s\n", output.StyleInfo, m.Metadata, output.StyleReset)
+		}
+		return nil
+	})
+}
+
+func rebaseExec(c *cli.Context) error {
+	args := c.Args().Slice()
+	if len(args) == 0 {
+		return cli.Exit("no migrations specified", 1)
+	}
+
+	timestamps, err := parseTimestamps(args)
+	if err != nil {
+		return err
+	}
+
+	rebased, err := migration.Rebase(c, timestamps)
+	if err != nil {
+		return err
+	}
+
+	return render(c, newRebaseResult(rebased), func() error {
+		for _, m := range rebased {
+			fmt.Printf("%sRebased %s onto %s%s\n", output.StyleSuccess, m.From.FileName(), formatParents(m.To.Parents), output.StyleReset)
+			if m.To.Timestamp != m.From.Timestamp {
+				fmt.Printf("%s  renamed to %s%s\n", output.StyleInfo, m.Path, output.StyleReset)
+			}
+		}
+		return nil
+	})
+}
+
+func importExec(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.Exit("specify the directory of the migrations to import", 1)
+	}
+
+	imported, err := migration.Import(c, c.String("from"), c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return render(c, newImportResult(imported), func() error {
+		var applied int
+		for _, m := range imported {
+			note := ""
+			if m.Applied {
+				note = " (applied)"
+				applied++
+			}
+			fmt.Printf("%sImported %s as %s%s%s\n", output.StyleSuccess, strings.Join(m.Sources, ", "), m.FileName(), note, output.StyleReset)
+		}
+		fmt.Printf("%sImported %d migration(s)", output.StyleInfo, len(imported))
+		if c.Bool("copy-history") {
+			fmt.Printf(", %d recorded as applied", applied)
+		}
+		fmt.Printf(".%s\n", output.StyleReset)
+		return nil
+	})
+}
+
+func configShowExec(c *cli.Context) error {
+	resolved, err := config.Load(c)
+	if err != nil {
+		return err
+	}
+	resolved.WriteWarnings(os.Stderr)
+	settings, err := resolved.Settings()
+	if err != nil {
+		return err
+	}
+
+	return render(c, newConfigShowResult(resolved, settings), func() error {
+		if resolved.Path == "" {
+			fmt.Println("# configured from the environment")
+		} else {
+			fmt.Printf("# %s\n", resolved.Path)
+		}
+		return resolved.WriteAnnotated(os.Stdout)
+	})
+}
+
+func configValidateExec(c *cli.Context) error {
+	if err := config.LoadEnvFile(c); err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path, err := config.FindConfigFile(c, wd)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return config.ErrConfigNotFound
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	problems, err := config.ValidateSchema(content, os.LookupEnv)
+	if err != nil {
+		return errors.Wrapf(err, "parsing %s", path)
+	}
+	// Settings can match the schema and still be unusable, such as an invalid duration or
+	// a password file that can't be read.
+	if len(problems) == 0 {
+		resolved, err := config.Load(c)
+		if err == nil {
+			_, err = resolved.Config.Database.ParseDBTimeouts()
+		}
+		if err != nil {
+			problems = append(problems, config.SchemaProblem{Message: err.Error()})
+		}
+	}
+
+	result := newConfigValidateResult(path, problems)
+	if len(problems) > 0 {
+		err := &codedError{code: errCodeValidationFailed, err: errors.Newf("found %d problem(s) in %s", len(problems), path)}
+		if isJSON(c) {
+			return renderFailure(c, result, err)
+		}
+		for _, p := range problems {
+			location := path
+			if p.Line > 0 {
+				location = fmt.Sprintf("%s:%d", path, p.Line)
+			}
+			if p.Key != "" {
+				location += ": " + p.Key
+			}
+			fmt.Printf("%s%s: %s%s\n", output.StyleFailure, location, p.Message, output.StyleReset)
+		}
+		return err
+	}
+
+	return render(c, result, func() error {
+		fmt.Printf("%s%s is valid.%s\n", output.StyleSuccess, path, output.StyleReset)
+		return nil
+	})
+}
+
+func configPathExec(c *cli.Context) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path, err := config.FindConfigFile(c, wd)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return config.ErrConfigNotFound
+	}
+
+	return render(c, configPathResult{Path: path}, func() error {
+		fmt.Println(path)
+		return nil
+	})
+}
+
+func doctorExec(c *cli.Context) error {
+	checks := migration.Doctor(c)
+
+	var failed int
+	for _, check := range checks {
+		if check.Status == migration.CheckFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err := &codedError{code: errCodeChecksFailed, err: errors.Newf("%d check(s) failed", failed)}
+		if isJSON(c) {
+			return renderFailure(c, newDoctorResult(checks), err)
+		}
+		if perr := migration.PrintDoctor(os.Stdout, checks); perr != nil {
+			return errors.CombineErrors(err, perr)
+		}
+		return err
+	}
+
+	return render(c, newDoctorResult(checks), func() error {
+		return migration.PrintDoctor(os.Stdout, checks)
+	})
+}
+
+// parseTimestamps parses migration timestamps given as command arguments.
+func parseTimestamps(args []string) ([]int64, error) {
+	timestamps := make([]int64, 0, len(args))
+	for _, arg := range args {
+		ts, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, cli.Exit(fmt.Sprintf("invalid migration timestamp %q", arg), 1)
+		}
+		timestamps = append(timestamps, ts)
+	}
+	return timestamps, nil
+}
+
+func formatParents(parents []int64) string {
+	if len(parents) == 0 {
+		return "no parents"
+	}
+	parts := make([]string, len(parents))
+	for i, p := range parents {
+		parts[i] = strconv.FormatInt(p, 10)
+	}
+	return strings.Join(parts, ", ")
 }
 
 func initialize(c *cli.Context) error {
-	return migration.Init(c)
+	configFile, err := migration.Init(c)
+	if err != nil {
+		return err
+	}
+
+	return render(c, initResult{ConfigFile: configFile}, func() error {
+		fmt.Printf("%sKat initialized successfully!%s\n", output.StyleSuccess, output.StyleReset)
+		fmt.Printf("%sConfig file: %s%s\n", output.StyleInfo, configFile, output.StyleReset)
+		return nil
+	})
 }
 
 func getVersion(c *cli.Context) error {
-	fmt.Printf("%sVersion: %s%s\n", output.StyleInfo, version.Version(), output.StyleReset)
-	return nil
+	return render(c, versionResult{Version: version.Version()}, func() error {
+		fmt.Printf("%sVersion: %s%s\n", output.StyleInfo, version.Version(), output.StyleReset)
+		return nil
+	})
 }
 
+// noText is the text renderer for commands whose human-readable output is logged while
+// they run.
+func noText() error { return nil }
+
 func updateExec(c *cli.Context) error {
+	current := version.Version()
 	if version.IsDev() {
-		fmt.Printf("%sYou are running kat in dev mode. The update command is not available in dev mode.%s\n", output.StyleInfo, output.StyleReset)
-		return nil
+		return render(c, updateResult{CurrentVersion: current}, func() error {
+			fmt.Printf("%sYou are running kat in dev mode. The update command is not available in dev mode.%s\n", output.StyleInfo, output.StyleReset)
+			return nil
+		})
 	}
 
 	// Check if a newer version is available
@@ -88,13 +452,25 @@ func updateExec(c *cli.Context) error {
 
 	// No update available
 	if !hasUpdate {
-		fmt.Printf("%sKat is already at the latest version.%s\n", output.StyleSuccess, output.StyleReset)
-		return nil
+		return render(c, updateResult{CurrentVersion: current, LatestVersion: latestVersion}, func() error {
+			fmt.Printf("%sKat is already at the latest version.%s\n", output.StyleSuccess, output.StyleReset)
+			return nil
+		})
+	}
+
+	// There is no terminal to prompt on in JSON mode, so the update must be confirmed up front.
+	if isJSON(c) && !c.Bool("yes") {
+		return &codedError{
+			code: errCodeConfirmationRequired,
+			err:  errors.Newf("a new version of kat is available (%s); pass --yes to install it", latestVersion),
+		}
 	}
 
 	// Update available - notify the user
-	fmt.Printf("%sA new version of Kat is available: %s%s\n",
-		output.StyleInfo, latestVersion, output.StyleReset)
+	if !isJSON(c) {
+		fmt.Printf("%sA new version of Kat is available: %s%s\n",
+			output.StyleInfo, latestVersion, output.StyleReset)
+	}
 
 	// Get the path to the current executable
 	execPath, err := os.Executable()
@@ -122,15 +498,22 @@ func updateExec(c *cli.Context) error {
 		}
 	}
 
-	// Download and install the update
-	err = updatepkg.DownloadAndReplace(downloadURL, execPath, os.Stdout)
+	// Download and install the update. Progress goes to stderr in JSON mode so stdout only
+	// carries the result document.
+	var progress io.Writer = os.Stdout
+	if isJSON(c) {
+		progress = os.Stderr
+	}
+	err = updatepkg.DownloadAndReplace(downloadURL, execPath, progress)
 	if err != nil {
 		return errors.Wrap(err, "failed to update")
 	}
 
-	fmt.Printf("%sKat has been updated to version %s%s\n",
-		output.StyleSuccess, latestVersion, output.StyleReset)
-	return nil
+	return render(c, updateResult{CurrentVersion: current, LatestVersion: latestVersion, Updated: true}, func() error {
+		fmt.Printf("%sKat has been updated to version %s%s\n",
+			output.StyleSuccess, latestVersion, output.StyleReset)
+		return nil
+	})
 }
 
 func ping(c *cli.Context) error {
@@ -150,7 +533,7 @@ func ping(c *cli.Context) error {
 	retryCount := c.Int("retry-count")
 	retryDelay := c.Int("retry-delay")
 
-	logger := loggr.NewDefault()
+	logger := migration.LoggerFromCtx(c)
 
 	// Build database config from config file
 	dbConfig, err := migration.DBConfigFromCfg(cfg)
@@ -166,23 +549,36 @@ func ping(c *cli.Context) error {
 	defer db.Close()
 
 	// Use PingWithRetry with the provided parameters
-	fmt.Fprintf(os.Stdout, "%sAttempting to ping database%s\n", output.StyleInfo, output.StyleReset)
-	if retryCount > 0 {
-		fmt.Fprintf(os.Stdout, "%sUsing retry count: %d, initial delay: %dms%s\n",
-			output.StyleInfo, retryCount, retryDelay, output.StyleReset)
+	if !isJSON(c) {
+		fmt.Fprintf(os.Stdout, "%sAttempting to ping database%s\n", output.StyleInfo, output.StyleReset)
+		if retryCount > 0 {
+			fmt.Fprintf(os.Stdout, "%sUsing retry count: %d, initial delay: %dms%s\n",
+				output.StyleInfo, retryCount, retryDelay, output.StyleReset)
+		}
 	}
 
+	start := time.Now()
 	err = db.PingWithRetry(c.Context, retryCount, retryDelay)
+	result := pingResult{
+		Driver:       cfg.Database.Driver.String(),
+		LatencyMS:    milliseconds(time.Since(start)),
+		RetryCount:   retryCount,
+		RetryDelayMS: retryDelay,
+	}
 
 	if err != nil {
-		fmt.Fprintf(os.Stdout, "%sFailed to connect to database: %s%s\n",
-			output.StyleFailure, err.Error(), output.StyleReset)
-		return err
+		if !isJSON(c) {
+			fmt.Fprintf(os.Stdout, "%sFailed to connect to database: %s%s\n",
+				output.StyleFailure, err.Error(), output.StyleReset)
+		}
+		return renderFailure(c, result, &codedError{code: errCodeConnectionFailed, err: err})
 	}
 
-	fmt.Fprintf(os.Stdout, "%sSuccessfully connected to database!%s\n",
-		output.StyleSuccess, output.StyleReset)
-	return nil
+	return render(c, result, func() error {
+		fmt.Fprintf(os.Stdout, "%sSuccessfully connected to database!%s\n",
+			output.StyleSuccess, output.StyleReset)
+		return nil
+	})
 }
 
 func exportExec(c *cli.Context) error {
@@ -196,6 +592,9 @@ func exportExec(c *cli.Context) error {
 
 	// Get format parameter
 	file := c.String("file")
+	if isJSON(c) {
+		return exportJSON(c, cfg, file)
+	}
 	if file == "" {
 		wrt = os.Stdout
 	} else {
@@ -210,3 +609,28 @@ func exportExec(c *cli.Context) error {
 	// Export the graph
 	return migration.ExportGraph(wrt, cfg)
 }
+
+// exportJSON writes the migration graph as a JSON document. The DOT graph is embedded in
+// the document unless it was written to file.
+func exportJSON(c *cli.Context, cfg types.Config, file string) error {
+	defs, err := migration.ListDefinitions(cfg)
+	if err != nil {
+		return err
+	}
+
+	var graph bytes.Buffer
+	if err := migration.ExportGraph(&graph, cfg); err != nil {
+		return err
+	}
+
+	result := exportResult{Migrations: newDefinitionsJSON(defs)}
+	if file == "" {
+		result.Graph = graph.String()
+	} else {
+		if err := os.WriteFile(file, graph.Bytes(), migration.FilePerm); err != nil {
+			return err
+		}
+		result.File = file
+	}
+	return render(c, result, noText)
+}
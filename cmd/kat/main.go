@@ -31,14 +31,36 @@ var supportedDrivers = []string{
 	dbdriver.SqliteDriver.String(),
 }
 
-var verbose bool
-
 var descriptionFlag = &cli.StringFlag{
 	Name:    "description",
 	Usage:   "-d <description of the migration>",
 	Aliases: []string{"d"},
 }
 
+var irreversibleFlag = &cli.BoolFlag{
+	Name:  "irreversible",
+	Usage: "mark the migration as irreversible so it can't be rolled back without --force",
+	Value: false,
+}
+
+var namespaceFlag = &cli.StringFlag{
+	Name:  "namespace",
+	Usage: "namespace of the migrations directory to add the migration to, when migration.directories is configured (default: the first one)",
+}
+
+var forceFlag = &cli.BoolFlag{
+	Name:    "force",
+	Usage:   "roll back migrations even if they are marked as irreversible",
+	EnvVars: []string{"KAT_FORCE"},
+	Value:   false,
+}
+
+var metricsFileFlag = &cli.PathFlag{
+	Name:    "metrics-file",
+	Usage:   "write Prometheus metrics for the run to this file in the node exporter textfile format",
+	EnvVars: []string{"KAT_METRICS_FILE"},
+}
+
 var configFlag = &cli.PathFlag{
 	Name:    "config",
 	Usage:   "the configuration file for kat",
@@ -78,12 +100,17 @@ var kat = &cli.App{
 	Compiled:    time.Now(),
 	Flags: []cli.Flag{
 		&cli.BoolFlag{
-			Name:        "verbose",
-			Usage:       "toggle verbose mode (default: false)",
-			Aliases:     []string{"v"},
-			EnvVars:     []string{"KAT_VERBOSE"},
-			Value:       false,
-			Destination: &verbose,
+			Name:    "verbose",
+			Usage:   "toggle verbose mode (default: false)",
+			Aliases: []string{"v"},
+			EnvVars: []string{"KAT_VERBOSE"},
+			Value:   false,
+		},
+		outputFlag,
+		&cli.PathFlag{
+			Name:    "env-file",
+			Usage:   "load environment variables from this file (default: .env in the current directory, if it exists)",
+			EnvVars: []string{"KAT_ENV_FILE"},
 		},
 	},
 	Commands: []*cli.Command{
@@ -217,12 +244,36 @@ var kat = &cli.App{
 			Description: "Creates a new migration file in the migrations directory",
 			Action:      addExec,
 			Before:      config.ParseConfig,
-			Flags:       []cli.Flag{configFlag, descriptionFlag},
+			Flags: []cli.Flag{
+				configFlag, descriptionFlag, irreversibleFlag, namespaceFlag,
+				&cli.StringSliceFlag{
+					Name:  "parent",
+					Usage: "timestamp, namespace:timestamp or name of a parent migration; repeat for several parents (default: the current heads)",
+				},
+				&cli.BoolFlag{
+					Name:  "no-parent",
+					Usage: "create the migration without parents",
+				},
+				&cli.BoolFlag{
+					Name:  "no-transaction",
+					Usage: "mark the migration to run outside a transaction, for statements such as CREATE INDEX CONCURRENTLY",
+				},
+				&cli.BoolFlag{
+					Name:  "single-file",
+					Usage: "create the migration as a single <timestamp>_<name>.sql file with -- +kat Up and -- +kat Down sections",
+				},
+				&cli.StringFlag{
+					Name:    "template",
+					Usage:   "name of the template to create the migration from, either built in or from the migration.templates directory",
+					Aliases: []string{"t"},
+					Value:   "default",
+				},
+			},
 		},
 		{
 			Name:        "up",
 			Usage:       "Run migrations",
-			Description: "Apply migrations",
+			Description: "Apply pending migrations, or a single one with --only",
 			Action:      upExec,
 			Before:      config.ParseConfig,
 			Flags: []cli.Flag{
@@ -231,12 +282,16 @@ var kat = &cli.App{
 					Aliases: []string{"n"},
 					Usage:   "number of migrations to apply (default: 0)",
 					Value:   0,
-				}, configFlag, dryRunFlag},
+				},
+				&cli.Int64Flag{
+					Name:  "only",
+					Usage: "apply only the migration with this timestamp; its parents must already be applied",
+				}, configFlag, dryRunFlag, metricsFileFlag},
 		},
 		{
 			Name:        "down",
 			Usage:       "Rollback migrations",
-			Description: "Rollback the most recent migration or specify a count with --count flag",
+			Description: "Rollback the most recent migration, specify a count with --count flag, or roll back the last `kat up` run with --batch",
 			Action:      downExec,
 			Before:      config.ParseConfig,
 			Flags: []cli.Flag{
@@ -245,7 +300,130 @@ var kat = &cli.App{
 					Aliases: []string{"n"},
 					Usage:   "number of migrations to roll back (default: 1)",
 					Value:   1,
-				}, configFlag, dryRunFlag},
+				},
+				&cli.BoolFlag{
+					Name:  "batch",
+					Usage: "roll back every migration applied by the most recent `kat up`, in reverse order of application",
+				}, configFlag, dryRunFlag, forceFlag},
+		},
+		{
+			Name:        "status",
+			Usage:       "Show migration status",
+			Description: "Lists every migration and whether it has been applied to the database",
+			Action:      statusExec,
+			Before:      config.ParseConfig,
+			Flags:       []cli.Flag{configFlag},
+		},
+		{
+			Name:        "validate",
+			Usage:       "Validate migrations",
+			Description: "Checks every migration for duplicate IDs, mismatched directory names, missing parents and dependency cycles",
+			Action:      validateExec,
+			Before:      config.ParseConfig,
+			Flags:       []cli.Flag{configFlag},
+		},
+		{
+			Name:        "heads",
+			Usage:       "Show migration heads",
+			Description: "Lists the migrations nothing depends on yet, with the branch that leads to each when there are several",
+			Action:      headsExec,
+			Before:      config.ParseConfig,
+			Flags:       []cli.Flag{configFlag},
+		},
+		{
+			Name:        "merge",
+			ArgsUsage:   "<timestamp> <timestamp>...",
+			Usage:       "Merge migration heads",
+			Description: "Creates an empty migration whose parents are the given heads, joining diverged branches back into one",
+			Action:      mergeExec,
+			Before:      config.ParseConfig,
+			Flags: []cli.Flag{
+				configFlag,
+				descriptionFlag,
+				&cli.StringFlag{
+					Name:  "name",
+					Usage: "name of the merge migration",
+					Value: "merge",
+				},
+			},
+		},
+		{
+			Name:        "rebase",
+			ArgsUsage:   "<timestamp>...",
+			Usage:       "Move migrations onto the current heads",
+			Description: "Rewrites the parents of the given migrations so they build on the current heads of their namespace, for example after merging another branch",
+			Action:      rebaseExec,
+			Before:      config.ParseConfig,
+			Flags: []cli.Flag{
+				configFlag,
+				&cli.BoolFlag{
+					Name:  "renumber",
+					Usage: "give the migrations new IDs and rename their directories so they sort after the current heads",
+				},
+				&cli.BoolFlag{
+					Name:  "offline",
+					Usage: "don't connect to the database to check that the migrations haven't been applied",
+				},
+			},
+		},
+		{
+			Name:        "import",
+			ArgsUsage:   "<directory>",
+			Usage:       "Import migrations from another tool",
+			Description: "Converts the migrations of goose, golang-migrate, dbmate or sql-migrate into Kat migrations that follow each other in a linear chain. With --copy-history, the migrations the tool has applied are recorded as applied in Kat's tracking table",
+			Action:      importExec,
+			Before:      config.ParseConfig,
+			Flags: []cli.Flag{
+				configFlag,
+				namespaceFlag,
+				&cli.StringFlag{
+					Name:     "from",
+					Usage:    "tool the migrations were written for: goose, golang-migrate, dbmate or sql-migrate",
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "copy-history",
+					Usage: "record the migrations the tool has applied as applied in the tracking table",
+				},
+				&cli.StringFlag{
+					Name:  "table",
+					Usage: "tracking table of the tool, if it isn't the tool's default",
+				},
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "Inspect the configuration",
+			Subcommands: []*cli.Command{
+				{
+					Name:        "show",
+					Usage:       "Show the resolved configuration",
+					Description: "Prints the configuration kat uses, with secrets redacted and the file, environment variable or flag each setting comes from",
+					Action:      configShowExec,
+					Flags:       []cli.Flag{configFlag},
+				},
+				{
+					Name:        "validate",
+					Usage:       "Validate the configuration file",
+					Description: "Checks the configuration file against the kat.conf.yaml JSON schema, then loads it the way other commands do",
+					Action:      configValidateExec,
+					Flags:       []cli.Flag{configFlag},
+				},
+				{
+					Name:        "path",
+					Usage:       "Print the configuration file path",
+					Description: "Prints the configuration file kat reads, found in the working directory or one of its parents",
+					Action:      configPathExec,
+					Flags:       []cli.Flag{configFlag},
+				},
+			},
+		},
+		{
+			Name:        "doctor",
+			Usage:       "Diagnose the environment",
+			Description: "Checks the configuration, database connection, server version, permissions, tracking table and migrations, and suggests fixes for what's wrong",
+			Action:      doctorExec,
+			Flags:       []cli.Flag{configFlag, retryCountFlag, retryDelayFlag},
 		},
 		{
 			Name:        "ping",
@@ -269,9 +447,17 @@ var kat = &cli.App{
 			cli.ShowSubcommandHelpAndExit(c, 1)
 		}
 
-		errMsg := err.Error()
-		if errMsg != "" {
-			fmt.Fprintf(os.Stderr, "%s%s%s\n", output.StyleFailure, errMsg, output.StyleReset)
+		var rendered *renderedError
+		switch {
+		case errors.As(err, &rendered):
+			// The command already wrote its JSON document.
+		case isJSON(c):
+			_ = writeDocument(os.Stdout, commandName(c), nil, err)
+		default:
+			errMsg := err.Error()
+			if errMsg != "" {
+				fmt.Fprintf(os.Stderr, "%s%s%s\n", output.StyleFailure, errMsg, output.StyleReset)
+			}
 		}
 
 		// Determine exit code
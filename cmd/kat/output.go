@@ -0,0 +1,495 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/config"
+	"github.com/BolajiOlajide/kat/internal/migration"
+	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Error codes reported in the JSON error document. They are part of the CLI's public
+// interface, so existing codes must not be renamed.
+const (
+	errCodeGeneric               = "error"
+	errCodeInvalidArguments      = "invalid_arguments"
+	errCodeConfigNotFound        = "config_not_found"
+	errCodeMigrationsDirNotFound = "migrations_dir_not_found"
+	errCodeIrreversibleMigration = "irreversible_migration"
+	errCodeMigrationFailed       = "migration_failed"
+	errCodeConnectionFailed      = "connection_failed"
+	errCodeConfirmationRequired  = "confirmation_required"
+	errCodeValidationFailed      = "validation_failed"
+	errCodeChecksFailed          = "checks_failed"
+	errCodeOrphanedMigrations    = "orphaned_migrations"
+	errCodeOutOfOrderMigrations  = "out_of_order_migrations"
+)
+
+var outputFlag = &cli.StringFlag{
+	Name:    "output",
+	Usage:   "output format, one of text or json",
+	Aliases: []string{"o"},
+	EnvVars: []string{"KAT_OUTPUT"},
+	Value:   string(output.FormatText),
+	Action: func(_ *cli.Context, s string) error {
+		_, err := output.ParseFormat(s)
+		return err
+	},
+}
+
+// codedError carries an explicit error code for the JSON error document.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// renderedError marks an error whose JSON document has already been written, so the
+// exit handler only has to set the exit code.
+type renderedError struct{ err error }
+
+func (e *renderedError) Error() string { return e.err.Error() }
+func (e *renderedError) Unwrap() error { return e.err }
+
+func isJSON(c *cli.Context) bool {
+	return migration.OutputFormatFromCtx(c) == output.FormatJSON
+}
+
+// render writes the result of a successful command. In text mode text prints the
+// human-readable output; in JSON mode result is written to stdout as a single document.
+func render(c *cli.Context, result any, text func() error) error {
+	if !isJSON(c) {
+		return text()
+	}
+	return writeDocument(os.Stdout, commandName(c), result, nil)
+}
+
+// renderFailure writes a JSON document describing a failed command together with any
+// partial result, and returns an error that tells the exit handler not to write another.
+// In text mode err is returned unchanged.
+func renderFailure(c *cli.Context, result any, err error) error {
+	if !isJSON(c) {
+		return err
+	}
+	if werr := writeDocument(os.Stdout, commandName(c), result, err); werr != nil {
+		return errors.CombineErrors(err, werr)
+	}
+	return &renderedError{err: err}
+}
+
+// commandName returns the name of the running command, including its parent for
+// subcommands such as `config show`.
+func commandName(c *cli.Context) string {
+	var names []string
+	for _, ctx := range c.Lineage() {
+		// The app's own root command isn't part of the name.
+		if ctx.Command != nil && ctx.Command.Name != "" && ctx.Command.Name != c.App.Name {
+			names = append([]string{ctx.Command.Name}, names...)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+func writeDocument(w io.Writer, command string, result any, err error) error {
+	doc := output.Document{
+		Command: command,
+		Success: err == nil,
+		Result:  result,
+	}
+	if err != nil {
+		doc.Error = &output.ErrorDocument{Code: errorCode(err), Message: err.Error()}
+	}
+	return output.WriteJSON(w, doc)
+}
+
+// errorCode maps an error to the stable code reported in the JSON error document.
+func errorCode(err error) string {
+	var (
+		coded        *codedError
+		irreversible *types.IrreversibleMigrationError
+		orphaned     *types.OrphanedMigrationsError
+		outOfOrder   *types.OutOfOrderMigrationsError
+		exitCoder    cli.ExitCoder
+	)
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &irreversible):
+		return errCodeIrreversibleMigration
+	case errors.As(err, &orphaned):
+		return errCodeOrphanedMigrations
+	case errors.As(err, &outOfOrder):
+		return errCodeOutOfOrderMigrations
+	case errors.Is(err, config.ErrConfigNotFound):
+		return errCodeConfigNotFound
+	case errors.Is(err, migration.ErrMigrationsDirNotExist):
+		return errCodeMigrationsDirNotFound
+	case errors.As(err, &exitCoder):
+		return errCodeInvalidArguments
+	default:
+		return errCodeGeneric
+	}
+}
+
+// migrationRunResult is the JSON result of `kat up` and `kat down`.
+type migrationRunResult struct {
+	Operation  string                `json:"operation"`
+	DryRun     bool                  `json:"dry_run"`
+	Migrations []migrationResultJSON `json:"migrations"`
+}
+
+type migrationResultJSON struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func newMigrationRunResult(op types.MigrationOperationType, dryRun bool, results []types.MigrationResult) migrationRunResult {
+	run := migrationRunResult{
+		Operation:  op.String(),
+		DryRun:     dryRun,
+		Migrations: make([]migrationResultJSON, 0, len(results)),
+	}
+	for _, r := range results {
+		m := migrationResultJSON{
+			Name:       r.Name,
+			DurationMS: milliseconds(r.Duration),
+		}
+		switch {
+		case r.Err != nil:
+			m.Status = "failed"
+			m.Error = r.Err.Error()
+		case r.DryRun:
+			m.Status = "dry_run"
+		case op.IsUpMigration():
+			m.Status = "applied"
+		default:
+			m.Status = "rolled_back"
+		}
+		run.Migrations = append(run.Migrations, m)
+	}
+	return run
+}
+
+// migrationRunError attaches the migration_failed code when the error was caused by a
+// migration's SQL rather than by setup or policy.
+func migrationRunError(results []types.MigrationResult, err error) error {
+	if n := len(results); n > 0 && results[n-1].Err != nil {
+		return &codedError{code: errCodeMigrationFailed, err: err}
+	}
+	return err
+}
+
+// statusJSON is one entry of the JSON result of `kat status`.
+type statusJSON struct {
+	Name          string     `json:"name"`
+	Namespace     string     `json:"namespace,omitempty"`
+	Status        string     `json:"status"`
+	AppliedAt     *time.Time `json:"applied_at,omitempty"`
+	Batch         int64      `json:"batch,omitempty"`
+	Irreversible  bool       `json:"irreversible"`
+	NoTransaction bool       `json:"no_transaction"`
+}
+
+type statusResult struct {
+	Total      int          `json:"total"`
+	Applied    int          `json:"applied"`
+	Pending    int          `json:"pending"`
+	Orphaned   int          `json:"orphaned"`
+	Migrations []statusJSON `json:"migrations"`
+}
+
+func newStatusResult(statuses []types.MigrationStatus) statusResult {
+	res := statusResult{Total: len(statuses), Migrations: make([]statusJSON, 0, len(statuses))}
+	for _, s := range statuses {
+		entry := statusJSON{
+			Name:          s.Definition.FileName(),
+			Namespace:     s.Definition.Namespace,
+			Status:        "pending",
+			Irreversible:  s.Definition.Irreversible,
+			NoTransaction: s.Definition.NoTransaction,
+		}
+		switch {
+		case s.Orphaned:
+			entry.Name, entry.Status, entry.Batch = s.Log.Name, "orphaned", s.Log.Batch
+			appliedAt := s.Log.MigrationTime
+			entry.AppliedAt = &appliedAt
+			res.Orphaned++
+		case s.Applied():
+			entry.Status, entry.Batch = "applied", s.Log.Batch
+			appliedAt := s.Log.MigrationTime
+			entry.AppliedAt = &appliedAt
+			res.Applied++
+		default:
+			res.Pending++
+		}
+		res.Migrations = append(res.Migrations, entry)
+	}
+	res.Total -= res.Orphaned
+	return res
+}
+
+// definitionJSON describes a migration in the JSON result of `kat export`.
+type definitionJSON struct {
+	Name          string  `json:"name"`
+	Namespace     string  `json:"namespace,omitempty"`
+	Timestamp     int64   `json:"timestamp"`
+	Description   string  `json:"description,omitempty"`
+	Parents       []int64 `json:"parents"`
+	Irreversible  bool    `json:"irreversible"`
+	NoTransaction bool    `json:"no_transaction"`
+}
+
+type exportResult struct {
+	Migrations []definitionJSON `json:"migrations"`
+	Graph      string           `json:"graph,omitempty"`
+	File       string           `json:"file,omitempty"`
+}
+
+func newDefinitionsJSON(defs []types.Definition) []definitionJSON {
+	out := make([]definitionJSON, 0, len(defs))
+	for _, def := range defs {
+		parents := def.Parents
+		if parents == nil {
+			parents = []int64{}
+		}
+		out = append(out, definitionJSON{
+			Name:          def.FileName(),
+			Namespace:     def.Namespace,
+			Timestamp:     def.Timestamp,
+			Description:   def.Description,
+			Parents:       parents,
+			Irreversible:  def.Irreversible,
+			NoTransaction: def.NoTransaction,
+		})
+	}
+	return out
+}
+
+// validateResult is the JSON result of `kat validate`.
+type validateResult struct {
+	Valid      bool          `json:"valid"`
+	Migrations int           `json:"migrations"`
+	Problems   []problemJSON `json:"problems"`
+}
+
+type problemJSON struct {
+	Migration string `json:"migration,omitempty"`
+	Message   string `json:"message"`
+}
+
+func newValidateResult(count int, problems []migration.Problem) validateResult {
+	res := validateResult{
+		Valid:      len(problems) == 0,
+		Migrations: count,
+		Problems:   make([]problemJSON, 0, len(problems)),
+	}
+	for _, p := range problems {
+		res.Problems = append(res.Problems, problemJSON{Migration: p.Migration, Message: p.Message})
+	}
+	return res
+}
+
+// configShowResult is the JSON result of `kat config show`.
+type configShowResult struct {
+	Path     string        `json:"path,omitempty"`
+	Settings []settingJSON `json:"settings"`
+}
+
+type settingJSON struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+func newConfigShowResult(resolved *config.Resolved, settings []config.Setting) configShowResult {
+	res := configShowResult{Path: resolved.Path, Settings: make([]settingJSON, 0, len(settings))}
+	for _, s := range settings {
+		res.Settings = append(res.Settings, settingJSON{Key: s.Key, Value: s.Value, Source: s.Source})
+	}
+	return res
+}
+
+// configValidateResult is the JSON result of `kat config validate`.
+type configValidateResult struct {
+	Path     string              `json:"path"`
+	Valid    bool                `json:"valid"`
+	Problems []configProblemJSON `json:"problems"`
+}
+
+type configProblemJSON struct {
+	Key     string `json:"key,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func newConfigValidateResult(path string, problems []config.SchemaProblem) configValidateResult {
+	res := configValidateResult{
+		Path:     path,
+		Valid:    len(problems) == 0,
+		Problems: make([]configProblemJSON, 0, len(problems)),
+	}
+	for _, p := range problems {
+		res.Problems = append(res.Problems, configProblemJSON{Key: p.Key, Line: p.Line, Message: p.Message})
+	}
+	return res
+}
+
+// configPathResult is the JSON result of `kat config path`.
+type configPathResult struct {
+	Path string `json:"path"`
+}
+
+// doctorResult is the JSON result of `kat doctor`.
+type doctorResult struct {
+	Checks []checkJSON `json:"checks"`
+}
+
+type checkJSON struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+func newDoctorResult(checks []migration.Check) doctorResult {
+	res := doctorResult{Checks: make([]checkJSON, 0, len(checks))}
+	for _, c := range checks {
+		res.Checks = append(res.Checks, checkJSON{Name: c.Name, Status: string(c.Status), Message: c.Message, Hint: c.Hint})
+	}
+	return res
+}
+
+// headsResult is the JSON result of `kat heads`.
+type headsResult struct {
+	Heads []headJSON `json:"heads"`
+}
+
+type headJSON struct {
+	Name         string   `json:"name"`
+	Namespace    string   `json:"namespace,omitempty"`
+	Timestamp    int64    `json:"timestamp"`
+	Branch       []string `json:"branch"`
+	BranchedFrom []string `json:"branched_from"`
+}
+
+func newHeadsResult(heads []migration.Head) headsResult {
+	res := headsResult{Heads: make([]headJSON, 0, len(heads))}
+	for _, h := range heads {
+		entry := headJSON{
+			Name:         h.FileName(),
+			Namespace:    h.Namespace,
+			Timestamp:    h.Timestamp,
+			Branch:       make([]string, 0, len(h.Branch)),
+			BranchedFrom: make([]string, 0, len(h.BranchedFrom)),
+		}
+		for _, def := range h.Branch {
+			entry.Branch = append(entry.Branch, def.QualifiedName())
+		}
+		for _, def := range h.BranchedFrom {
+			entry.BranchedFrom = append(entry.BranchedFrom, def.QualifiedName())
+		}
+		res.Heads = append(res.Heads, entry)
+	}
+	return res
+}
+
+// rebaseResult is the JSON result of `kat rebase`.
+type rebaseResult struct {
+	Migrations []rebasedJSON `json:"migrations"`
+}
+
+type rebasedJSON struct {
+	Name         string  `json:"name"`
+	PreviousName string  `json:"previous_name"`
+	Namespace    string  `json:"namespace,omitempty"`
+	Parents      []int64 `json:"parents"`
+	Path         string  `json:"path"`
+}
+
+func newRebaseResult(rebased []migration.RebasedMigration) rebaseResult {
+	res := rebaseResult{Migrations: make([]rebasedJSON, 0, len(rebased))}
+	for _, m := range rebased {
+		parents := m.To.Parents
+		if parents == nil {
+			parents = []int64{}
+		}
+		res.Migrations = append(res.Migrations, rebasedJSON{
+			Name:         m.To.FileName(),
+			PreviousName: m.From.FileName(),
+			Namespace:    m.To.Namespace,
+			Parents:      parents,
+			Path:         m.Path,
+		})
+	}
+	return res
+}
+
+// importResult is the JSON result of `kat import`.
+type importResult struct {
+	Migrations []importedJSON `json:"migrations"`
+}
+
+type importedJSON struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Sources   []string `json:"sources"`
+	Path      string   `json:"path"`
+	Applied   bool     `json:"applied"`
+}
+
+func newImportResult(imported []migration.ImportedMigration) importResult {
+	res := importResult{Migrations: make([]importedJSON, 0, len(imported))}
+	for _, m := range imported {
+		res.Migrations = append(res.Migrations, importedJSON{
+			Name:      m.FileName(),
+			Namespace: m.Namespace,
+			Sources:   m.Sources,
+			Path:      m.Path,
+			Applied:   m.Applied,
+		})
+	}
+	return res
+}
+
+type pingResult struct {
+	Driver       string  `json:"driver"`
+	LatencyMS    float64 `json:"latency_ms"`
+	RetryCount   int     `json:"retry_count"`
+	RetryDelayMS int     `json:"retry_delay_ms"`
+}
+
+type addResult struct {
+	Name     string `json:"name"`
+	Up       string `json:"up"`
+	Down     string `json:"down"`
+	Metadata string `json:"metadata"`
+}
+
+type initResult struct {
+	ConfigFile string `json:"config_file"`
+}
+
+type versionResult struct {
+	Version string `json:"version"`
+}
+
+type updateResult struct {
+	CurrentVersion string `json:"current_version"`
+	LatestVersion  string `json:"latest_version,omitempty"`
+	Updated        bool   `json:"updated"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
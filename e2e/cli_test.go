@@ -3,6 +3,7 @@
 package e2e
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -314,6 +315,52 @@ func TestCLI_DownAll(t *testing.T) {
 	}
 }
 
+func TestCLI_DownIrreversible(t *testing.T) {
+	for _, p := range allProviders {
+		t.Run(p.name, func(t *testing.T) {
+			connStr, cleanup := p.setup(t)
+			defer cleanup()
+
+			projDir := createTempProject(t, p, connStr, fixturesPath(t, "irreversible"))
+
+			_, _, exitCode := runKat(t, projDir, []string{"up"}, nil)
+			require.Equal(t, 0, exitCode)
+
+			_, stderr, exitCode := runKat(t, projDir, []string{"down"}, nil)
+			require.NotEqual(t, 0, exitCode)
+			require.Contains(t, stderr, "1000000002_drop_legacy")
+			require.Contains(t, stderr, "irreversible")
+
+			db := openDB(t, p, connStr)
+			require.Equal(t, 2, countRows(t, db, "migration_logs"))
+
+			_, _, exitCode = runKat(t, projDir, []string{"down", "--force"}, nil)
+			require.Equal(t, 0, exitCode)
+			require.Equal(t, 1, countRows(t, db, "migration_logs"))
+		})
+	}
+}
+
+func TestCLI_Status(t *testing.T) {
+	for _, p := range allProviders {
+		t.Run(p.name, func(t *testing.T) {
+			connStr, cleanup := p.setup(t)
+			defer cleanup()
+
+			projDir := createTempProject(t, p, connStr, fixturesPath(t, "irreversible"))
+
+			_, _, exitCode := runKat(t, projDir, []string{"up", "--count", "1"}, nil)
+			require.Equal(t, 0, exitCode)
+
+			stdout, _, exitCode := runKat(t, projDir, []string{"status"}, nil)
+			require.Equal(t, 0, exitCode)
+			require.Contains(t, stdout, "applied  1000000001_create_users")
+			require.Contains(t, stdout, "pending  1000000002_drop_legacy")
+			require.Contains(t, stdout, "irreversible")
+		})
+	}
+}
+
 func TestCLI_UpThenDown(t *testing.T) {
 	for _, p := range allProviders {
 		t.Run(p.name, func(t *testing.T) {
@@ -557,3 +604,75 @@ func TestCLI_SingleMigration(t *testing.T) {
 		})
 	}
 }
+
+// jsonDocument mirrors the envelope written by `kat --output json`.
+type jsonDocument struct {
+	Command string          `json:"command"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func decodeDocument(t *testing.T, stdout string) jsonDocument {
+	t.Helper()
+	var doc jsonDocument
+	require.NoError(t, json.Unmarshal([]byte(stdout), &doc), "stdout should be a single JSON document: %s", stdout)
+	return doc
+}
+
+func TestCLI_OutputJSON(t *testing.T) {
+	for _, p := range allProviders {
+		t.Run(p.name, func(t *testing.T) {
+			connStr, cleanup := p.setup(t)
+			defer cleanup()
+
+			projDir := createTempProject(t, p, connStr, fixturesPath(t, "irreversible"))
+
+			stdout, _, exitCode := runKat(t, projDir, []string{"--output", "json", "up"}, nil)
+			require.Equal(t, 0, exitCode)
+			doc := decodeDocument(t, stdout)
+			require.Equal(t, "up", doc.Command)
+			require.True(t, doc.Success)
+
+			var up struct {
+				Migrations []struct {
+					Name   string `json:"name"`
+					Status string `json:"status"`
+				} `json:"migrations"`
+			}
+			require.NoError(t, json.Unmarshal(doc.Result, &up))
+			require.Len(t, up.Migrations, 2)
+			require.Equal(t, "1000000001_create_users", up.Migrations[0].Name)
+			require.Equal(t, "applied", up.Migrations[0].Status)
+
+			stdout, _, exitCode = runKat(t, projDir, []string{"-o", "json", "down"}, nil)
+			require.NotEqual(t, 0, exitCode)
+			doc = decodeDocument(t, stdout)
+			require.False(t, doc.Success)
+			require.NotNil(t, doc.Error)
+			require.Equal(t, "irreversible_migration", doc.Error.Code)
+
+			stdout, _, exitCode = runKat(t, projDir, []string{"-o", "json", "ping"}, nil)
+			require.Equal(t, 0, exitCode)
+			doc = decodeDocument(t, stdout)
+			require.Contains(t, string(doc.Result), "latency_ms")
+
+			stdout, _, exitCode = runKat(t, projDir, []string{"-o", "json", "export"}, nil)
+			require.Equal(t, 0, exitCode)
+			doc = decodeDocument(t, stdout)
+			require.Contains(t, string(doc.Result), "1000000002_drop_legacy")
+		})
+	}
+}
+
+func TestCLI_OutputJSON_NoConfig(t *testing.T) {
+	stdout, _, exitCode := runKat(t, t.TempDir(), []string{"--output", "json", "up"}, nil)
+	require.NotEqual(t, 0, exitCode)
+
+	doc := decodeDocument(t, stdout)
+	require.False(t, doc.Success)
+	require.Equal(t, "config_not_found", doc.Error.Code)
+}
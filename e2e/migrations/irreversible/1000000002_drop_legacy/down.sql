@@ -0,0 +1 @@
+-- This migration can't be undone.
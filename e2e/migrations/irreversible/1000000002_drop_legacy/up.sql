@@ -0,0 +1 @@
+CREATE TABLE legacy_audit (id INTEGER PRIMARY KEY);
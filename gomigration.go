@@ -0,0 +1,113 @@
+package kat
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// GoMigrationFunc is the up or down step of a migration written in Go. It runs inside
+// the migration's transaction; the tracking table is updated in the same transaction.
+type GoMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// GoMigrationNoTxFunc is the up or down step of a Go migration registered with
+// RegisterGoMigrationNoTx. It runs outside a transaction.
+type GoMigrationNoTxFunc func(ctx context.Context, db *sql.DB) error
+
+var goMigrations = struct {
+	sync.Mutex
+	defs map[int64]types.Definition
+}{defs: map[int64]types.Definition{}}
+
+// RegisterGoMigration registers a migration written in Go. Registered migrations join the
+// SQL migrations of every Migration created afterwards with New or NewWithDB: they share
+// the same graph, so SQL and Go migrations can be each other's parents, and they are
+// recorded in the same tracking table under "<timestamp>_<name>".
+//
+// Register migrations from an init function, before calling New:
+//
+//	func init() {
+//		if err := kat.RegisterGoMigration(1679012345, "reencode_settings", []int64{1679012300}, upReencode, nil); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// A nil down function makes rolling the migration back a no-op. In dry runs neither
+// function is called.
+func RegisterGoMigration(timestamp int64, name string, parents []int64, up, down GoMigrationFunc) error {
+	if up == nil {
+		return errors.New("up function cannot be nil")
+	}
+	return registerGoMigration(types.MigrationMetadata{
+		Name:      name,
+		Timestamp: timestamp,
+		Parents:   parents,
+	}, &types.GoMigration{
+		Up:   up,
+		Down: down,
+	})
+}
+
+// RegisterGoMigrationNoTx registers a migration written in Go that runs outside a
+// transaction, like a SQL migration marked no_transaction. The functions receive the
+// connection pool instead of a transaction. See RegisterGoMigration.
+func RegisterGoMigrationNoTx(timestamp int64, name string, parents []int64, up, down GoMigrationNoTxFunc) error {
+	if up == nil {
+		return errors.New("up function cannot be nil")
+	}
+	return registerGoMigration(types.MigrationMetadata{
+		Name:          name,
+		Timestamp:     timestamp,
+		Parents:       parents,
+		NoTransaction: true,
+	}, &types.GoMigration{
+		UpNoTx:   up,
+		DownNoTx: down,
+	})
+}
+
+func registerGoMigration(metadata types.MigrationMetadata, fns *types.GoMigration) error {
+	if metadata.Timestamp <= 0 {
+		return errors.New("timestamp must be a positive number")
+	}
+	if metadata.Name == "" || strings.ContainsAny(metadata.Name, " /\\") {
+		return errors.Newf("invalid migration name %q: it cannot be empty or contain spaces or slashes", metadata.Name)
+	}
+	if slices.Contains(metadata.Parents, metadata.Timestamp) {
+		return errors.Newf("migration %d cannot be its own parent", metadata.Timestamp)
+	}
+
+	goMigrations.Lock()
+	defer goMigrations.Unlock()
+
+	if existing, ok := goMigrations.defs[metadata.Timestamp]; ok {
+		return errors.Newf("a Go migration with timestamp %d is already registered: %s", metadata.Timestamp, existing.FileName())
+	}
+	goMigrations.defs[metadata.Timestamp] = types.Definition{
+		MigrationMetadata: metadata,
+		Go:                fns,
+	}
+	return nil
+}
+
+// registeredGoMigrations returns the registered Go migrations in timestamp order.
+func registeredGoMigrations() []types.Definition {
+	goMigrations.Lock()
+	defer goMigrations.Unlock()
+
+	defs := make([]types.Definition, 0, len(goMigrations.defs))
+	for _, def := range goMigrations.defs {
+		defs = append(defs, def)
+	}
+	slices.SortFunc(defs, func(a, b types.Definition) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	return defs
+}
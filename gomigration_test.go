@@ -0,0 +1,133 @@
+package kat
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// registerForTest registers a Go migration and removes it again when the test ends.
+func registerForTest(t *testing.T, register func() error, timestamp int64) {
+	t.Helper()
+
+	require.NoError(t, register())
+	t.Cleanup(func() {
+		goMigrations.Lock()
+		defer goMigrations.Unlock()
+		delete(goMigrations.defs, timestamp)
+	})
+}
+
+func TestRegisterGoMigration(t *testing.T) {
+	noop := func(context.Context, *sql.Tx) error { return nil }
+	registerForTest(t, func() error {
+		return RegisterGoMigration(1651234600, "existing", nil, noop, nil)
+	}, 1651234600)
+	// Parents can be newer than their children, as after a rebase without --renumber.
+	registerForTest(t, func() error {
+		return RegisterGoMigration(1651234590, "rebased", []int64{1651234600}, noop, nil)
+	}, 1651234590)
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		migration string
+		parents   []int64
+		up        GoMigrationFunc
+		wantErr   string
+	}{
+		{name: "nil up", timestamp: 1651234601, migration: "backfill", wantErr: "up function cannot be nil"},
+		{name: "zero timestamp", migration: "backfill", up: noop, wantErr: "timestamp must be a positive number"},
+		{name: "empty name", timestamp: 1651234601, up: noop, wantErr: "invalid migration name"},
+		{name: "name with spaces", timestamp: 1651234601, migration: "back fill", up: noop, wantErr: "invalid migration name"},
+		{name: "own parent", timestamp: 1651234601, migration: "backfill", parents: []int64{1651234601}, up: noop, wantErr: "cannot be its own parent"},
+		{name: "duplicate timestamp", timestamp: 1651234600, migration: "backfill", up: noop, wantErr: "1651234600_existing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterGoMigration(tt.timestamp, tt.migration, tt.parents, tt.up, nil)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGoMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	registerForTest(t, func() error {
+		return RegisterGoMigration(1651234570, "seed_users", []int64{1651234568},
+			func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO users (id) VALUES (1), (2)")
+				return err
+			},
+			func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM users")
+				return err
+			},
+		)
+	}, 1651234570)
+	registerForTest(t, func() error {
+		return RegisterGoMigrationNoTx(1651234580, "seed_posts", []int64{1651234570},
+			func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "INSERT INTO posts (id, user_id) VALUES (1, 1)")
+				return err
+			},
+			nil,
+		)
+	}, 1651234580)
+
+	countRows := func(t *testing.T, db *sql.DB, table string) int {
+		t.Helper()
+		var n int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n))
+		return n
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "kat.db")
+
+	dry, err := New(SQLiteDriver, dbPath, sqliteMigrations, "migration_logs", WithDryRun())
+	require.NoError(t, err)
+	t.Cleanup(func() { dry.Close() })
+	require.NoError(t, dry.Up(ctx, 0))
+
+	statuses, err := dry.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	for _, s := range statuses {
+		require.False(t, s.Applied(), "dry run must not apply %s", s.Definition.FileName())
+	}
+
+	sqlDB, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	m, err := NewWithDB(SQLiteDriver, sqlDB, sqliteMigrations, "migration_logs")
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx, 0))
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, s := range statuses {
+		require.True(t, s.Applied(), "%s should be applied", s.Definition.FileName())
+		names = append(names, s.Definition.FileName())
+	}
+	require.Equal(t, []string{
+		"1651234567_create_users",
+		"1651234568_create_posts",
+		"1651234570_seed_users",
+		"1651234580_seed_posts",
+	}, names)
+	require.Equal(t, 2, countRows(t, sqlDB, "users"))
+	require.Equal(t, 1, countRows(t, sqlDB, "posts"))
+
+	// seed_posts has no down function, so rolling it back only removes its tracking row.
+	require.NoError(t, m.Down(ctx, 2))
+	require.Equal(t, 0, countRows(t, sqlDB, "users"))
+	require.Equal(t, 2, countRows(t, sqlDB, "migration_logs"))
+}
@@ -5,24 +5,33 @@
 // The package supports:
 //   - Loading configuration from YAML files
 //   - Environment variable substitution in configuration
+//   - Overriding configuration fields, or configuring kat without a file, with KAT_*
+//     environment variables and DATABASE_URL
+//   - Loading environment variables from a .env file
 //   - Context-based configuration management for CLI commands
 //   - Validation of configuration parameters
 package config
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v3"
 
 	"github.com/BolajiOlajide/kat/internal/constants"
+	"github.com/BolajiOlajide/kat/internal/output"
 	"github.com/BolajiOlajide/kat/internal/types"
 )
 
-var errConfigNotFound = errors.Newf("config file not provided. Provide one via the `-c` flag or have the %s in the current working directory.", constants.KatConfigurationFileName)
+// ErrConfigNotFound is returned when no configuration file could be found and the
+// environment doesn't configure kat either.
+var ErrConfigNotFound = errors.Newf("config file not provided. Provide one via the `-c` flag, have the %s in the current working directory or one of its parents, or configure kat with KAT_* environment variables or DATABASE_URL.", constants.KatConfigurationFileName)
 
 func GetKatConfigFromCtx(c *cli.Context) (types.Config, error) {
 	cfg, ok := c.Context.Value(constants.KatConfigKey).(types.Config)
@@ -32,41 +41,277 @@ func GetKatConfigFromCtx(c *cli.Context) (types.Config, error) {
 	return cfg, nil
 }
 
+// Resolved is a loaded configuration together with where its settings came from.
+type Resolved struct {
+	Config types.Config
+
+	// Path is the configuration file that was read, or empty when the environment alone
+	// configures kat.
+	Path string
+
+	// Sources maps the dotted key of each setting, such as database.host, to where its
+	// value came from: a file and line, an environment variable or a flag. Settings
+	// without a source have their default value.
+	Sources map[string]string
+
+	// Warnings are problems with the configuration file that don't stop kat from
+	// loading it.
+	Warnings []string
+}
+
+// ParseConfig loads the configuration into the command's context. Settings come, in order
+// of precedence, from command-line flags, KAT_* environment variables, the configuration
+// file and defaults; without a configuration file, the environment alone configures kat.
+// Variables from a .env file are loaded first unless they are already set.
 func ParseConfig(c *cli.Context) error {
-	var (
-		f   []byte
-		err error
-	)
+	resolved, err := Load(c)
+	if err != nil {
+		return err
+	}
+	resolved.WriteWarnings(os.Stderr)
+	c.Context = context.WithValue(c.Context, constants.KatConfigKey, resolved.Config)
+	return nil
+}
 
-	configPath := c.String("config")
-	if !filepath.IsAbs(configPath) {
-		wd, err := os.Getwd()
+// WriteWarnings writes the configuration's warnings to w, one per line.
+func (r *Resolved) WriteWarnings(w io.Writer) {
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "%swarning: %s%s\n", output.StyleWarning, warning, output.StyleReset)
+	}
+}
+
+// Load resolves the configuration the way ParseConfig does and reports where it came
+// from. Unless a file is given with --config, kat.conf.yaml is looked for in the working
+// directory and then in its parents, up to the root of the git repository.
+func Load(c *cli.Context) (*Resolved, error) {
+	if err := LoadEnvFile(c); err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting working directory")
+	}
+	configPath, err := FindConfigFile(c, wd)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &Resolved{Sources: map[string]string{}}
+	cfg := &resolved.Config
+	if configPath != "" {
+		f, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		doc, bare, err := decodeConfig(f, cfg)
 		if err != nil {
-			return errors.Wrap(err, "getting working directory")
+			return nil, errors.Wrapf(err, "parsing %s", configPath)
+		}
+		resolved.Path = configPath
+
+		display := configPath
+		if rel, err := filepath.Rel(wd, configPath); err == nil {
+			display = rel
+		}
+		for _, node := range bare {
+			resolved.Warnings = append(resolved.Warnings, fmt.Sprintf(
+				"%s:%d: %s is used as written because bare $VAR references are no longer expanded; write ${%s} to read the environment variable",
+				display, node.Line, node.Value, node.Value[1:],
+			))
+		}
+		walkSettings(doc, func(key string, _, value *yaml.Node) {
+			resolved.Sources[key] = fmt.Sprintf("%s:%d", display, value.Line)
+		})
+
+		// Paths in a file found in a parent directory are relative to that directory, so
+		// kat behaves the same anywhere below it.
+		if dir := filepath.Dir(configPath); !c.IsSet("config") && dir != wd {
+			resolveRelativePaths(cfg, dir)
 		}
-		configPath = filepath.Join(wd, configPath)
 	}
 
-	f, err = os.ReadFile(configPath)
+	fromEnv, err := applyEnv(cfg, os.LookupEnv, resolved.Sources)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return errConfigNotFound
+		return nil, err
+	}
+	if configPath == "" && !fromEnv {
+		return nil, ErrConfigNotFound
+	}
+	if c.IsSet("verbose") {
+		cfg.Verbose = c.Bool("verbose")
+		resolved.Sources["verbose"] = "--verbose"
+	}
+	if err := readSecretFiles(&cfg.Database); err != nil {
+		return nil, err
+	}
+	if cfg.Database.PasswordFile != "" {
+		resolved.Sources["database.password"] = "database.password_file"
+	}
+	if cfg.Database.URLFile != "" {
+		resolved.Sources["database.url"] = "database.url_file"
+	}
+
+	if err := cfg.SetDefault(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// LoadEnvFile loads the file given with --env-file, or .env in the working directory if
+// it exists, without overriding variables that are already set.
+func LoadEnvFile(c *cli.Context) error {
+	if envFile := c.String("env-file"); envFile != "" {
+		return loadEnvFile(envFile, true)
+	}
+	return loadEnvFile(defaultEnvFile, false)
+}
+
+// FindConfigFile returns the absolute path of the configuration file to read, or an empty
+// string when there is none and the environment has to configure kat. A file given with
+// --config has to exist; otherwise kat.conf.yaml is looked for in wd and its parents,
+// stopping at the root of the git repository wd is in.
+func FindConfigFile(c *cli.Context, wd string) (string, error) {
+	configPath := c.String("config")
+	if c.IsSet("config") {
+		if !filepath.IsAbs(configPath) {
+			configPath = filepath.Join(wd, configPath)
 		}
-		return err
+		if _, err := os.Stat(configPath); err != nil {
+			if os.IsNotExist(err) {
+				return "", ErrConfigNotFound
+			}
+			return "", err
+		}
+		return configPath, nil
+	}
+	if configPath == "" {
+		configPath = constants.KatConfigurationFileName
+	}
+	if filepath.IsAbs(configPath) {
+		return "", errors.Newf("the default configuration file %s must be a relative path", configPath)
 	}
 
-	// Expand environment variables in the config
-	configStr := os.ExpandEnv(string(f))
+	return searchUpward(wd, configPath)
+}
 
-	var cfg = &types.Config{}
-	err = yaml.Unmarshal([]byte(configStr), cfg)
-	if err != nil {
-		return errors.Wrap(err, "marshalling config")
+// searchUpward looks for name in dir and its parents, up to the first directory holding
+// .git, and returns its path or an empty string if there is none.
+func searchUpward(dir, name string) (string, error) {
+	for {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
 	}
+}
 
-	if err := cfg.SetDefault(); err != nil {
-		return err
+// resolveRelativePaths makes the relative paths of cfg relative to dir instead of the
+// working directory.
+func resolveRelativePaths(cfg *types.Config, dir string) {
+	resolve := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	resolve(&cfg.Migration.Directory)
+	resolve(&cfg.Migration.Templates)
+	for i := range cfg.Migration.Directories {
+		resolve(&cfg.Migration.Directories[i].Path)
+	}
+	resolve(&cfg.Database.PasswordFile)
+	resolve(&cfg.Database.URLFile)
+	// SQLite also accepts :memory: and file: URIs, which aren't paths.
+	if p := cfg.Database.Path; p != ":memory:" && !strings.HasPrefix(p, "file:") {
+		resolve(&cfg.Database.Path)
+	}
+}
+
+// walkSettings calls fn for every setting in a parsed configuration file with its dotted
+// key and the nodes of its key and value. Lists are reported as a single setting.
+func walkSettings(node *yaml.Node, fn func(key string, keyNode, value *yaml.Node)) {
+	var walk func(prefix string, node *yaml.Node)
+	walk = func(prefix string, node *yaml.Node) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			dotted := key.Value
+			if prefix != "" {
+				dotted = prefix + "." + key.Value
+			}
+			if value.Kind == yaml.MappingNode {
+				walk(dotted, value)
+				continue
+			}
+			fn(dotted, key, value)
+		}
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.MappingNode {
+		walk("", node)
+	}
+}
+
+// decodeConfig parses a configuration file into cfg, expanding environment variable
+// references in its values, and returns the expanded document along with the values that
+// are bare `$VAR` references, which aren't expanded.
+func decodeConfig(content []byte, cfg *types.Config) (*yaml.Node, []*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, err
+	}
+	if doc.Kind == 0 {
+		return &doc, nil, nil
+	}
+	bare := bareReferences(&doc)
+	if err := expandNode(&doc, os.LookupEnv); err != nil {
+		return nil, nil, err
+	}
+	return &doc, bare, doc.Decode(cfg)
+}
+
+// readSecretFiles reads the database password and URL from the files named by
+// password_file and url_file, such as mounted Kubernetes secrets. Trailing newlines are
+// dropped. Only one of a value, its file and its command can be set; commands are run
+// later, every time a connection string is built.
+func readSecretFiles(db *types.DatabaseInfo) error {
+	secrets := []struct {
+		field, fileField, commandField string
+		value                          *string
+		path, command                  string
+	}{
+		{"password", "password_file", "password_command", &db.Password, db.PasswordFile, db.PasswordCommand},
+		{"url", "url_file", "url_command", &db.URL, db.URLFile, db.URLCommand},
+	}
+	for _, secret := range secrets {
+		if secret.command != "" && (*secret.value != "" || secret.path != "") {
+			return errors.Newf("database.%s cannot be combined with database.%s or database.%s", secret.commandField, secret.field, secret.fileField)
+		}
+		if secret.path == "" {
+			continue
+		}
+		if *secret.value != "" {
+			return errors.Newf("database.%s and database.%s cannot both be set", secret.field, secret.fileField)
+		}
+		content, err := os.ReadFile(secret.path)
+		if err != nil {
+			return errors.Wrapf(err, "reading database.%s", secret.fileField)
+		}
+		*secret.value = strings.TrimRight(string(content), "\r\n")
 	}
-	c.Context = context.WithValue(c.Context, constants.KatConfigKey, *cfg)
 	return nil
 }
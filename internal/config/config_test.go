@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestReadSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cr$t\n"), 0600))
+
+	db := types.DatabaseInfo{User: "kat", PasswordFile: passwordFile}
+	require.NoError(t, readSecretFiles(&db))
+	require.Equal(t, "s3cr$t", db.Password)
+
+	db = types.DatabaseInfo{Password: "inline", PasswordFile: passwordFile}
+	require.EqualError(t, readSecretFiles(&db), "database.password and database.password_file cannot both be set")
+
+	db = types.DatabaseInfo{URLFile: filepath.Join(dir, "missing")}
+	require.ErrorContains(t, readSecretFiles(&db), "reading database.url_file")
+
+	db = types.DatabaseInfo{URLFile: passwordFile, URLCommand: "echo postgres://localhost/db"}
+	require.EqualError(t, readSecretFiles(&db), "database.url_command cannot be combined with database.url or database.url_file")
+}
+
+func TestSearchUpward(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	service := filepath.Join(repo, "services", "api")
+	require.NoError(t, os.MkdirAll(service, 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(repo, ".git"), 0o755))
+
+	// A file above the repository is never picked up.
+	require.NoError(t, os.WriteFile(filepath.Join(root, "kat.conf.yaml"), nil, 0o644))
+	path, err := searchUpward(service, "kat.conf.yaml")
+	require.NoError(t, err)
+	require.Empty(t, path)
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "kat.conf.yaml"), nil, 0o644))
+	path, err = searchUpward(service, "kat.conf.yaml")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(repo, "kat.conf.yaml"), path)
+
+	require.NoError(t, os.WriteFile(filepath.Join(service, "kat.conf.yaml"), nil, 0o644))
+	path, err = searchUpward(service, "kat.conf.yaml")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(service, "kat.conf.yaml"), path)
+}
+
+func TestResolveRelativePaths(t *testing.T) {
+	cfg := types.Config{
+		Migration: types.MigrationInfo{
+			Directory:   "migrations",
+			Templates:   "/etc/kat/templates",
+			Directories: []types.MigrationDirectory{{Namespace: "core", Path: "core/migrations"}},
+		},
+		Database: types.DatabaseInfo{Path: ":memory:", PasswordFile: "secrets/password"},
+	}
+	resolveRelativePaths(&cfg, "/repo")
+	require.Equal(t, "/repo/migrations", cfg.Migration.Directory)
+	require.Equal(t, "/etc/kat/templates", cfg.Migration.Templates)
+	require.Equal(t, "/repo/core/migrations", cfg.Migration.Directories[0].Path)
+	require.Equal(t, ":memory:", cfg.Database.Path)
+	require.Equal(t, "/repo/secrets/password", cfg.Database.PasswordFile)
+}
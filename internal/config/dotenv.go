@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// defaultEnvFile is the file loaded when it exists in the working directory and no other
+// file is given with --env-file.
+const defaultEnvFile = ".env"
+
+// loadEnvFile sets the variables defined in the file at path that aren't already set in
+// the environment. A missing file is an error only when required is true.
+func loadEnvFile(path string, required bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return errors.Wrapf(err, "reading env file %s", path)
+	}
+
+	vars, err := parseEnvFile(string(content))
+	if err != nil {
+		return errors.Wrapf(err, "parsing env file %s", path)
+	}
+	for _, v := range vars {
+		if _, ok := os.LookupEnv(v[0]); ok {
+			continue
+		}
+		if err := os.Setenv(v[0], v[1]); err != nil {
+			return errors.Wrapf(err, "setting %s", v[0])
+		}
+	}
+	return nil
+}
+
+// parseEnvFile parses the `KEY=value` lines of a .env file, in order. Blank lines and
+// lines starting with # are skipped, an `export ` prefix is allowed, and values can be
+// single-quoted (taken literally) or double-quoted (with \n, \t, \" and \\ escapes).
+// Unquoted values end at a ` #` comment.
+func parseEnvFile(content string) ([][2]string, error) {
+	var vars [][2]string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, errors.Newf("line %d: expected KEY=value", line)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, errors.Newf("line %d: unterminated double-quoted value", line)
+			}
+			unquoted, err := strconv.Unquote(value[:end+1])
+			if err != nil {
+				return nil, errors.Newf("line %d: invalid double-quoted value", line)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, errors.Newf("line %d: unterminated single-quoted value", line)
+			}
+			value = value[1 : end+1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars = append(vars, [2]string{key, value})
+	}
+	return vars, scanner.Err()
+}
+
+// closingQuote returns the index of the double quote that ends the quoted string at the
+// start of s, or -1 if there is none.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// databaseURLEnvVar is the conventional variable holding a database connection URL. It is
+// only used when nothing else configures the connection.
+const databaseURLEnvVar = "DATABASE_URL"
+
+// envVar binds an environment variable to a configuration field.
+type envVar struct {
+	name string
+	// key is the dotted key of the setting, such as database.host.
+	key string
+	set func(cfg *types.Config, value string) error
+}
+
+// envVars are the environment variables that override fields of the configuration file.
+// They use the same names as the flags of `kat init`.
+var envVars = []envVar{
+	{"KAT_DRIVER_NAME", "database.driver", func(cfg *types.Config, value string) error {
+		driver, err := dbdriver.ParseDBDriver(value)
+		cfg.Database.Driver = driver
+		return err
+	}},
+	{"KAT_MIGRATION_DATABASE_URL", "database.url", func(cfg *types.Config, value string) error {
+		cfg.Database.URLFile, cfg.Database.URLCommand = "", ""
+		setDatabaseURL(&cfg.Database, value)
+		return nil
+	}},
+	{"KAT_DB_URL_FILE", "database.url_file", func(cfg *types.Config, value string) error {
+		cfg.Database.URL, cfg.Database.URLFile, cfg.Database.URLCommand = "", value, ""
+		return nil
+	}},
+	{"KAT_DB_URL_COMMAND", "database.url_command", func(cfg *types.Config, value string) error {
+		cfg.Database.URL, cfg.Database.URLFile, cfg.Database.URLCommand = "", "", value
+		return nil
+	}},
+	{"KAT_DB_HOST", "database.host", stringField(func(cfg *types.Config) *string { return &cfg.Database.Host })},
+	{"KAT_DB_PORT", "database.port", stringField(func(cfg *types.Config) *string { return &cfg.Database.Port })},
+	{"KAT_DB_USER", "database.user", stringField(func(cfg *types.Config) *string { return &cfg.Database.User })},
+	{"KAT_DB_PASSWORD", "database.password", func(cfg *types.Config, value string) error {
+		cfg.Database.Password, cfg.Database.PasswordFile, cfg.Database.PasswordCommand = value, "", ""
+		return nil
+	}},
+	{"KAT_DB_PASSWORD_FILE", "database.password_file", func(cfg *types.Config, value string) error {
+		cfg.Database.Password, cfg.Database.PasswordFile, cfg.Database.PasswordCommand = "", value, ""
+		return nil
+	}},
+	{"KAT_DB_PASSWORD_COMMAND", "database.password_command", func(cfg *types.Config, value string) error {
+		cfg.Database.Password, cfg.Database.PasswordFile, cfg.Database.PasswordCommand = "", "", value
+		return nil
+	}},
+	{"KAT_DB_NAME", "database.name", stringField(func(cfg *types.Config) *string { return &cfg.Database.Name })},
+	{"KAT_DB_SSL_MODE", "database.sslmode", stringField(func(cfg *types.Config) *string { return &cfg.Database.SSLMode })},
+	{"KAT_DB_PATH", "database.path", stringField(func(cfg *types.Config) *string { return &cfg.Database.Path })},
+	{"KAT_DB_CONNECT_TIMEOUT", "database.connect_timeout", stringField(func(cfg *types.Config) *string { return &cfg.Database.ConnectTimeout })},
+	{"KAT_DB_STATEMENT_TIMEOUT", "database.statement_timeout", stringField(func(cfg *types.Config) *string { return &cfg.Database.StatementTimeout })},
+	{"KAT_DB_MAX_OPEN_CONNS", "database.max_open_conns", intField(func(cfg *types.Config) *int { return &cfg.Database.MaxOpenConns })},
+	{"KAT_DB_MAX_IDLE_CONNS", "database.max_idle_conns", intField(func(cfg *types.Config) *int { return &cfg.Database.MaxIdleConns })},
+	{"KAT_DB_CONN_MAX_LIFETIME", "database.conn_max_lifetime", stringField(func(cfg *types.Config) *string { return &cfg.Database.ConnMaxLifetime })},
+	{"KAT_DB_DEFAULT_TIMEOUT", "database.default_timeout", stringField(func(cfg *types.Config) *string { return &cfg.Database.DefaultTimeout })},
+	{"KAT_MIGRATION_TABLE_NAME", "migration.tablename", stringField(func(cfg *types.Config) *string { return &cfg.Migration.TableName })},
+	{"KAT_MIGRATION_DIRECTORY", "migration.directory", stringField(func(cfg *types.Config) *string { return &cfg.Migration.Directory })},
+	{"KAT_MIGRATION_TEMPLATES", "migration.templates", stringField(func(cfg *types.Config) *string { return &cfg.Migration.Templates })},
+	{"KAT_MIGRATION_REQUIRE_SINGLE_HEAD", "migration.require_single_head", boolField(func(cfg *types.Config) *bool { return &cfg.Migration.RequireSingleHead })},
+	{"KAT_MIGRATION_ORPHANS", "migration.orphans", func(cfg *types.Config, value string) error {
+		cfg.Migration.Orphans = types.OrphanPolicy(value)
+		return nil
+	}},
+	{"KAT_MIGRATION_OUT_OF_ORDER", "migration.out_of_order", func(cfg *types.Config, value string) error {
+		cfg.Migration.OutOfOrder = types.OutOfOrderPolicy(value)
+		return nil
+	}},
+}
+
+func stringField(field func(cfg *types.Config) *string) func(*types.Config, string) error {
+	return func(cfg *types.Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+func intField(field func(cfg *types.Config) *int) func(*types.Config, string) error {
+	return func(cfg *types.Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.Newf("must be a number, got %q", value)
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func boolField(field func(cfg *types.Config) *bool) func(*types.Config, string) error {
+	return func(cfg *types.Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Newf("must be true or false, got %q", value)
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+// applyEnv overrides the fields of cfg with the KAT_* environment variables that are set,
+// and falls back to DATABASE_URL when neither the file nor those variables say how to
+// connect to the database. A variable for the password or URL also replaces the file or
+// command the configuration file gets it from. Every variable used is recorded in sources
+// under the key of its setting. It reports whether any variable was used.
+func applyEnv(cfg *types.Config, lookup func(string) (string, bool), sources map[string]string) (bool, error) {
+	var used bool
+	for _, v := range envVars {
+		value, ok := lookup(v.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := v.set(cfg, value); err != nil {
+			return false, errors.Wrapf(err, "invalid %s", v.name)
+		}
+		sources[v.key] = v.name
+		used = true
+	}
+
+	db := cfg.Database
+	if value, ok := lookup(databaseURLEnvVar); ok && value != "" && db.URL == "" && db.URLFile == "" && db.URLCommand == "" && db.Path == "" && db.Host == "" {
+		setDatabaseURL(&cfg.Database, value)
+		if cfg.Database.Path != "" {
+			sources["database.driver"] = databaseURLEnvVar
+			sources["database.path"] = databaseURLEnvVar
+		} else {
+			sources["database.url"] = databaseURLEnvVar
+		}
+		used = true
+	}
+	return used, nil
+}
+
+// setDatabaseURL configures the database connection from a URL. `sqlite:` URLs, as used by
+// other migration tools, select the SQLite driver with the rest of the URL as the path.
+func setDatabaseURL(db *types.DatabaseInfo, value string) {
+	if u, err := url.Parse(value); err == nil && (u.Scheme == "sqlite" || u.Scheme == "sqlite3") {
+		db.Driver = dbdriver.SqliteDriver
+		db.Path = strings.TrimPrefix(strings.TrimPrefix(value, u.Scheme+":"), "//")
+		return
+	}
+	db.URL = value
+}
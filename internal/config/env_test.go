@@ -0,0 +1,128 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             types.Config
+		env             map[string]string
+		expected        types.Config
+		expectedUsed    bool
+		expectedSources map[string]string
+		expectedErr     string
+	}{
+		{
+			name: "environment only",
+			env: map[string]string{
+				"KAT_DB_HOST":              "db.internal",
+				"KAT_DB_USER":              "kat",
+				"KAT_DB_MAX_OPEN_CONNS":    "4",
+				"KAT_MIGRATION_TABLE_NAME": "schema_log",
+			},
+			expected: types.Config{
+				Migration: types.MigrationInfo{TableName: "schema_log"},
+				Database:  types.DatabaseInfo{Host: "db.internal", User: "kat", MaxOpenConns: 4},
+			},
+			expectedUsed: true,
+			expectedSources: map[string]string{
+				"database.host":           "KAT_DB_HOST",
+				"database.user":           "KAT_DB_USER",
+				"database.max_open_conns": "KAT_DB_MAX_OPEN_CONNS",
+				"migration.tablename":     "KAT_MIGRATION_TABLE_NAME",
+			},
+		},
+		{
+			name: "variables override the file",
+			cfg: types.Config{
+				Database: types.DatabaseInfo{URL: "postgres://localhost/dev", StatementTimeout: "1m"},
+			},
+			env: map[string]string{
+				"KAT_MIGRATION_DATABASE_URL": "postgres://db.internal/prod",
+				"DATABASE_URL":               "postgres://ignored/db",
+			},
+			expected: types.Config{
+				Database: types.DatabaseInfo{URL: "postgres://db.internal/prod", StatementTimeout: "1m"},
+			},
+			expectedUsed: true,
+		},
+		{
+			name: "DATABASE_URL is a fallback",
+			cfg:  types.Config{Database: types.DatabaseInfo{Path: "dev.db", Driver: dbdriver.SqliteDriver}},
+			env:  map[string]string{"DATABASE_URL": "postgres://db.internal/prod"},
+			expected: types.Config{
+				Database: types.DatabaseInfo{Path: "dev.db", Driver: dbdriver.SqliteDriver},
+			},
+		},
+		{
+			name: "sqlite DATABASE_URL",
+			env:  map[string]string{"DATABASE_URL": "sqlite:///var/lib/app.db"},
+			expected: types.Config{
+				Database: types.DatabaseInfo{Driver: dbdriver.SqliteDriver, Path: "/var/lib/app.db"},
+			},
+			expectedUsed:    true,
+			expectedSources: map[string]string{"database.driver": "DATABASE_URL", "database.path": "DATABASE_URL"},
+		},
+		{
+			name:        "invalid driver",
+			env:         map[string]string{"KAT_DRIVER_NAME": "mysql"},
+			expectedErr: "invalid KAT_DRIVER_NAME: unsupported database driver: mysql",
+		},
+		{
+			name:        "invalid boolean",
+			env:         map[string]string{"KAT_MIGRATION_REQUIRE_SINGLE_HEAD": "yes please"},
+			expectedErr: `invalid KAT_MIGRATION_REQUIRE_SINGLE_HEAD: must be true or false, got "yes please"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			sources := map[string]string{}
+			used, err := applyEnv(&cfg, func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}, sources)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedUsed, used)
+			require.Equal(t, tt.expected, cfg)
+			if tt.expectedSources != nil {
+				require.Equal(t, tt.expectedSources, sources)
+			}
+		})
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	vars, err := parseEnvFile(`# database
+export KAT_DB_HOST=db.internal # the primary
+KAT_DB_PASSWORD='p#ss word'
+KAT_DB_NAME="app\n\"prod\""
+
+EMPTY=
+`)
+	require.NoError(t, err)
+	require.Equal(t, [][2]string{
+		{"KAT_DB_HOST", "db.internal"},
+		{"KAT_DB_PASSWORD", "p#ss word"},
+		{"KAT_DB_NAME", "app\n\"prod\""},
+		{"EMPTY", ""},
+	}, vars)
+
+	_, err = parseEnvFile("KAT_DB_HOST\n")
+	require.EqualError(t, err, "line 1: expected KEY=value")
+
+	_, err = parseEnvFile("KAT_DB_NAME=\"app\n")
+	require.EqualError(t, err, "line 1: unterminated double-quoted value")
+}
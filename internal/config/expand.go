@@ -0,0 +1,182 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// bareReference matches a value that is only a `$VAR` reference. Older versions of kat
+// expanded these; they are now kept as written so a `$` in a password stays intact.
+var bareReference = regexp.MustCompile(`^\$[A-Za-z_][A-Za-z0-9_]*$`)
+
+// bareReferences returns the values of a parsed YAML document, before expansion, that
+// look like a bare `$VAR` reference written for older versions of kat.
+func bareReferences(node *yaml.Node) []*yaml.Node {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		var found []*yaml.Node
+		for _, child := range node.Content {
+			found = append(found, bareReferences(child)...)
+		}
+		return found
+	case yaml.MappingNode:
+		var found []*yaml.Node
+		for i := 1; i < len(node.Content); i += 2 {
+			found = append(found, bareReferences(node.Content[i])...)
+		}
+		return found
+	case yaml.ScalarNode:
+		if bareReference.MatchString(node.Value) {
+			return []*yaml.Node{node}
+		}
+	}
+	return nil
+}
+
+// expandNode expands environment variable references in the values of a parsed YAML
+// document. Keys are left alone, so variables can't change the document's structure.
+func expandNode(node *yaml.Node, lookup func(string) (string, bool)) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := expandNode(child, lookup); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandNode(node.Content[i], lookup); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		value, err := expandEnv(node.Value, lookup)
+		if err != nil {
+			return errors.Wrapf(err, "line %d", node.Line)
+		}
+		if value != node.Value {
+			node.Value = value
+			// Let unquoted values resolve to the type of the expanded text, so
+			// `max_open_conns: ${MAX_CONNS}` still decodes into a number.
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces the ${...} references in s with values from lookup, like a shell:
+//
+//	${VAR}           the value of VAR; an error if VAR is unset
+//	${VAR:-default}  default if VAR is unset or empty
+//	${VAR-default}   default if VAR is unset
+//	${VAR:?message}  an error with message if VAR is unset or empty
+//	${VAR?message}   an error with message if VAR is unset
+//
+// Defaults can contain references themselves. A `$` that doesn't start a reference is
+// kept as it is, and `$${` writes a literal `${`.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "$")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+
+		switch {
+		case strings.HasPrefix(s, "$${"):
+			b.WriteString("${")
+			s = s[3:]
+		case strings.HasPrefix(s, "${"):
+			end := closingBrace(s)
+			if end < 0 {
+				return "", errors.Newf("unterminated reference %q", s)
+			}
+			value, err := expandReference(s[2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			s = s[end+1:]
+		default:
+			b.WriteString("$")
+			s = s[1:]
+		}
+	}
+}
+
+// closingBrace returns the index of the brace that closes the reference at the start of
+// s, taking nested references into account, or -1 if there is none.
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expandReference expands the text between ${ and }.
+func expandReference(ref string, lookup func(string) (string, bool)) (string, error) {
+	name, op, word := ref, "", ""
+	if i := strings.IndexAny(ref, ":-?"); i >= 0 {
+		name, op, word = ref[:i], ref[i:i+1], ref[i+1:]
+		if op == ":" {
+			if word == "" || (word[0] != '-' && word[0] != '?') {
+				return "", errors.Newf("invalid reference ${%s}: expected :- or :? after the variable name", ref)
+			}
+			op, word = ref[i:i+2], word[1:]
+		}
+	}
+	if !validEnvName(name) {
+		return "", errors.Newf("invalid reference ${%s}: invalid variable name %q", ref, name)
+	}
+
+	value, ok := lookup(name)
+	missing := !ok || (strings.HasPrefix(op, ":") && value == "")
+	switch {
+	case op == "" && !ok:
+		return "", errors.Newf("environment variable %s is not set; use ${%s:-} to default to an empty value", name, name)
+	case !missing:
+		return value, nil
+	case strings.HasSuffix(op, "-"):
+		return expandEnv(word, lookup)
+	default:
+		message, err := expandEnv(word, lookup)
+		if err != nil {
+			return "", err
+		}
+		if message == "" {
+			message = "is required"
+		}
+		return "", errors.Newf("environment variable %s: %s", name, message)
+	}
+}
+
+// validEnvName reports whether name is a valid shell variable name.
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
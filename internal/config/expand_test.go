@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{
+		"DB_USER": "kat",
+		"EMPTY":   "",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{name: "set variable", input: "user=${DB_USER}", expected: "user=kat"},
+		{name: "bare dollar is literal", input: "pa$$word$DB_USER", expected: "pa$$word$DB_USER"},
+		{name: "escaped reference", input: "$${DB_USER}", expected: "${DB_USER}"},
+		{name: "empty variable", input: "${EMPTY}", expected: ""},
+		{name: "default when unset", input: "${DB_HOST:-localhost}", expected: "localhost"},
+		{name: "default when empty", input: "${EMPTY:-fallback}", expected: "fallback"},
+		{name: "default only when unset", input: "${EMPTY-fallback}", expected: ""},
+		{name: "nested default", input: "${DB_HOST:-${DB_USER}.internal}", expected: "kat.internal"},
+		{name: "required and set", input: "${DB_USER:?set DB_USER}", expected: "kat"},
+		{name: "unset variable", input: "${DB_PASSWORD}", expectedErr: "environment variable DB_PASSWORD is not set; use ${DB_PASSWORD:-} to default to an empty value"},
+		{name: "required variable", input: "${DB_PASSWORD:?mount the secret}", expectedErr: "environment variable DB_PASSWORD: mount the secret"},
+		{name: "required without message", input: "${EMPTY:?}", expectedErr: "environment variable EMPTY: is required"},
+		{name: "unterminated", input: "${DB_USER", expectedErr: `unterminated reference "${DB_USER"`},
+		{name: "invalid name", input: "${1DB}", expectedErr: `invalid reference ${1DB}: invalid variable name "1DB"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := expandEnv(tt.input, lookup)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestExpandNode(t *testing.T) {
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`
+database:
+  password: "${DB_PASSWORD}"
+  max_open_conns: ${MAX_CONNS:-4}
+  ${KEY}: value
+`), &doc))
+
+	lookup := func(name string) (string, bool) {
+		value, ok := map[string]string{"DB_PASSWORD": "s3cr$t", "KEY": "password"}[name]
+		return value, ok
+	}
+	require.NoError(t, expandNode(&doc, lookup))
+
+	var out struct {
+		Database map[string]any `yaml:"database"`
+	}
+	require.NoError(t, doc.Decode(&out))
+	require.Equal(t, map[string]any{
+		"password":       "s3cr$t",
+		"max_open_conns": 4,
+		"${KEY}":         "value",
+	}, out.Database)
+
+	require.NoError(t, yaml.Unmarshal([]byte("database:\n  password: ${DB_USER}\n"), &doc))
+	require.EqualError(t, expandNode(&doc, lookup), "line 2: environment variable DB_USER is not set; use ${DB_USER:-} to default to an empty value")
+}
+
+func TestBareReferences(t *testing.T) {
+	var doc yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`
+database:
+  user: $DB_USER
+  password: pa$$word
+  name: ${DB_NAME}
+  host: $1host
+migration:
+  directories:
+    - path: $MIGRATIONS_DIR
+`), &doc))
+
+	var found []string
+	for _, node := range bareReferences(&doc) {
+		found = append(found, node.Value)
+	}
+	require.Equal(t, []string{"$DB_USER", "$MIGRATIONS_DIR"}, found)
+}
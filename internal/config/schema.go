@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+
+	"github.com/BolajiOlajide/kat/schemas"
+)
+
+// schemaURL is the URL the configuration schema is registered under. It matches the
+// schema's $id.
+const schemaURL = "https://github.com/BolajiOlajide/kat/schemas/kat.conf.schema.json"
+
+var compileSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schemas.Config))
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(schemaURL, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(schemaURL)
+})
+
+// SchemaProblem is a part of a configuration file that doesn't match the schema.
+type SchemaProblem struct {
+	// Key is the dotted path of the offending setting, such as database.port, or empty
+	// for the whole file.
+	Key     string
+	Line    int
+	Message string
+}
+
+// ValidateSchema checks a configuration file against schemas/kat.conf.schema.json after
+// expanding its environment variable references, and returns every mismatch.
+func ValidateSchema(content []byte, lookup func(string) (string, bool)) ([]SchemaProblem, error) {
+	schema, err := compileSchema()
+	if err != nil {
+		return nil, errors.Wrap(err, "compiling the configuration schema")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if err := expandNode(&doc, lookup); err != nil {
+		return nil, err
+	}
+	var value any = map[string]any{}
+	if doc.Kind != 0 {
+		if err := doc.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	// The validator expects the types encoding/json produces.
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	var verr *jsonschema.ValidationError
+	if err := schema.Validate(instance); !errors.As(err, &verr) {
+		return nil, err
+	}
+	var problems []SchemaProblem
+	collectSchemaProblems(verr, &doc, &problems)
+	return problems, nil
+}
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// collectSchemaProblems adds a problem for every failed assertion under verr. Failed
+// oneOf and anyOf assertions are reported once, as the reasons each alternative failed
+// are rarely useful.
+func collectSchemaProblems(verr *jsonschema.ValidationError, doc *yaml.Node, problems *[]SchemaProblem) {
+	switch verr.ErrorKind.(type) {
+	case *kind.OneOf, *kind.AnyOf:
+	default:
+		if len(verr.Causes) > 0 {
+			for _, cause := range verr.Causes {
+				collectSchemaProblems(cause, doc, problems)
+			}
+			return
+		}
+	}
+	message := verr.ErrorKind.LocalizedString(schemaPrinter)
+	if _, ok := verr.ErrorKind.(*kind.OneOf); ok {
+		if alternatives := schemaAlternatives(verr.SchemaURL); len(alternatives) > 0 {
+			message = "must match exactly one of: " + strings.Join(alternatives, "; ")
+		}
+	}
+	*problems = append(*problems, SchemaProblem{
+		Key:     strings.Join(verr.InstanceLocation, "."),
+		Line:    nodeLine(doc, verr.InstanceLocation),
+		Message: message,
+	})
+}
+
+// schemaAlternatives returns the descriptions of the oneOf alternatives of the schema at
+// location, a URL whose fragment is a JSON pointer into the configuration schema.
+func schemaAlternatives(location string) []string {
+	_, pointer, _ := strings.Cut(location, "#")
+	var node any
+	if err := json.Unmarshal(schemas.Config, &node); err != nil {
+		return nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = m[strings.NewReplacer("~1", "/", "~0", "~").Replace(token)]
+	}
+
+	m, _ := node.(map[string]any)
+	branches, _ := m["oneOf"].([]any)
+	var descriptions []string
+	for _, branch := range branches {
+		if b, ok := branch.(map[string]any); ok {
+			if d, ok := b["description"].(string); ok {
+				descriptions = append(descriptions, d)
+			}
+		}
+	}
+	return descriptions
+}
+
+// nodeLine returns the line of the value at path in doc, or of its closest ancestor.
+func nodeLine(doc *yaml.Node, path []string) int {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					next = node.Content[i+1]
+				}
+			}
+		case yaml.SequenceNode:
+			var idx int
+			if _, err := fmt.Sscan(key, &idx); err == nil && idx < len(node.Content) {
+				next = node.Content[idx]
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return node.Line
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		env      map[string]string
+		expected []SchemaProblem
+	}{
+		{
+			name: "valid",
+			content: `migration:
+  tablename: migrations
+  directory: migrations
+database:
+  host: ${DB_HOST}
+  port: 5432
+  max_open_conns: ${MAX_CONNS}
+`,
+			env: map[string]string{"DB_HOST": "localhost", "MAX_CONNS": "4"},
+		},
+		{
+			name: "wrong type and unknown field",
+			content: `migration:
+  tablename: migrations
+  directory: migrations
+  directories:
+    - namespace: core
+database:
+  driver: mysql
+`,
+			expected: []SchemaProblem{
+				{Key: "database.driver", Line: 7, Message: "value must be one of 'postgres', 'sqlite'"},
+				{Key: "migration.directories.0", Line: 5, Message: "missing property 'path'"},
+			},
+		},
+		{
+			name: "url and credentials",
+			content: `database:
+  url: postgres://localhost/app
+  host: localhost
+`,
+			expected: []SchemaProblem{
+				{Key: "database", Line: 2, Message: "must match exactly one of: Connect using a PostgreSQL connection URL; Connect using individual database credentials"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := ValidateSchema([]byte(tt.content), func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			})
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.expected, problems)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"io"
+	"maps"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// redacted replaces secret values in the output of `kat config show`.
+const redacted = "********"
+
+// defaultSource is the source of settings that no file, variable or flag sets.
+const defaultSource = "default"
+
+// Setting is a setting of a resolved configuration and where its value came from.
+type Setting struct {
+	Key    string
+	Value  any
+	Source string
+}
+
+// Settings returns every setting of the resolved configuration with secrets redacted, in
+// the order they appear in the configuration file format.
+func (r *Resolved) Settings() ([]Setting, error) {
+	doc, err := r.redactedNode()
+	if err != nil {
+		return nil, err
+	}
+
+	var settings []Setting
+	var decodeErr error
+	walkSettings(doc, func(key string, _, value *yaml.Node) {
+		var v any
+		if err := value.Decode(&v); err != nil && decodeErr == nil {
+			decodeErr = err
+		}
+		settings = append(settings, Setting{Key: key, Value: v, Source: r.source(key)})
+	})
+	return settings, decodeErr
+}
+
+// WriteAnnotated writes the resolved configuration as YAML with secrets redacted and the
+// source of every setting in a comment next to it.
+func (r *Resolved) WriteAnnotated(w io.Writer) error {
+	doc, err := r.redactedNode()
+	if err != nil {
+		return err
+	}
+	walkSettings(doc, func(key string, keyNode, value *yaml.Node) {
+		// Comments on a list go after its key; on a scalar, after its value.
+		if value.Kind == yaml.ScalarNode {
+			value.LineComment = r.source(key)
+		} else {
+			keyNode.LineComment = r.source(key)
+		}
+	})
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (r *Resolved) source(key string) string {
+	if source, ok := r.Sources[key]; ok {
+		return source
+	}
+	return defaultSource
+}
+
+func (r *Resolved) redactedNode() (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := doc.Encode(redact(r.Config)); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// redact returns cfg with the database password, the password in the database URL and
+// the tracing headers, which often carry API keys, replaced.
+func redact(cfg types.Config) types.Config {
+	if cfg.Database.Password != "" {
+		cfg.Database.Password = redacted
+	}
+	if cfg.Database.URL != "" {
+		if u, err := url.Parse(cfg.Database.URL); err == nil {
+			cfg.Database.URL = u.Redacted()
+		} else {
+			cfg.Database.URL = redacted
+		}
+	}
+	if len(cfg.Tracing.Headers) > 0 {
+		cfg.Tracing.Headers = maps.Clone(cfg.Tracing.Headers)
+		for name := range cfg.Tracing.Headers {
+			cfg.Tracing.Headers[name] = redacted
+		}
+	}
+	return cfg
+}
@@ -10,11 +10,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"math/rand/v2"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/errors"
@@ -42,6 +44,10 @@ type DBConfig struct {
 
 	// Default timeouts for operations
 	DefaultTimeout time.Duration // Default timeout for operations without explicit context deadline
+
+	// ConnString, when set, builds the connection string for every connection opened
+	// after the first, so credentials that expire are renewed when the pool reconnects.
+	ConnString func() (string, error)
 }
 
 // DefaultDBConfig returns sensible default configuration for Kat migrations
@@ -69,7 +75,7 @@ func DefaultDBConfig(drv dbdriver.DatabaseDriver) DBConfig {
 
 type database struct {
 	db     *sql.DB
-	logger loggr.Logger
+	logger loggr.StructuredLogger
 	config DBConfig
 	driver dbdriver.DatabaseDriver
 }
@@ -126,11 +132,16 @@ func (d *database) Ping(ctx context.Context) error {
 }
 
 func (d *database) Exec(ctx context.Context, query *sqlf.Query) error {
+	_, err := d.ExecResult(ctx, query)
+	return err
+}
+
+// ExecResult executes query and returns its result, which reports the rows affected.
+func (d *database) ExecResult(ctx context.Context, query *sqlf.Query) (sql.Result, error) {
 	ctx, cancel := d.withDefaultTimeout(ctx, d.config.DefaultTimeout)
 	defer cancel()
 
-	_, err := d.db.ExecContext(ctx, query.Query(d.driver.BindVar()), query.Args()...)
-	return err
+	return d.db.ExecContext(ctx, query.Query(d.driver.BindVar()), query.Args()...)
 }
 
 func (d *database) QueryRow(ctx context.Context, query *sqlf.Query) *sql.Row {
@@ -238,7 +249,7 @@ func isTransientError(err error) bool {
 }
 
 // withRetry executes a function with retries for transient errors
-func withRetry(ctx context.Context, l loggr.Logger, retryCount int, initialDelay int, f func(context.Context) error) error {
+func withRetry(ctx context.Context, l loggr.StructuredLogger, retryCount int, initialDelay int, f func(context.Context) error) error {
 	// Validate retry parameters
 	if retryCount < 1 {
 		retryCount = 1 // Minimum 1 retry
@@ -271,7 +282,8 @@ func withRetry(ctx context.Context, l loggr.Logger, retryCount int, initialDelay
 
 		// Don't sleep on the last attempt
 		if attempt < retryCount {
-			l.Error(fmt.Sprintf("Transient error detected: %s. Retrying in %v (attempt %d/%d)...", err.Error(), delay, attempt+1, retryCount))
+			l.Error(fmt.Sprintf("Transient error detected: %s. Retrying in %v (attempt %d/%d)...", err.Error(), delay, attempt+1, retryCount),
+				loggr.KeyError, err, "attempt", attempt+1, "retry_in", delay)
 
 			// Add jitter to prevent thundering herd
 			jitter := time.Duration(rand.Int64N(int64(delay / 4)))
@@ -344,7 +356,7 @@ func ensureTimeoutsInKeyValueDSN(dsn string, connectTimeout, statementTimeout ti
 }
 
 // NewWithConfig returns a new database instance with custom configuration
-func NewWithConfig(dd dbdriver.DatabaseDriver, url string, logger loggr.Logger, config DBConfig) (DB, error) {
+func NewWithConfig(dd dbdriver.DatabaseDriver, url string, logger loggr.StructuredLogger, config DBConfig) (DB, error) {
 	var err error
 	finalURL := url
 	// Only apply DSN timeout enrichment for PostgreSQL
@@ -355,7 +367,12 @@ func NewWithConfig(dd dbdriver.DatabaseDriver, url string, logger loggr.Logger,
 		}
 	}
 
-	db, err := sql.Open(dd.DriverName(), finalURL)
+	var db *sql.DB
+	if config.ConnString != nil {
+		db, err = openRefreshing(dd, finalURL, config)
+	} else {
+		db, err = sql.Open(dd.DriverName(), finalURL)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -392,7 +409,8 @@ func NewWithConfig(dd dbdriver.DatabaseDriver, url string, logger loggr.Logger,
 	}
 
 	logger.Info(fmt.Sprintf("Database connection established - Pool: max_open=%d, max_idle=%d, max_lifetime=%v",
-		config.MaxOpenConns, config.MaxIdleConns, config.ConnMaxLifetime))
+		config.MaxOpenConns, config.MaxIdleConns, config.ConnMaxLifetime),
+		loggr.KeyDriver, dd.String(), "max_open_conns", config.MaxOpenConns, "max_idle_conns", config.MaxIdleConns, "conn_max_lifetime", config.ConnMaxLifetime)
 
 	d := &database{
 		db:     db,
@@ -402,18 +420,81 @@ func NewWithConfig(dd dbdriver.DatabaseDriver, url string, logger loggr.Logger,
 	}
 
 	if config.StatementTimeout > 0 {
-		logger.Info(fmt.Sprintf("Session statement timeout configured for %v", config.StatementTimeout))
+		logger.Info(fmt.Sprintf("Session statement timeout configured for %v", config.StatementTimeout),
+			loggr.KeyDriver, dd.String(), "statement_timeout", config.StatementTimeout)
 	}
 
 	return d, nil
 }
 
+// openRefreshing opens a pool whose first connection uses connStr and whose later
+// connections use a connection string freshly built by config.ConnString.
+func openRefreshing(dd dbdriver.DatabaseDriver, connStr string, config DBConfig) (*sql.DB, error) {
+	// sql.Open doesn't connect, it only looks up the registered driver.
+	db, err := sql.Open(dd.DriverName(), connStr)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	db.Close()
+
+	return sql.OpenDB(&refreshingConnector{
+		driver:  drv,
+		connStr: connStr,
+		refresh: func() (string, error) {
+			connStr, err := config.ConnString()
+			if err != nil || !dd.IsPostgres() {
+				return connStr, err
+			}
+			return ensureTimeoutsInDSN(connStr, config.ConnectTimeout, config.StatementTimeout)
+		},
+	}), nil
+}
+
+// refreshingConnector is a driver.Connector that rebuilds the connection string for
+// every connection but the first.
+type refreshingConnector struct {
+	driver  driver.Driver
+	refresh func() (string, error)
+
+	mu      sync.Mutex
+	connStr string
+	used    bool
+}
+
+func (c *refreshingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	connStr, used := c.connStr, c.used
+	c.used = true
+	c.mu.Unlock()
+
+	if used {
+		var err error
+		if connStr, err = c.refresh(); err != nil {
+			return nil, errors.Wrap(err, "refreshing database credentials")
+		}
+	}
+
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(connStr)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.driver.Open(connStr)
+}
+
+func (c *refreshingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
 // New returns a new instance of the database with default configuration
-func New(drv dbdriver.DatabaseDriver, url string, logger loggr.Logger) (DB, error) {
+func New(drv dbdriver.DatabaseDriver, url string, logger loggr.StructuredLogger) (DB, error) {
 	return NewWithConfig(drv, url, logger, DefaultDBConfig(drv))
 }
 
-func NewWithDB(db *sql.DB, driver dbdriver.DatabaseDriver, logger loggr.Logger) (DB, error) {
+func NewWithDB(db *sql.DB, driver dbdriver.DatabaseDriver, logger loggr.StructuredLogger) (DB, error) {
 	// SQLite allows only one writer at a time. Enforce this on externally
 	// supplied connections to prevent "database is locked" errors.
 	if driver.IsSQLite() {
@@ -13,6 +13,7 @@ type DB interface {
 	Ping(context.Context) error
 	PingWithRetry(context.Context, int, int) error // Only used by ping command
 	Exec(context.Context, *sqlf.Query) error
+	ExecResult(context.Context, *sqlf.Query) (sql.Result, error)
 	QueryRow(context.Context, *sqlf.Query) *sql.Row
 	Query(context.Context, *sqlf.Query) (*sql.Rows, error)
 	Driver() dbdriver.DatabaseDriver
@@ -30,3 +31,23 @@ type Tx interface {
 	Commit() error
 	Rollback() error
 }
+
+// SQLTx returns the *sql.Tx behind tx, or false if tx is not backed by a database/sql
+// transaction.
+func SQLTx(tx Tx) (*sql.Tx, bool) {
+	dtx, ok := tx.(*databaseTx)
+	if !ok {
+		return nil, false
+	}
+	return dtx.tx, true
+}
+
+// SQLDB returns the *sql.DB behind db, or false if db is not backed by a database/sql
+// connection pool.
+func SQLDB(db DB) (*sql.DB, bool) {
+	d, ok := db.(*database)
+	if !ok {
+		return nil, false
+	}
+	return d.db, true
+}
@@ -153,3 +153,34 @@ func TestUnsupportedDriver(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported database driver: mysql")
 }
+
+func TestSQLiteConnStringRefresh(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Without idle connections every query opens a new connection.
+	config := DefaultDBConfig(dbdriver.SqliteDriver)
+	config.MaxIdleConns = 0
+	var refreshes int
+	config.ConnString = func() (string, error) {
+		refreshes++
+		return dbPath, nil
+	}
+
+	db, err := NewWithConfig(dbdriver.SqliteDriver, dbPath, loggr.NewDefault(), config)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, 0, refreshes, "the first connection uses the initial connection string")
+
+	require.NoError(t, db.Exec(context.Background(), sqlf.Sprintf("CREATE TABLE test_table (id INTEGER PRIMARY KEY)")))
+	require.NoError(t, db.Exec(context.Background(), sqlf.Sprintf("INSERT INTO test_table DEFAULT VALUES")))
+	assert.Equal(t, 2, refreshes)
+
+	config.ConnString = func() (string, error) {
+		return "", fmt.Errorf("token expired")
+	}
+	db, err = NewWithConfig(dbdriver.SqliteDriver, dbPath, loggr.NewDefault(), config)
+	require.NoError(t, err)
+	defer db.Close()
+	err = db.Exec(context.Background(), sqlf.Sprintf("SELECT 1"))
+	require.ErrorContains(t, err, "refreshing database credentials: token expired")
+}
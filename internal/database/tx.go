@@ -46,11 +46,16 @@ func (d *databaseTx) withDefaultTimeout(ctx context.Context, timeout time.Durati
 }
 
 func (d *databaseTx) Exec(ctx context.Context, query *sqlf.Query) error {
+	_, err := d.ExecResult(ctx, query)
+	return err
+}
+
+// ExecResult executes query and returns its result, which reports the rows affected.
+func (d *databaseTx) ExecResult(ctx context.Context, query *sqlf.Query) (sql.Result, error) {
 	ctx, cancel := d.withDefaultTimeout(ctx, d.config.DefaultTimeout)
 	defer cancel()
 
-	_, err := d.tx.ExecContext(ctx, query.Query(d.driver.BindVar()), query.Args()...)
-	return err
+	return d.tx.ExecContext(ctx, query.Query(d.driver.BindVar()), query.Args()...)
 }
 
 func (d *databaseTx) QueryRow(ctx context.Context, query *sqlf.Query) *sql.Row {
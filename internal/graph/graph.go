@@ -40,23 +40,55 @@ type Graph struct {
 }
 
 func (g *Graph) AddDefinition(def types.Definition) error {
-	if err := g.graph.AddVertex(def, graphlib.VertexAttribute("name", def.FileName())); err != nil {
-		return errors.Wrap(err, "error adding vertex")
+	if err := g.addVertex(def); err != nil {
+		return err
 	}
 
 	// Then we define the relationship with its parent by adding its edges.
+	return g.addEdges(def)
+}
+
+func (g *Graph) addVertex(def types.Definition) error {
+	if err := g.graph.AddVertex(def, graphlib.VertexAttributes(vertexAttributes(def))); err != nil {
+		return errors.Wrap(err, "error adding vertex")
+	}
+	return nil
+}
+
+func (g *Graph) addEdges(def types.Definition) error {
 	for _, parent := range def.Parents {
 		if err := g.graph.AddEdge(parent, def.Timestamp); err != nil {
 			return errors.Wrapf(err, "error adding edge for parent: %d", parent)
 		}
 	}
-
 	return nil
 }
 
+// vertexAttributes returns the DOT attributes used to render a definition when the graph is drawn.
+func vertexAttributes(def types.Definition) map[string]string {
+	attrs := map[string]string{"name": def.QualifiedName()}
+	if def.Namespace != "" {
+		// Show which migrations directory the migration comes from.
+		attrs["label"] = def.QualifiedName()
+	}
+	if def.Irreversible {
+		// Highlight irreversible migrations so they stand out in the exported graph.
+		attrs["color"] = "red"
+		attrs["xlabel"] = "irreversible"
+	}
+	return attrs
+}
+
+// AddDefinitions adds every definition to the graph before connecting any of them to their
+// parents, so a parent doesn't have to come before its children in defs.
 func (g *Graph) AddDefinitions(defs ...types.Definition) error {
 	for _, def := range defs {
-		if err := g.AddDefinition(def); err != nil {
+		if err := g.addVertex(def); err != nil {
+			return err
+		}
+	}
+	for _, def := range defs {
+		if err := g.addEdges(def); err != nil {
 			return err
 		}
 	}
@@ -89,6 +121,12 @@ func (g *Graph) GetDefinition(timestamp int64) (types.Definition, error) {
 	return g.graph.Vertex(timestamp)
 }
 
+// Has reports whether the graph contains a definition with the given timestamp.
+func (g *Graph) Has(timestamp int64) bool {
+	_, err := g.graph.Vertex(timestamp)
+	return err == nil
+}
+
 // TopologicalSort returns a valid topological ordering of all the vertices in the graph.
 // It uses StableTopologicalSort from the graph library to ensure that elements with
 // valid topological ordering are consistently returned in order of their timestamps (i < j),
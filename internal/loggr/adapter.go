@@ -0,0 +1,41 @@
+package loggr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// messageLogger adapts a message-only Logger to the StructuredLogger interface by
+// appending the key/value pairs to the message as "key=value".
+type messageLogger struct {
+	l Logger
+}
+
+// FromLogger wraps a message-only Logger so it can receive structured log records.
+func FromLogger(l Logger) StructuredLogger {
+	return &messageLogger{l: l}
+}
+
+func (m *messageLogger) Debug(msg string, args ...any) { m.l.Debug(formatMessage(msg, args)) }
+func (m *messageLogger) Info(msg string, args ...any)  { m.l.Info(formatMessage(msg, args)) }
+func (m *messageLogger) Warn(msg string, args ...any)  { m.l.Warn(formatMessage(msg, args)) }
+func (m *messageLogger) Error(msg string, args ...any) { m.l.Error(formatMessage(msg, args)) }
+
+// formatMessage renders key/value pairs after the message. A trailing key without a
+// value is rendered with the "!BADKEY" marker, the same way log/slog handles it.
+func formatMessage(msg string, args []any) string {
+	if len(args) == 0 {
+		return msg
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			fmt.Fprintf(&sb, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+	}
+	return sb.String()
+}
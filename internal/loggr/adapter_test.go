@@ -0,0 +1,50 @@
+package loggr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (r *recordingLogger) Debug(msg string) { r.messages = append(r.messages, "DEBUG: "+msg) }
+func (r *recordingLogger) Info(msg string)  { r.messages = append(r.messages, "INFO: "+msg) }
+func (r *recordingLogger) Warn(msg string)  { r.messages = append(r.messages, "WARN: "+msg) }
+func (r *recordingLogger) Error(msg string) { r.messages = append(r.messages, "ERROR: "+msg) }
+
+func TestFromLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      func(l StructuredLogger)
+		expected string
+	}{
+		{
+			name:     "message without fields",
+			log:      func(l StructuredLogger) { l.Info("No migration(s) to apply.") },
+			expected: "INFO: No migration(s) to apply.",
+		},
+		{
+			name: "message with fields",
+			log: func(l StructuredLogger) {
+				l.Debug("Executed migration", KeyMigration, "1747578808_init", KeyOperation, "up")
+			},
+			expected: "DEBUG: Executed migration migration=1747578808_init operation=up",
+		},
+		{
+			name:     "dangling key",
+			log:      func(l StructuredLogger) { l.Error("Migration failed", KeyMigration) },
+			expected: "ERROR: Migration failed !BADKEY=migration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingLogger{}
+			tt.log(FromLogger(rec))
+			require.Equal(t, []string{tt.expected}, rec.messages)
+		})
+	}
+}
@@ -6,28 +6,42 @@ import (
 	"github.com/BolajiOlajide/kat/internal/output"
 )
 
-type defaultLogger struct{}
+// defaultLogger prints coloured messages to stdout. It is used by the CLI, where the
+// messages are meant for humans, so structured fields are not rendered.
+type defaultLogger struct {
+	verbose bool
+}
 
-func NewDefault() Logger {
+// NewDefault returns the coloured stdout logger used by the kat CLI. It drops debug
+// messages.
+func NewDefault() StructuredLogger {
 	return &defaultLogger{}
 }
 
+// NewVerbose returns the coloured stdout logger used by the kat CLI with --verbose. Unlike
+// NewDefault, it prints debug messages too.
+func NewVerbose() StructuredLogger {
+	return &defaultLogger{verbose: true}
+}
+
 func (d *defaultLogger) print(style output.Style, msg string) {
 	fmt.Printf("%s%s%s\n", style, msg, output.StyleReset)
 }
 
-func (d *defaultLogger) Debug(msg string) {
-	d.print(output.StyleSuggestion, msg)
+func (d *defaultLogger) Debug(msg string, _ ...any) {
+	if d.verbose {
+		d.print(output.StyleSuggestion, msg)
+	}
 }
 
-func (d *defaultLogger) Info(msg string) {
+func (d *defaultLogger) Info(msg string, _ ...any) {
 	d.print(output.StyleInfo, msg)
 }
 
-func (d *defaultLogger) Warn(msg string) {
+func (d *defaultLogger) Warn(msg string, _ ...any) {
 	d.print(output.StyleWarning, msg)
 }
 
-func (d *defaultLogger) Error(msg string) {
+func (d *defaultLogger) Error(msg string, _ ...any) {
 	d.print(output.StyleFailure, msg)
 }
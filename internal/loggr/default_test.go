@@ -0,0 +1,52 @@
+package loggr
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestDefaultLoggerDebug(t *testing.T) {
+	tests := []struct {
+		name      string
+		logger    StructuredLogger
+		wantDebug bool
+	}{
+		{name: "default", logger: NewDefault()},
+		{name: "verbose", logger: NewVerbose(), wantDebug: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStdout(t, func() {
+				tt.logger.Debug("Executed up migration")
+				tt.logger.Info("Total: 1 migration(s) applied.")
+			})
+			require.Contains(t, out, "Total: 1 migration(s) applied.")
+			if tt.wantDebug {
+				require.Contains(t, out, "Executed up migration")
+			} else {
+				require.NotContains(t, out, "Executed up migration")
+			}
+		})
+	}
+}
@@ -1,8 +1,30 @@
 package loggr
 
+// Logger is the message-only logging interface. Implementations that only support
+// plain messages can be used anywhere a StructuredLogger is expected via FromLogger.
 type Logger interface {
 	Debug(msg string)
 	Info(msg string)
 	Warn(msg string)
 	Error(msg string)
 }
+
+// StructuredLogger is a leveled logger that accepts alternating key/value pairs after
+// the message, following the conventions of log/slog. *slog.Logger implements it.
+type StructuredLogger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Keys used for the structured fields attached to log records.
+const (
+	KeyMigration  = "migration"
+	KeyOperation  = "operation"
+	KeyDuration   = "duration"
+	KeyDriver     = "driver"
+	KeyError      = "error"
+	KeyOrphans    = "orphans"
+	KeyOutOfOrder = "out_of_order"
+)
@@ -0,0 +1,14 @@
+package loggr
+
+type noopLogger struct{}
+
+// NewNoop returns a logger that discards everything. It is the default for library
+// users, so embedding kat doesn't write to the host application's stdout.
+func NewNoop() StructuredLogger {
+	return noopLogger{}
+}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
@@ -0,0 +1,44 @@
+// Package metrics defines the callbacks kat invokes while running migrations so that
+// callers can record durations, failures and backlog size in their metrics system.
+package metrics
+
+import "time"
+
+// Metrics receives measurements from migration runs. Implementations must be safe for
+// concurrent use if the same value is shared between Migration instances. Dry runs are
+// not reported because nothing is executed.
+type Metrics interface {
+	// MigrationsPending is called once at the start of every run with the number of
+	// migrations that have not been applied yet.
+	MigrationsPending(count int)
+
+	// MigrationStarted is called before a migration's SQL is executed. operation is
+	// "up" or "down".
+	MigrationStarted(name, operation string)
+
+	// MigrationCompleted is called after a migration and its tracking table update
+	// succeeded.
+	MigrationCompleted(name, operation string, duration time.Duration)
+
+	// MigrationFailed is called when a migration fails. duration is the time spent
+	// before the failure.
+	MigrationFailed(name, operation string, duration time.Duration, err error)
+
+	// LockWaited reports how long a migration waited for its transaction to start,
+	// which covers waiting for a pooled connection and for database locks taken by
+	// BEGIN, such as SQLite's write lock.
+	LockWaited(operation string, wait time.Duration)
+}
+
+type noopMetrics struct{}
+
+// NewNoop returns a Metrics implementation that discards everything.
+func NewNoop() Metrics {
+	return noopMetrics{}
+}
+
+func (noopMetrics) MigrationsPending(int)                                {}
+func (noopMetrics) MigrationStarted(string, string)                      {}
+func (noopMetrics) MigrationCompleted(string, string, time.Duration)     {}
+func (noopMetrics) MigrationFailed(string, string, time.Duration, error) {}
+func (noopMetrics) LockWaited(string, time.Duration)                     {}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,72 +12,196 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"github.com/BolajiOlajide/kat/internal/config"
-	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/graph"
 	"github.com/BolajiOlajide/kat/internal/types"
 )
 
 // Add creates a new directory with stub migration files in the given schema and returns the
 // names of the newly created files. If there was an error, the filesystem is rolled-back.
-func Add(c *cli.Context, name string) error {
+func Add(c *cli.Context, name string) (types.TemporaryMigrationInfo, error) {
 	cfg, err := config.GetKatConfigFromCtx(c)
 	if err != nil {
-		return err
+		return types.TemporaryMigrationInfo{}, err
 	}
 
-	timestamp := time.Now().UTC().Unix()
-	sanitizedName := nonAlphaNumericOrUnderscore.ReplaceAllString(
-		strings.ReplaceAll(strings.ToLower(name), " ", "_"), "",
-	)
-	migrationDirName := fmt.Sprintf("%d_%s", timestamp, sanitizedName)
+	sanitizedName := sanitizeName(name)
 
-	m := types.TemporaryMigrationInfo{
-		Up:        filepath.Join(cfg.Migration.Directory, migrationDirName, "up.sql"),
-		Down:      filepath.Join(cfg.Migration.Directory, migrationDirName, "down.sql"),
-		Metadata:  filepath.Join(cfg.Migration.Directory, migrationDirName, "metadata.yaml"),
-		Timestamp: timestamp,
+	dir, err := cfg.Migration.MigrationDirectory(c.String("namespace"))
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
 	}
 
-	f, err := getMigrationsFS(cfg.Migration.Directory)
-	if err != nil {
-		if errors.Is(err, ErrMigrationsDirNotExist) {
-			// Create the migrations directory if it doesn't exist
-			if err := os.MkdirAll(cfg.Migration.Directory, 0755); err != nil {
-				return errors.Wrapf(err, "failed to create migrations directory: %s", cfg.Migration.Directory)
-			}
-			// Return the DirFS for the newly created directory
-			f = os.DirFS(cfg.Migration.Directory)
-		} else {
-			return err
+	if _, err := getMigrationsFS(dir.Path); err != nil {
+		if !errors.Is(err, ErrMigrationsDirNotExist) {
+			return types.TemporaryMigrationInfo{}, err
+		}
+		// Create the migrations directory if it doesn't exist
+		if err := os.MkdirAll(dir.Path, 0755); err != nil {
+			return types.TemporaryMigrationInfo{}, errors.Wrapf(err, "failed to create migrations directory: %s", dir.Path)
 		}
 	}
 
-	defs, err := ComputeDefinitions(f)
+	// Other namespaces may not have any migrations yet.
+	src, err := configSource(cfg, true)
 	if err != nil {
-		return err
+		return types.TemporaryMigrationInfo{}, err
+	}
+	defs, err := ComputeDefinitionsFromSource(src)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
 	}
 
-	leaves, err := defs.Leaves()
+	templateName := c.String("template")
+	if templateName == "" {
+		templateName = defaultTemplate
+	}
+	tmpl, err := findTemplate(cfg.Migration.Templates, templateName)
 	if err != nil {
-		return err
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	// By default new migrations build on the latest migrations of their own namespace.
+	// The parents are checked against the graph before any file is written.
+	parents, parentNamespaces, err := addParents(c, defs, dir.Namespace)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	timestamp := nextMigrationID(defs)
+	singleFile := c.Bool("single-file")
+	m := newMigrationFiles(dir.Path, timestamp, sanitizedName)
+	if singleFile {
+		m = newSingleFileMigration(dir.Path, timestamp, sanitizedName)
+	}
+
+	// Flags take precedence over the defaults set by the template.
+	data := templateData{
+		Driver:        cfg.Database.Driver.String(),
+		Name:          sanitizedName,
+		Namespace:     dir.Namespace,
+		Timestamp:     timestamp,
+		Parents:       parents,
+		Description:   c.String("description"),
+		NoTransaction: c.Bool("no-transaction"),
+		Irreversible:  c.Bool("irreversible"),
+	}
+	defaults, err := tmpl.defaults(data)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+	if data.Description == "" {
+		data.Description = defaults.Description
+	}
+	data.NoTransaction = data.NoTransaction || defaults.NoTransaction
+	data.Irreversible = data.Irreversible || defaults.Irreversible
+
+	up, err := tmpl.render("up.sql", data)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+	down, err := tmpl.render("down.sql", data)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
 	}
 
 	md := types.MigrationMetadata{
-		Name:        sanitizedName,
-		Timestamp:   timestamp,
-		Description: c.String("description"),
-		Parents:     leaves,
+		Name:          sanitizedName,
+		Timestamp:     timestamp,
+		Description:   data.Description,
+		Parents:       parents,
+		NoTransaction: data.NoTransaction,
+		Irreversible:  data.Irreversible,
+
+		ParentNamespaces: parentNamespaces,
+	}
+
+	if singleFile {
+		err = saveSingleFileMigration(m.Up, md, up, down)
+	} else {
+		err = saveMigration(m, md, up, down)
+	}
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
 	}
 
-	if err := saveMigration(m, md); err != nil {
-		return err
+	return m, nil
+}
+
+// sanitizeName turns a migration name into the form used in directory names.
+func sanitizeName(name string) string {
+	return nonAlphaNumericOrUnderscore.ReplaceAllString(
+		strings.ReplaceAll(strings.ToLower(name), " ", "_"), "",
+	)
+}
+
+// nextMigrationID returns the ID for a migration created now. Nanosecond IDs make
+// collisions very unlikely; a script creating migrations in a tight loop could still hit
+// an existing one, so move past it.
+func nextMigrationID(g *graph.Graph) int64 {
+	timestamp := types.NewMigrationID(time.Now())
+	for g.Has(timestamp) {
+		timestamp++
 	}
+	return timestamp
+}
 
-	fmt.Printf("%sMigration created successfully!%s\n", output.StyleSuccess, output.StyleReset)
-	if cfg.Verbose {
-		fmt.Printf("%sUp query file: %s%s\n", output.StyleInfo, m.Up, output.StyleReset)
-		fmt.Printf("%sDown query file: %s%s\n", output.StyleInfo, m.Down, output.StyleReset)
-		fmt.Printf("%sMetadata file: %s%s\n", output.StyleInfo, m.Metadata, output.StyleReset)
+// newSingleFileMigration returns the path of a new single-file migration in dir.
+func newSingleFileMigration(dir string, timestamp int64, name string) types.TemporaryMigrationInfo {
+	path := filepath.Join(dir, singleFileMigrationName(timestamp, name))
+	return types.TemporaryMigrationInfo{
+		Name:      fmt.Sprintf("%d_%s", timestamp, name),
+		Up:        path,
+		Down:      path,
+		Metadata:  path,
+		Timestamp: timestamp,
 	}
+}
 
-	return nil
+// newMigrationFiles returns the paths of the files of a new migration in dir.
+func newMigrationFiles(dir string, timestamp int64, name string) types.TemporaryMigrationInfo {
+	migrationDirName := fmt.Sprintf("%d_%s", timestamp, name)
+	return types.TemporaryMigrationInfo{
+		Name:      migrationDirName,
+		Up:        filepath.Join(dir, migrationDirName, "up.sql"),
+		Down:      filepath.Join(dir, migrationDirName, "down.sql"),
+		Metadata:  filepath.Join(dir, migrationDirName, "metadata.yaml"),
+		Timestamp: timestamp,
+	}
+}
+
+// namespaceLeaves returns the migrations of namespace that no other migration of the same
+// namespace depends on.
+func namespaceLeaves(g *graph.Graph, namespace string) ([]int64, error) {
+	adj, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting adjacency map")
+	}
+
+	var leaves []int64
+	for ts, children := range adj {
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return nil, err
+		}
+		if def.Namespace != namespace {
+			continue
+		}
+
+		leaf := true
+		for child := range children {
+			childDef, err := g.GetDefinition(child)
+			if err != nil {
+				return nil, err
+			}
+			if childDef.Namespace == namespace {
+				leaf = false
+				break
+			}
+		}
+		if leaf {
+			leaves = append(leaves, ts)
+		}
+	}
+	slices.Sort(leaves)
+	return leaves, nil
 }
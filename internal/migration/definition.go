@@ -8,7 +8,6 @@ import (
 	"sort"
 
 	"github.com/cockroachdb/errors"
-	"github.com/keegancsmith/sqlf"
 	"gopkg.in/yaml.v3"
 
 	"github.com/BolajiOlajide/kat/internal/types"
@@ -43,17 +42,23 @@ func extractMigrationFiles(f fs.FS) ([]fs.FileInfo, error) {
 	return fis, nil
 }
 
-func computeDefinition(fs fs.FS, filename string) (types.Definition, error) {
-	// Read all migration files in a single function call
-	upQuery, downQuery, metadata, err := readMigrationFiles(fs, filename)
+// readMigration reads the metadata and SQL of the migration in dirname.
+func readMigration(f fs.FS, dirname string) (types.MigrationMetadata, string, string, error) {
+	up, down, metadata, err := readMigrationFiles(f, dirname)
 	if err != nil {
-		return types.Definition{}, err
+		return types.MigrationMetadata{}, "", "", err
 	}
 
-	return populateDefinition(upQuery, downQuery, metadata)
+	payload, err := parseMetadata(metadata)
+	if err != nil {
+		return types.MigrationMetadata{}, "", "", errors.Wrapf(err, "parsing metadata.yaml for migration %s", dirname)
+	}
+	return payload, up, down, nil
 }
 
-func readMigrationFiles(f fs.FS, dirname string) (*sqlf.Query, *sqlf.Query, []byte, error) {
+// readMigrationFiles returns the contents of a migration directory's up.sql, down.sql and
+// metadata.yaml.
+func readMigrationFiles(f fs.FS, dirname string) (string, string, []byte, error) {
 	upFilename := fmt.Sprintf("%s/up.sql", dirname)
 	downFilename := fmt.Sprintf("%s/down.sql", dirname)
 	metadataFilename := fmt.Sprintf("%s/metadata.yaml", dirname)
@@ -61,58 +66,58 @@ func readMigrationFiles(f fs.FS, dirname string) (*sqlf.Query, *sqlf.Query, []by
 	// Read up.sql file
 	upFile, err := f.Open(upFilename)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "failed to open up.sql for migration %s", dirname)
+		return "", "", nil, errors.Wrapf(err, "failed to open up.sql for migration %s", dirname)
 	}
 	defer upFile.Close()
 
 	// Read down.sql file
 	downFile, err := f.Open(downFilename)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "failed to open down.sql for migration %s", dirname)
+		return "", "", nil, errors.Wrapf(err, "failed to open down.sql for migration %s", dirname)
 	}
 	defer downFile.Close()
 
 	// Read metadata.yaml file
 	metadataFile, err := f.Open(metadataFilename)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "failed to open metadata.yaml for migration %s", dirname)
+		return "", "", nil, errors.Wrapf(err, "failed to open metadata.yaml for migration %s", dirname)
 	}
 	defer metadataFile.Close()
 
 	// Read file contents
 	upContent, err := readFile(upFile)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "failed to read up.sql for migration %s", dirname)
+		return "", "", nil, errors.Wrapf(err, "failed to read up.sql for migration %s", dirname)
 	}
 
 	downContent, err := readFile(downFile)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "failed to read down.sql for migration %s", dirname)
+		return "", "", nil, errors.Wrapf(err, "failed to read down.sql for migration %s", dirname)
 	}
 
 	metadata, err := readFile(metadataFile)
 	if err != nil {
-		return nil, nil, nil, errors.Wrapf(err, "failed to read metadata.yaml for migration %s", dirname)
+		return "", "", nil, errors.Wrapf(err, "failed to read metadata.yaml for migration %s", dirname)
 	}
 
-	// Convert SQL content to queries
-	upQuery := queryFromString(string(upContent))
-	downQuery := queryFromString(string(downContent))
-
-	return upQuery, downQuery, metadata, nil
+	return string(upContent), string(downContent), metadata, nil
 }
 
-func populateDefinition(upQuery, downQuery *sqlf.Query, metadata []byte) (types.Definition, error) {
+func parseMetadata(metadata []byte) (types.MigrationMetadata, error) {
 	var payload types.MigrationMetadata
 	if err := yaml.Unmarshal(metadata, &payload); err != nil {
-		return types.Definition{}, err
+		return types.MigrationMetadata{}, err
 	}
+	return payload, nil
+}
 
+// newDefinition builds a definition from a migration's metadata and its SQL.
+func newDefinition(metadata types.MigrationMetadata, up, down string) types.Definition {
 	return types.Definition{
-		UpQuery:           upQuery,
-		DownQuery:         downQuery,
-		MigrationMetadata: payload,
-	}, nil
+		UpQuery:           queryFromString(up),
+		DownQuery:         queryFromString(down),
+		MigrationMetadata: metadata,
+	}
 }
 
 func readFile(fi fs.File) ([]byte, error) {
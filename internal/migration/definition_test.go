@@ -11,7 +11,7 @@ import (
 	"github.com/BolajiOlajide/kat/internal/types"
 )
 
-func TestComputeDefinition(t *testing.T) {
+func TestReadMigration(t *testing.T) {
 	tests := []struct {
 		name         string
 		files        fstest.MapFS
@@ -94,7 +94,7 @@ func TestComputeDefinition(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := computeDefinition(tt.files, tt.migrationDir)
+			metadata, up, down, err := readMigration(tt.files, tt.migrationDir)
 
 			if tt.expectError {
 				require.Error(t, err)
@@ -102,6 +102,7 @@ func TestComputeDefinition(t *testing.T) {
 			}
 
 			require.NoError(t, err)
+			result := newDefinition(metadata, up, down)
 
 			// Check metadata fields
 			require.Equal(t, tt.expectedDef.Name, result.Name)
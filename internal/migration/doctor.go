@@ -0,0 +1,403 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/keegancsmith/sqlf"
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/config"
+	"github.com/BolajiOlajide/kat/internal/database"
+	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/runner"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// CheckStatus is the outcome of a `kat doctor` check.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+	// CheckSkip marks a check that couldn't run because an earlier one failed.
+	CheckSkip CheckStatus = "skip"
+)
+
+// Check is the result of one `kat doctor` check.
+type Check struct {
+	Name    string
+	Status  CheckStatus
+	Message string
+	// Hint suggests how to fix a failed check or act on a warning.
+	Hint string
+}
+
+// The checks `kat doctor` runs, in order.
+const (
+	checkConfig        = "config"
+	checkConnection    = "connection"
+	checkServerVersion = "server version"
+	checkReadOnly      = "read-only"
+	checkCreateTable   = "create table"
+	checkTrackingTable = "tracking table"
+	checkMigrations    = "migrations"
+	checkOrphanedRows  = "orphaned rows"
+)
+
+// minPostgresVersion is the oldest PostgreSQL release that supports the identity column
+// of the tracking table.
+const minPostgresVersion = 100000
+
+// errProbeRollback rolls back the transaction of the create table check.
+var errProbeRollback = errors.New("rolling back probe table")
+
+// Doctor runs the `kat doctor` checklist against the configuration, database and
+// migrations, and returns the result of every check. Checks that depend on one that
+// failed, such as the database checks when kat can't connect, are skipped.
+func Doctor(c *cli.Context) []Check {
+	var checks []Check
+	add := func(name string, status CheckStatus, message, hint string) {
+		checks = append(checks, Check{Name: name, Status: status, Message: message, Hint: hint})
+	}
+	skip := func(reason string, names ...string) []Check {
+		for _, name := range names {
+			add(name, CheckSkip, reason, "")
+		}
+		return checks
+	}
+
+	resolved, err := config.Load(c)
+	if err != nil {
+		add(checkConfig, CheckFail, err.Error(), "run `kat init` to create kat.conf.yaml, pass --config, or set KAT_* environment variables")
+		return skip("no configuration", checkConnection, checkServerVersion, checkReadOnly, checkCreateTable, checkTrackingTable, checkMigrations, checkOrphanedRows)
+	}
+	cfg := resolved.Config
+	switch {
+	case len(resolved.Warnings) > 0:
+		add(checkConfig, CheckWarn, strings.Join(resolved.Warnings, "; "), "write environment variable references as ${VAR}")
+	case resolved.Path != "":
+		add(checkConfig, CheckPass, "loaded "+resolved.Path, "")
+	default:
+		add(checkConfig, CheckPass, "configured from environment variables", "")
+	}
+
+	// The migrations don't need the database, so they are checked even if it's down.
+	migrationsCheck, definitions := checkMigrationFiles(cfg)
+
+	ctx := c.Context
+	db, latency, err := connect(c, cfg)
+	if err != nil {
+		add(checkConnection, CheckFail, err.Error(), "check the database settings with `kat config show` and that the server accepts connections from this host")
+		skip("no database connection", checkServerVersion, checkReadOnly, checkCreateTable, checkTrackingTable)
+		checks = append(checks, migrationsCheck)
+		return skip("no database connection", checkOrphanedRows)
+	}
+	defer db.Close()
+	add(checkConnection, CheckPass, fmt.Sprintf("connected to %s in %s", cfg.Database.Driver, latency.Round(time.Microsecond)), "")
+
+	checks = append(checks,
+		checkVersion(ctx, db),
+		checkReplica(ctx, db),
+		checkCreate(ctx, db),
+	)
+	tracking, columns := checkTracking(ctx, db, cfg.Migration.TableName)
+	checks = append(checks, tracking, migrationsCheck)
+
+	switch {
+	case definitions == nil:
+		return skip("the migrations couldn't be loaded", checkOrphanedRows)
+	case len(columns) == 0:
+		return skip("the tracking table doesn't exist", checkOrphanedRows)
+	}
+	return append(checks, checkOrphans(ctx, db, cfg.Migration.TableName, definitions))
+}
+
+// connect opens the database and pings it with the --retry-count and --retry-delay
+// settings, and returns how long the ping took.
+func connect(c *cli.Context, cfg types.Config) (database.DB, time.Duration, error) {
+	dbConn, err := cfg.Database.ConnString()
+	if err != nil {
+		return nil, 0, err
+	}
+	dbConfig, err := DBConfigFromCfg(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	db, err := database.NewWithConfig(cfg.Database.Driver, dbConn, LoggerFromCtx(c), dbConfig)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	if err := db.PingWithRetry(c.Context, c.Int("retry-count"), c.Int("retry-delay")); err != nil {
+		db.Close()
+		return nil, 0, err
+	}
+	return db, time.Since(start), nil
+}
+
+func checkVersion(ctx context.Context, db database.DB) Check {
+	check := Check{Name: checkServerVersion}
+	if db.Driver().IsSQLite() {
+		var version string
+		if err := db.QueryRow(ctx, sqlf.Sprintf("SELECT sqlite_version()")).Scan(&version); err != nil {
+			check.Status, check.Message = CheckFail, err.Error()
+			return check
+		}
+		check.Status, check.Message = CheckPass, "SQLite "+version
+		return check
+	}
+
+	var version, num string
+	if err := db.QueryRow(ctx, sqlf.Sprintf("SELECT current_setting('server_version'), current_setting('server_version_num')")).Scan(&version, &num); err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check
+	}
+	check.Status, check.Message = CheckPass, "PostgreSQL "+version
+	if n, err := strconv.Atoi(num); err == nil && n < minPostgresVersion {
+		check.Status = CheckFail
+		check.Hint = "kat needs PostgreSQL 10 or later for its tracking table"
+	}
+	return check
+}
+
+func checkReplica(ctx context.Context, db database.DB) Check {
+	check := Check{Name: checkReadOnly}
+	if db.Driver().IsSQLite() {
+		var queryOnly bool
+		if err := db.QueryRow(ctx, sqlf.Sprintf("PRAGMA query_only")).Scan(&queryOnly); err != nil {
+			check.Status, check.Message = CheckFail, err.Error()
+			return check
+		}
+		if queryOnly {
+			check.Status, check.Message = CheckFail, "the connection is in query_only mode"
+			return check
+		}
+		check.Status, check.Message = CheckPass, "the database is writable"
+		return check
+	}
+
+	var inRecovery bool
+	var readOnly string
+	if err := db.QueryRow(ctx, sqlf.Sprintf("SELECT pg_is_in_recovery(), current_setting('transaction_read_only')")).Scan(&inRecovery, &readOnly); err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check
+	}
+	switch {
+	case inRecovery:
+		check.Status, check.Message = CheckFail, "the server is a read-only replica"
+		check.Hint = "point kat at the primary server"
+	case readOnly == "on":
+		check.Status, check.Message = CheckFail, "transactions are read-only by default (default_transaction_read_only is on)"
+		check.Hint = "connect as a role or to a database that allows writes"
+	default:
+		check.Status, check.Message = CheckPass, "the server is a primary and accepts writes"
+	}
+	return check
+}
+
+// checkCreate creates a table in a transaction that is rolled back, to find out whether
+// the user can create the tracking table and the tables of migrations.
+func checkCreate(ctx context.Context, db database.DB) Check {
+	check := Check{Name: checkCreateTable}
+	schema := "main"
+	if db.Driver().IsPostgres() {
+		var current *string
+		if err := db.QueryRow(ctx, sqlf.Sprintf("SELECT current_schema()")).Scan(&current); err != nil {
+			check.Status, check.Message = CheckFail, err.Error()
+			return check
+		}
+		if current == nil {
+			check.Status, check.Message = CheckFail, "no schema in the search_path exists"
+			check.Hint = "create the schema or fix the search_path of the database user"
+			return check
+		}
+		schema = *current
+	}
+
+	err := db.WithTransact(ctx, func(tx database.Tx) error {
+		if err := tx.Exec(ctx, sqlf.Sprintf(`CREATE TABLE "kat_doctor_probe" (id INTEGER)`)); err != nil {
+			return err
+		}
+		return errProbeRollback
+	})
+	if !errors.Is(err, errProbeRollback) {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("cannot create tables in schema %s: %s", schema, err)
+		if db.Driver().IsPostgres() {
+			check.Hint = fmt.Sprintf("grant the permission with `GRANT CREATE ON SCHEMA %s TO <user>`", schema)
+		} else {
+			check.Hint = "check that the database file and its directory are writable"
+		}
+		return check
+	}
+	check.Status, check.Message = CheckPass, "can create tables in schema "+schema
+	return check
+}
+
+// checkTracking checks that the tracking table has the columns kat uses, and returns the
+// columns it has; none when it doesn't exist yet.
+func checkTracking(ctx context.Context, db database.DB, table string) (Check, []string) {
+	check := Check{Name: checkTrackingTable}
+	query := sqlf.Sprintf("SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = %s", table)
+	if db.Driver().IsSQLite() {
+		query = sqlf.Sprintf("SELECT name FROM pragma_table_info(%s)", table)
+	}
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check, nil
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			check.Status, check.Message = CheckFail, err.Error()
+			return check, nil
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check, nil
+	}
+
+	if len(columns) == 0 {
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("table %q doesn't exist yet", table)
+		check.Hint = "`kat up` creates it"
+		return check, nil
+	}
+	var missing, added []string
+	for _, column := range runner.MigrationLogColumns() {
+		switch {
+		case slices.Contains(columns, column):
+		case slices.Contains(runner.AddedMigrationLogColumns(), column):
+			added = append(added, column)
+		default:
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		check.Status, check.Message = CheckFail, fmt.Sprintf("table %q is missing column(s) %s", table, strings.Join(missing, ", "))
+		check.Hint = "another tool may own this table; set migration.tablename to a table kat can use"
+		return check, columns
+	}
+	if len(added) > 0 {
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("table %q was created by an older version of kat and has no %s column", table, strings.Join(added, ", "))
+		check.Hint = "`kat up` adds it"
+		return check, columns
+	}
+	check.Status, check.Message = CheckPass, fmt.Sprintf("table %q has the expected columns", table)
+	return check, columns
+}
+
+// checkMigrationFiles validates the migrations and returns the names of the loaded
+// migrations, or nil if they couldn't be loaded.
+func checkMigrationFiles(cfg types.Config) (Check, map[string]bool) {
+	check := Check{Name: checkMigrations}
+	problems, count, err := Validate(cfg)
+	switch {
+	case errors.Is(err, ErrMigrationsDirNotExist):
+		check.Status, check.Message = CheckFail, err.Error()
+		check.Hint = "create the directory with `kat add` or fix migration.directory"
+		return check, nil
+	case err != nil:
+		check.Status, check.Message = CheckFail, err.Error()
+		return check, nil
+	case len(problems) > 0:
+		check.Status, check.Message = CheckFail, fmt.Sprintf("found %d problem(s) in %d migration(s), the first: %s", len(problems), count, problems[0].Message)
+		check.Hint = "run `kat validate` for the full list"
+		return check, nil
+	}
+
+	g, err := ComputeDefinitionsFromConfig(cfg)
+	if err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check, nil
+	}
+	ids, err := g.TopologicalSort()
+	if err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check, nil
+	}
+	names := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		def, err := g.GetDefinition(id)
+		if err != nil {
+			check.Status, check.Message = CheckFail, err.Error()
+			return check, nil
+		}
+		names[def.FileName()] = true
+	}
+	check.Status, check.Message = CheckPass, fmt.Sprintf("%d migration(s) are valid", count)
+	return check, names
+}
+
+// checkOrphans looks for tracking rows of migrations that aren't on disk, which happens
+// when an older release of the code runs against a database migrated by a newer one.
+func checkOrphans(ctx context.Context, db database.DB, table string, definitions map[string]bool) Check {
+	check := Check{Name: checkOrphanedRows}
+	rows, err := db.Query(ctx, sqlf.Sprintf(fmt.Sprintf(`SELECT name FROM "%s" ORDER BY id`, table)))
+	if err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check
+	}
+	defer rows.Close()
+	var orphans []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			check.Status, check.Message = CheckFail, err.Error()
+			return check
+		}
+		if !definitions[name] {
+			orphans = append(orphans, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		check.Status, check.Message = CheckFail, err.Error()
+		return check
+	}
+
+	if len(orphans) > 0 {
+		check.Status, check.Message = CheckWarn, fmt.Sprintf("%d applied migration(s) aren't on disk: %s", len(orphans), strings.Join(orphans, ", "))
+		check.Hint = "the database may have been migrated by a newer version of the code; deploy it, or remove the rows if the migrations were deleted on purpose"
+		return check
+	}
+	check.Status, check.Message = CheckPass, "every tracking row matches a migration on disk"
+	return check
+}
+
+// PrintDoctor writes the result of every check, with hints for failures and warnings,
+// followed by a summary.
+func PrintDoctor(w io.Writer, checks []Check) error {
+	counts := map[CheckStatus]int{}
+	for _, check := range checks {
+		counts[check.Status]++
+		style := output.StyleSuggestion
+		switch check.Status {
+		case CheckPass:
+			style = output.StyleSuccess
+		case CheckWarn:
+			style = output.StyleWarning
+		case CheckFail:
+			style = output.StyleFailure
+		}
+		fmt.Fprintf(w, "%s[%s] %s: %s%s\n", style, check.Status, check.Name, check.Message, output.StyleReset)
+		if check.Hint != "" {
+			fmt.Fprintf(w, "       %s%s%s\n", output.StyleSuggestion, check.Hint, output.StyleReset)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%s%d passed, %d warning(s), %d failed, %d skipped.%s\n",
+		output.StyleInfo, counts[CheckPass], counts[CheckWarn], counts[CheckFail], counts[CheckSkip], output.StyleReset)
+	return err
+}
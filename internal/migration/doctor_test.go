@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/database"
+	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
+	"github.com/BolajiOlajide/kat/internal/loggr"
+)
+
+func TestDoctorChecks(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(dbdriver.SqliteDriver, filepath.Join(t.TempDir(), "doctor.db"), loggr.NewDefault())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.Equal(t, CheckPass, checkVersion(ctx, db).Status)
+	require.Equal(t, Check{Name: checkReadOnly, Status: CheckPass, Message: "the database is writable"}, checkReplica(ctx, db))
+	require.Equal(t, Check{Name: checkCreateTable, Status: CheckPass, Message: "can create tables in schema main"}, checkCreate(ctx, db))
+
+	// The probe table is rolled back.
+	check, columns := checkTracking(ctx, db, "kat_doctor_probe")
+	require.Equal(t, CheckWarn, check.Status)
+	require.Empty(t, columns)
+
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`CREATE TABLE "migrations" (id INTEGER PRIMARY KEY, name TEXT NOT NULL, migration_time TEXT)`)))
+	check, columns = checkTracking(ctx, db, "migrations")
+	require.Equal(t, Check{
+		Name:    checkTrackingTable,
+		Status:  CheckFail,
+		Message: `table "migrations" is missing column(s) duration`,
+		Hint:    "another tool may own this table; set migration.tablename to a table kat can use",
+	}, check)
+	require.Equal(t, []string{"id", "name", "migration_time"}, columns)
+
+	// Tables created before kat recorded batches are upgraded by the next run.
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`ALTER TABLE "migrations" ADD COLUMN duration TEXT`)))
+	check, _ = checkTracking(ctx, db, "migrations")
+	require.Equal(t, Check{
+		Name:    checkTrackingTable,
+		Status:  CheckWarn,
+		Message: `table "migrations" was created by an older version of kat and has no batch column`,
+		Hint:    "`kat up` adds it",
+	}, check)
+
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`INSERT INTO "migrations" (name) VALUES ('1747578000_create_users'), ('1747579000_add_email')`)))
+	check = checkOrphans(ctx, db, "migrations", map[string]bool{"1747578000_create_users": true})
+	require.Equal(t, CheckWarn, check.Status)
+	require.Equal(t, "1 applied migration(s) aren't on disk: 1747579000_add_email", check.Message)
+
+	check = checkOrphans(ctx, db, "migrations", map[string]bool{"1747578000_create_users": true, "1747579000_add_email": true})
+	require.Equal(t, CheckPass, check.Status)
+}
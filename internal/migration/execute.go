@@ -2,21 +2,30 @@ package migration
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli/v2"
 
 	"github.com/BolajiOlajide/kat/internal/database"
-	"github.com/BolajiOlajide/kat/internal/graph"
 	"github.com/BolajiOlajide/kat/internal/loggr"
+	"github.com/BolajiOlajide/kat/internal/metrics"
 	"github.com/BolajiOlajide/kat/internal/runner"
+	"github.com/BolajiOlajide/kat/internal/tracing"
 	"github.com/BolajiOlajide/kat/internal/types"
+	katprom "github.com/BolajiOlajide/kat/prometheus"
 )
 
 // DBConfigFromCfg builds a database.DBConfig from the config file's timeout settings.
 // Falls back to database.DefaultDBConfig(driver) for any unset fields.
 func DBConfigFromCfg(cfg types.Config) (database.DBConfig, error) {
 	dbConfig := database.DefaultDBConfig(cfg.Database.Driver)
+	if cfg.Database.HasCredentialCommand() {
+		// Run the credential command again whenever the pool opens a new connection.
+		dbConfig.ConnString = cfg.Database.ConnString
+	}
 
 	timeouts, err := cfg.Database.ParseDBTimeouts()
 	if err != nil {
@@ -48,93 +57,160 @@ func DBConfigFromCfg(cfg types.Config) (database.DBConfig, error) {
 	return dbConfig, nil
 }
 
-// Up is the command that runs the up migration operation.
-func Up(c *cli.Context, cfg types.Config, dryRun bool) error {
+// Up is the command that runs the up migration operation. It returns the outcome of
+// every migration it attempted.
+func Up(c *cli.Context, cfg types.Config, dryRun bool) ([]types.MigrationResult, error) {
 	count := c.Int("count")
 	if count < 0 {
-		return errors.New("count cannot be a negative number")
+		return nil, errors.New("count cannot be a negative number")
+	}
+	only := c.Int64("only")
+	if c.IsSet("only") {
+		if only <= 0 {
+			return nil, errors.New("--only must be a migration timestamp")
+		}
+		if c.IsSet("count") {
+			return nil, errors.New("--only cannot be combined with --count")
+		}
 	}
 
-	f, err := getMigrationsFS(cfg.Migration.Directory)
+	definitions, err := ComputeDefinitionsFromConfig(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	definitions, err := ComputeDefinitions(f)
-	if err != nil {
-		return err
+	if cfg.Migration.RequireSingleHead {
+		heads, err := computeHeads(definitions)
+		if err != nil {
+			return nil, err
+		}
+		if err := requireSingleHead(heads); err != nil {
+			return nil, err
+		}
 	}
 
 	dbConn, err := cfg.Database.ConnString()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	dbConfig, err := DBConfigFromCfg(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	logger := loggr.NewDefault()
+	logger := LoggerFromCtx(c)
 
 	db, err := database.NewWithConfig(cfg.Database.Driver, dbConn, logger, dbConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer db.Close()
 
-	return Execute(c.Context, db, logger, definitions, cfg, count, types.UpMigrationOperation, dryRun)
+	tp, shutdown, err := tracing.NewProvider(c.Context, cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	defer flushTraces(shutdown, logger)
+
+	var runMetrics metrics.Metrics
+	if metricsFile := c.Path("metrics-file"); metricsFile != "" {
+		reg := prom.NewRegistry()
+		runMetrics = katprom.New(reg)
+		defer writeMetricsFile(metricsFile, reg, logger)
+	}
+
+	return Execute(c.Context, db, logger, runner.Options{
+		Tracer:        tp.Tracer(runner.TracerName),
+		Metrics:       runMetrics,
+		Operation:     types.UpMigrationOperation,
+		Definitions:   definitions,
+		MigrationInfo: cfg.Migration,
+		DryRun:        dryRun,
+		Verbose:       cfg.Verbose,
+		Count:         count,
+		Only:          only,
+	})
 }
 
-func Down(c *cli.Context, cfg types.Config, dryRun bool) error {
+// Down is the command that runs the down migration operation. It returns the outcome of
+// every migration it attempted.
+func Down(c *cli.Context, cfg types.Config, dryRun bool) ([]types.MigrationResult, error) {
 	count := c.Int("count")
 	if count < 1 {
-		return errors.New("count must be a non-zero positive number")
+		return nil, errors.New("count must be a non-zero positive number")
 	}
-
-	f, err := getMigrationsFS(cfg.Migration.Directory)
-	if err != nil {
-		return err
+	batch := c.Bool("batch")
+	if batch && c.IsSet("count") {
+		return nil, errors.New("--batch cannot be combined with --count")
 	}
 
-	g, err := ComputeDefinitions(f)
+	g, err := ComputeDefinitionsFromConfig(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	dbConn, err := cfg.Database.ConnString()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	dbConfig, err := DBConfigFromCfg(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	logger := loggr.NewDefault()
+	logger := LoggerFromCtx(c)
 
 	db, err := database.NewWithConfig(cfg.Database.Driver, dbConn, logger, dbConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer db.Close()
 
-	return Execute(c.Context, db, logger, g, cfg, count, types.DownMigrationOperation, dryRun)
-}
-
-func Execute(ctx context.Context, db database.DB, logger loggr.Logger, definitions *graph.Graph, cfg types.Config, count int, op types.MigrationOperationType, dryRun bool) error {
-	r, err := runner.NewRunner(ctx, db, logger)
+	tp, shutdown, err := tracing.NewProvider(c.Context, cfg.Tracing)
 	if err != nil {
-		return errors.Wrap(err, "initializing runner")
+		return nil, err
 	}
+	defer flushTraces(shutdown, logger)
 
-	return r.Run(ctx, runner.Options{
-		Operation:     op,
-		Definitions:   definitions,
+	return Execute(c.Context, db, logger, runner.Options{
+		Tracer:        tp.Tracer(runner.TracerName),
+		Operation:     types.DownMigrationOperation,
+		Definitions:   g,
 		MigrationInfo: cfg.Migration,
 		DryRun:        dryRun,
 		Verbose:       cfg.Verbose,
 		Count:         count,
+		Force:         c.Bool("force"),
+		Batch:         batch,
 	})
 }
+
+// flushTraces sends any buffered spans before the command exits.
+func flushTraces(shutdown tracing.ShutdownFunc, logger loggr.StructuredLogger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		logger.Warn(fmt.Sprintf("Failed to export traces: %s", err), loggr.KeyError, err)
+	}
+}
+
+// writeMetricsFile writes the metrics gathered during a run in the node exporter's
+// textfile collector format. It runs whether or not the migrations succeeded.
+func writeMetricsFile(path string, g prom.Gatherer, logger loggr.StructuredLogger) {
+	if err := prom.WriteToTextfile(path, g); err != nil {
+		logger.Warn(fmt.Sprintf("Failed to write metrics file %q: %s", path, err), loggr.KeyError, err)
+	}
+}
+
+// Execute runs the migrations described by options against the database and returns
+// the outcome of every migration it attempted.
+func Execute(ctx context.Context, db database.DB, logger loggr.StructuredLogger, options runner.Options) ([]types.MigrationResult, error) {
+	r, err := runner.NewRunner(ctx, db, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing runner")
+	}
+
+	return r.Run(ctx, options)
+}
@@ -8,16 +8,35 @@ import (
 
 func ExportGraph(w io.Writer, cfg types.Config) error {
 	// get filesystem for the migrations directory
-	dirFS, err := getMigrationsFS(cfg.Migration.Directory)
+	// Compute the migration graph
+	g, err := ComputeDefinitionsFromConfig(cfg)
 	if err != nil {
 		return err
 	}
 
-	// Compute the migration graph
-	g, err := ComputeDefinitions(dirFS)
+	return g.Draw(w)
+}
+
+// ListDefinitions returns every migration in the migrations directory in the order
+// they would be applied.
+func ListDefinitions(cfg types.Config) ([]types.Definition, error) {
+	g, err := ComputeDefinitionsFromConfig(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return g.Draw(w)
+	sorted, err := g.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make([]types.Definition, 0, len(sorted))
+	for _, ts := range sorted {
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
 }
@@ -11,7 +11,7 @@ import (
 // ErrMigrationsDirNotExist is returned when the migrations directory doesn't exist
 var ErrMigrationsDirNotExist = errors.New("migrations directory does not exist")
 
-//go:embed templates/init.tmpl
+//go:embed templates
 var templatesFS embed.FS
 
 func getMigrationsFS(path string) (fs.FS, error) {
@@ -3,7 +3,10 @@ package migration
 import (
 	"io/fs"
 
+	"github.com/cockroachdb/errors"
+
 	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/types"
 )
 
 // ComputeDefinitions builds a directed acyclic graph (DAG) from migration definitions.
@@ -12,39 +15,89 @@ import (
 // migration ordering and dependency resolution.
 //
 // The function expects directories to be timestamp-prefixed following Kat's convention.
+// extra definitions, such as migrations written in Go, are merged with the ones found in f.
 // It returns the constructed graph and any errors encountered during graph construction.
-func ComputeDefinitions(f fs.FS) (*graph.Graph, error) {
+func ComputeDefinitions(f fs.FS, extra ...types.Definition) (*graph.Graph, error) {
+	return ComputeDefinitionsFromSource(DirSource(f), extra...)
+}
+
+// ComputeDefinitionsFromSource builds the migration graph from the migrations in src and
+// any extra definitions.
+func ComputeDefinitionsFromSource(src Source, extra ...types.Definition) (*graph.Graph, error) {
 	g := graph.New()
 
-	mf, err := extractMigrationFiles(f)
+	migrations, err := src.Migrations()
 	if err != nil {
 		return nil, err
 	}
 
-	// Because Kat prepends it's directories with a timestamp, we expect the migrations
-	// to be automatically sorted, so we can pretty much assume the loop is going to be
-	// in ascending order from the oldest migrations to newest.
-	//
-	// If this isn't the case, adding an edge will result in an error, because the parent
-	// edge won't be found in the graph.
-	for _, file := range mf {
-		if !file.IsDir() {
-			// if this is not a directory, skip it.
-
-			// Kat expects migrations to live in directories, so this is one of the most
-			// important validations we can have
-			continue
+	defs := make([]types.Definition, 0, len(migrations)+len(extra))
+	for _, m := range migrations {
+		up, down, err := src.ReadSQL(m)
+		if err != nil {
+			return nil, err
 		}
+		defs = append(defs, newDefinition(m, up, down))
+	}
+	defs = append(defs, extra...)
 
-		definition, err := computeDefinition(f, file.Name())
-		if err != nil {
-			return g, err
+	if err := validateParentNamespaces(defs); err != nil {
+		return nil, err
+	}
+
+	if err := g.AddDefinitions(defs...); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// validateParentNamespaces checks that every parent written as `namespace:timestamp`
+// belongs to that namespace.
+func validateParentNamespaces(defs []types.Definition) error {
+	namespaces := make(map[int64]string, len(defs))
+	for _, def := range defs {
+		namespaces[def.Timestamp] = def.Namespace
+	}
+
+	for _, def := range defs {
+		for parent, ns := range def.ParentNamespaces {
+			actual, ok := namespaces[parent]
+			if !ok {
+				return errors.Newf("migration %s: parent %s:%d does not exist", def.QualifiedName(), ns, parent)
+			}
+			if actual != ns {
+				return errors.Newf("migration %s: parent %d is in namespace %q, not %q", def.QualifiedName(), parent, actual, ns)
+			}
 		}
+	}
+	return nil
+}
+
+// ComputeDefinitionsFromConfig builds the migration graph from every migrations
+// directory in cfg.
+func ComputeDefinitionsFromConfig(cfg types.Config) (*graph.Graph, error) {
+	src, err := configSource(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeDefinitionsFromSource(src)
+}
 
-		if err := g.AddDefinition(definition); err != nil {
+// configSource returns a Source over every migrations directory in cfg, each tagged with
+// its namespace. Directories that don't exist are skipped when skipMissing is set.
+func configSource(cfg types.Config, skipMissing bool) (Source, error) {
+	dirs := cfg.Migration.MigrationDirectories()
+	sources := make([]Source, 0, len(dirs))
+	for _, dir := range dirs {
+		f, err := getMigrationsFS(dir.Path)
+		if skipMissing && errors.Is(err, ErrMigrationsDirNotExist) {
+			continue
+		}
+		if err != nil {
 			return nil, err
 		}
+		sources = append(sources, NamespacedSource(dir.Namespace, DirSource(f)))
 	}
-
-	return g, nil
+	return MergeSources(sources...), nil
 }
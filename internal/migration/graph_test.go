@@ -1,16 +1,22 @@
 package migration
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
 )
 
 func TestComputeDefinitions(t *testing.T) {
 	tests := []struct {
 		name             string
 		files            fstest.MapFS
+		extra            []types.Definition
 		expectError      bool
 		expectedVertices int
 		expectedEdges    map[int64][]int64
@@ -47,6 +53,45 @@ func TestComputeDefinitions(t *testing.T) {
 				1651234568: {},
 			},
 		},
+		{
+			name: "extra definitions interleave with files",
+			files: fstest.MapFS{
+				"1651234567/up.sql":        {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);\n")},
+				"1651234567/down.sql":      {Data: []byte("DROP TABLE users;\n")},
+				"1651234567/metadata.yaml": {Data: []byte("name: create_users\ntimestamp: 1651234567\nparents: []\n")},
+				"1651234569/up.sql":        {Data: []byte("CREATE TABLE posts (id SERIAL PRIMARY KEY);\n")},
+				"1651234569/down.sql":      {Data: []byte("DROP TABLE posts;\n")},
+				"1651234569/metadata.yaml": {Data: []byte("name: create_posts\ntimestamp: 1651234569\nparents: [1651234568]\n")},
+			},
+			extra: []types.Definition{
+				{
+					MigrationMetadata: types.MigrationMetadata{Name: "backfill_users", Timestamp: 1651234568, Parents: []int64{1651234567}},
+					Go:                &types.GoMigration{},
+				},
+			},
+			expectedVertices: 3,
+			expectedEdges: map[int64][]int64{
+				1651234567: {1651234568},
+				1651234568: {1651234569},
+				1651234569: {},
+			},
+		},
+		{
+			name: "parent newer than child",
+			files: fstest.MapFS{
+				"1651234567/up.sql":        {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);\n")},
+				"1651234567/down.sql":      {Data: []byte("DROP TABLE users;\n")},
+				"1651234567/metadata.yaml": {Data: []byte("name: add_email\ntimestamp: 1651234567\nparents: [1651234568]\n")},
+				"1651234568/up.sql":        {Data: []byte("CREATE TABLE posts (id SERIAL PRIMARY KEY);\n")},
+				"1651234568/down.sql":      {Data: []byte("DROP TABLE posts;\n")},
+				"1651234568/metadata.yaml": {Data: []byte("name: create_posts\ntimestamp: 1651234568\nparents: []\n")},
+			},
+			expectedVertices: 2,
+			expectedEdges: map[int64][]int64{
+				1651234567: {},
+				1651234568: {1651234567},
+			},
+		},
 		{
 			name: "missing required files",
 			files: fstest.MapFS{
@@ -59,7 +104,7 @@ func TestComputeDefinitions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := ComputeDefinitions(tt.files)
+			result, err := ComputeDefinitions(tt.files, tt.extra...)
 
 			if tt.expectError {
 				require.Error(t, err)
@@ -99,3 +144,58 @@ func TestComputeDefinitions(t *testing.T) {
 		})
 	}
 }
+
+func TestComputeDefinitionsFromConfig(t *testing.T) {
+	writeMigration := func(t *testing.T, dir, metadata string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "up.sql"), []byte("SELECT 1;"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "down.sql"), []byte("SELECT 1;"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "metadata.yaml"), []byte(metadata), 0644))
+	}
+
+	tests := []struct {
+		name          string
+		billingParent string
+		expectError   string
+	}{
+		{name: "namespaced parent", billingParent: "core:1747578808"},
+		{name: "plain parent", billingParent: "1747578808"},
+		{name: "parent in the wrong namespace", billingParent: "billing:1747578808", expectError: `parent 1747578808 is in namespace "core", not "billing"`},
+		{name: "unknown parent", billingParent: "core:1747578000", expectError: "parent core:1747578000 does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeMigration(t, filepath.Join(root, "core", "1747578808_create_users"), "name: create_users\ntimestamp: 1747578808\n")
+			writeMigration(t, filepath.Join(root, "billing", "1747578900_create_invoices"),
+				fmt.Sprintf("name: create_invoices\ntimestamp: 1747578900\nparents: [%s]\n", tt.billingParent))
+
+			cfg := types.Config{Migration: types.MigrationInfo{Directories: []types.MigrationDirectory{
+				{Namespace: "core", Path: filepath.Join(root, "core")},
+				{Namespace: "billing", Path: filepath.Join(root, "billing")},
+			}}}
+
+			g, err := ComputeDefinitionsFromConfig(cfg)
+			if tt.expectError != "" {
+				require.ErrorContains(t, err, tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+
+			order, err := g.TopologicalSort()
+			require.NoError(t, err)
+			require.Equal(t, []int64{1747578808, 1747578900}, order)
+
+			invoices, err := g.GetDefinition(1747578900)
+			require.NoError(t, err)
+			require.Equal(t, "billing", invoices.Namespace)
+			require.Equal(t, "billing:1747578900_create_invoices", invoices.QualifiedName())
+
+			leaves, err := namespaceLeaves(g, "core")
+			require.NoError(t, err)
+			require.Equal(t, []int64{1747578808}, leaves)
+		})
+	}
+}
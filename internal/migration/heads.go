@@ -0,0 +1,222 @@
+package migration
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Head is a migration that no other migration of its namespace depends on. A namespace
+// with several heads has branches that diverged, usually because two branches of the
+// code each added a migration.
+type Head struct {
+	types.Definition
+
+	// Branch lists the migrations, newest first, that only this head builds on. It is
+	// empty when the namespace has a single head.
+	Branch []types.Definition
+
+	// BranchedFrom lists the migrations the branch was started from, which the other heads
+	// of the namespace build on as well.
+	BranchedFrom []types.Definition
+}
+
+// Heads returns the heads of every namespace, ordered by namespace and timestamp.
+func Heads(cfg types.Config) ([]Head, error) {
+	g, err := ComputeDefinitionsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return computeHeads(g)
+}
+
+func computeHeads(g *graph.Graph) ([]Head, error) {
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, errors.Wrap(err, "sorting migrations")
+	}
+	position := make(map[int64]int, len(order))
+	namespaces := make(map[string]bool)
+	for i, ts := range order {
+		position[ts] = i
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return nil, err
+		}
+		namespaces[def.Namespace] = true
+	}
+
+	var heads []Head
+	for _, ns := range slices.Sorted(maps.Keys(namespaces)) {
+		leaves, err := namespaceLeaves(g, ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, leaf := range leaves {
+			head, err := newHead(g, leaf, leaves, position)
+			if err != nil {
+				return nil, err
+			}
+			heads = append(heads, head)
+		}
+	}
+	return heads, nil
+}
+
+// newHead describes the head leaf. The branch is found by walking up from the head until
+// reaching migrations that another head of the namespace also builds on.
+func newHead(g *graph.Graph, leaf int64, leaves []int64, position map[int64]int) (Head, error) {
+	def, err := g.GetDefinition(leaf)
+	if err != nil {
+		return Head{}, err
+	}
+	head := Head{Definition: def}
+	if len(leaves) < 2 {
+		return head, nil
+	}
+
+	shared := make(map[int64]bool)
+	for _, other := range leaves {
+		if other == leaf {
+			continue
+		}
+		if err := ancestors(g, other, shared); err != nil {
+			return Head{}, err
+		}
+	}
+
+	seen := map[int64]bool{leaf: true}
+	queue := slices.Clone(def.Parents)
+	for len(queue) > 0 {
+		ts := queue[0]
+		queue = queue[1:]
+		if seen[ts] {
+			continue
+		}
+		seen[ts] = true
+
+		parent, err := g.GetDefinition(ts)
+		if err != nil {
+			return Head{}, err
+		}
+		if shared[ts] {
+			head.BranchedFrom = append(head.BranchedFrom, parent)
+			continue
+		}
+		head.Branch = append(head.Branch, parent)
+		queue = append(queue, parent.Parents...)
+	}
+
+	newestFirst := func(a, b types.Definition) int {
+		return cmp.Compare(position[b.Timestamp], position[a.Timestamp])
+	}
+	slices.SortFunc(head.Branch, newestFirst)
+	slices.SortFunc(head.BranchedFrom, newestFirst)
+	return head, nil
+}
+
+// ancestors adds ts and every migration it depends on to seen.
+func ancestors(g *graph.Graph, ts int64, seen map[int64]bool) error {
+	if seen[ts] {
+		return nil
+	}
+	seen[ts] = true
+
+	def, err := g.GetDefinition(ts)
+	if err != nil {
+		return err
+	}
+	for _, parent := range def.Parents {
+		if err := ancestors(g, parent, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// divergedHeads returns the timestamps of the heads of every namespace that has more than
+// one, keyed by namespace.
+func divergedHeads(heads []Head) (namespaces []string, timestamps map[string][]int64) {
+	timestamps = make(map[string][]int64)
+	for _, h := range heads {
+		timestamps[h.Namespace] = append(timestamps[h.Namespace], h.Timestamp)
+	}
+	for _, h := range heads {
+		if len(timestamps[h.Namespace]) > 1 && !slices.Contains(namespaces, h.Namespace) {
+			namespaces = append(namespaces, h.Namespace)
+		}
+	}
+	return namespaces, timestamps
+}
+
+// requireSingleHead returns an error naming the heads to merge when a namespace has more
+// than one head.
+func requireSingleHead(heads []Head) error {
+	namespaces, timestamps := divergedHeads(heads)
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	ns := namespaces[0]
+	where := "the migration graph"
+	if ns != "" {
+		where = fmt.Sprintf("namespace %q", ns)
+	}
+	return errors.Newf("%s has %d heads (%s) but migration.require_single_head is set; run `kat merge %s` to merge them",
+		where, len(timestamps[ns]), joinTimestamps(timestamps[ns], ", "), joinTimestamps(timestamps[ns], " "))
+}
+
+func joinTimestamps(timestamps []int64, sep string) string {
+	parts := make([]string, len(timestamps))
+	for i, ts := range timestamps {
+		parts[i] = fmt.Sprint(ts)
+	}
+	return strings.Join(parts, sep)
+}
+
+// PrintHeads writes every head with the branch that leads to it, followed by the command
+// that merges them when a namespace has diverged.
+func PrintHeads(w io.Writer, heads []Head) error {
+	if len(heads) == 0 {
+		_, err := fmt.Fprintf(w, "%sNo migrations found.%s\n", output.StyleInfo, output.StyleReset)
+		return err
+	}
+
+	for i, h := range heads {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (head)\n", h.QualifiedName())
+		for _, def := range h.Branch {
+			fmt.Fprintf(w, "    %s\n", def.QualifiedName())
+		}
+		for _, def := range h.BranchedFrom {
+			fmt.Fprintf(w, "  branched from %s\n", def.QualifiedName())
+		}
+	}
+
+	namespaces, timestamps := divergedHeads(heads)
+	if len(namespaces) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, ns := range namespaces {
+		where := "The migration graph"
+		if ns != "" {
+			where = fmt.Sprintf("Namespace %q", ns)
+		}
+		if _, err := fmt.Fprintf(w, "%s%s has %d heads. Run `kat merge %s` to merge them.%s\n",
+			output.StyleInfo, where, len(timestamps[ns]), joinTimestamps(timestamps[ns], " "), output.StyleReset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
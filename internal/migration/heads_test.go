@@ -0,0 +1,146 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestComputeHeads(t *testing.T) {
+	spec := func(ts int64, name string, parents ...int64) MigrationSpec {
+		return MigrationSpec{
+			MigrationMetadata: types.MigrationMetadata{Name: name, Timestamp: ts, Parents: parents},
+			Up:                "SELECT 1;",
+			Down:              "SELECT 1;",
+		}
+	}
+
+	type head struct {
+		name         string
+		branch       []string
+		branchedFrom []string
+	}
+
+	tests := []struct {
+		name        string
+		specs       []MigrationSpec
+		expected    []head
+		expectedErr string
+	}{
+		{
+			name: "single head",
+			specs: []MigrationSpec{
+				spec(1, "create_users"),
+				spec(2, "add_email", 1),
+			},
+			expected: []head{{name: "2_add_email"}},
+		},
+		{
+			name: "diverged branches",
+			specs: []MigrationSpec{
+				spec(1, "create_users"),
+				spec(2, "create_posts", 1),
+				spec(3, "add_post_index", 2),
+				spec(4, "add_email", 1),
+			},
+			expected: []head{
+				{name: "3_add_post_index", branch: []string{"2_create_posts"}, branchedFrom: []string{"1_create_users"}},
+				{name: "4_add_email", branchedFrom: []string{"1_create_users"}},
+			},
+			expectedErr: "the migration graph has 2 heads (3, 4) but migration.require_single_head is set; run `kat merge 3 4` to merge them",
+		},
+		{
+			name: "merged branches",
+			specs: []MigrationSpec{
+				spec(1, "create_users"),
+				spec(2, "create_posts", 1),
+				spec(3, "add_email", 1),
+				spec(4, "merge", 2, 3),
+			},
+			expected: []head{{name: "4_merge"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ComputeDefinitionsFromSource(MemorySource(tt.specs...))
+			require.NoError(t, err)
+
+			heads, err := computeHeads(g)
+			require.NoError(t, err)
+
+			var actual []head
+			for _, h := range heads {
+				got := head{name: h.FileName()}
+				for _, def := range h.Branch {
+					got.branch = append(got.branch, def.FileName())
+				}
+				for _, def := range h.BranchedFrom {
+					got.branchedFrom = append(got.branchedFrom, def.FileName())
+				}
+				actual = append(actual, got)
+			}
+			require.Equal(t, tt.expected, actual)
+
+			err = requireSingleHead(heads)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestMergeParents(t *testing.T) {
+	heads := []Head{
+		{Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "create_users", Timestamp: 1, Namespace: "billing"}}},
+		{Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "add_email", Timestamp: 4, Namespace: "core"}}},
+		{Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "create_posts", Timestamp: 3, Namespace: "core"}}},
+	}
+
+	tests := []struct {
+		name              string
+		timestamps        []int64
+		expectedParents   []int64
+		expectedNamespace string
+		expectedErr       string
+	}{
+		{
+			name:              "heads of one namespace",
+			timestamps:        []int64{4, 3},
+			expectedParents:   []int64{3, 4},
+			expectedNamespace: "core",
+		},
+		{
+			name:        "not a head",
+			timestamps:  []int64{3, 2},
+			expectedErr: "migration 2 is not a head; run `kat heads` to list them",
+		},
+		{
+			name:        "repeated head",
+			timestamps:  []int64{3, 3},
+			expectedErr: "head 3 is given more than once",
+		},
+		{
+			name:        "different namespaces",
+			timestamps:  []int64{3, 1},
+			expectedErr: "cannot merge heads of different namespaces: core:3_create_posts and billing:1_create_users",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parents, namespace, err := mergeParents(heads, tt.timestamps)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedParents, parents)
+			require.Equal(t, tt.expectedNamespace, namespace)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/config"
+	"github.com/BolajiOlajide/kat/internal/database"
+	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/runner"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// ImportedMigration describes a migration created by Import.
+type ImportedMigration struct {
+	types.Definition
+	// Sources are the files the migration was converted from.
+	Sources []string
+	// Path is the directory of the new migration.
+	Path string
+	// Applied is true when the migration was recorded as applied in the tracking table.
+	Applied bool
+}
+
+// Import converts the migrations another tool keeps in dir into Kat migrations that
+// follow each other in a linear chain, built on the current heads of the namespace. With
+// --copy-history, the migrations the tool's tracking table lists as applied are recorded
+// as applied in Kat's tracking table so `kat up` doesn't run them again.
+func Import(c *cli.Context, toolName, dir string) ([]ImportedMigration, error) {
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return nil, err
+	}
+
+	tool, ok := importTools[toolName]
+	if !ok {
+		return nil, errors.Newf("unknown migration tool %q: must be one of %s", toolName, strings.Join(importToolNames(), ", "))
+	}
+
+	target, err := cfg.Migration.MigrationDirectory(c.String("namespace"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := tool.read(os.DirFS(dir))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s migrations in %s", toolName, dir)
+	}
+	if len(sources) == 0 {
+		return nil, errors.Newf("no %s migrations found in %s", toolName, dir)
+	}
+
+	src, err := configSource(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	defs, err := ComputeDefinitionsFromSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := planImport(defs, target.Namespace, toolName, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	copyHistory := c.Bool("copy-history")
+	var (
+		db      database.DB
+		applied map[string]time.Time
+	)
+	if copyHistory {
+		table := c.String("table")
+		if table == "" {
+			table = tool.table
+		}
+		if err := types.ValidateTableName(table); err != nil {
+			return nil, err
+		}
+
+		dbConn, err := cfg.Database.ConnString()
+		if err != nil {
+			return nil, err
+		}
+		dbConfig, err := DBConfigFromCfg(cfg)
+		if err != nil {
+			return nil, err
+		}
+		db, err = database.NewWithConfig(cfg.Database.Driver, dbConn, LoggerFromCtx(c), dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+
+		// Read the other tool's history before writing anything, so a missing table
+		// leaves the migrations directory untouched.
+		applied, err = tool.applied(c.Context, db, table, sources)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	written, err := writeImported(target.Path, imported, sources)
+	if err != nil {
+		return nil, err
+	}
+	if !copyHistory {
+		return imported, nil
+	}
+
+	if err := recordImported(c, cfg, db, imported, sources, applied); err != nil {
+		// Leave the project as it was so the import can be run again.
+		for _, path := range written {
+			_ = os.RemoveAll(path)
+		}
+		return nil, err
+	}
+	return imported, nil
+}
+
+// planImport returns the Kat migrations the migrations of another tool convert to. Each
+// one gets an ID derived from its version and the previous one as its only parent; the
+// first builds on the heads of namespace. A migration whose ID wouldn't sort after its
+// parents, such as a sequence number imported into a project that already has
+// migrations, gets a new ID instead, and so do the ones after it.
+func planImport(g *graph.Graph, namespace, toolName string, sources []importedMigration) ([]ImportedMigration, error) {
+	parents, err := namespaceLeaves(g, namespace)
+	if err != nil {
+		return nil, err
+	}
+	nextID := nextMigrationID(g)
+
+	imported := make([]ImportedMigration, 0, len(sources))
+	seen := make(map[int64]string, len(sources))
+	for _, source := range sources {
+		id, err := importedID(source.version)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot import %s", source.files[0])
+		}
+		if g.Has(id) {
+			def, err := g.GetDefinition(id)
+			if err != nil {
+				return nil, err
+			}
+			return nil, errors.Newf("cannot import %s: migration ID %d is already used by %s", source.files[0], id, def.QualifiedName())
+		}
+		if other, ok := seen[id]; ok {
+			return nil, errors.Newf("cannot import %s: it gets the same migration ID %d as %s", source.files[0], id, other)
+		}
+		if len(parents) > 0 && id <= slices.Max(parents) {
+			for nextID <= slices.Max(parents) || g.Has(nextID) {
+				nextID++
+			}
+			id = nextID
+			nextID++
+		}
+		seen[id] = source.files[0]
+
+		name := sanitizeName(strings.NewReplacer("-", "_", ".", "_").Replace(source.name))
+		if name == "" {
+			name = "migration"
+		}
+		imported = append(imported, ImportedMigration{
+			Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{
+				Name:          name,
+				Timestamp:     id,
+				Description:   fmt.Sprintf("Imported from %s: %s", toolName, strings.Join(source.files, ", ")),
+				Parents:       parents,
+				NoTransaction: source.noTransaction,
+				Namespace:     namespace,
+			}},
+			Sources: source.files,
+		})
+		parents = []int64{id}
+	}
+	return imported, nil
+}
+
+// importedID returns the Kat migration ID for a version of another tool. Versions that
+// are timestamps in the YYYYMMDDHHMMSS form, the default of most tools, become the
+// nanosecond ID of that time; other versions, such as sequence numbers or Unix
+// timestamps, are used as they are. planImport renumbers the IDs that would sort before
+// their parents.
+func importedID(version int64) (int64, error) {
+	if version <= 0 {
+		return 0, errors.Newf("invalid version %d: must be positive", version)
+	}
+	if s := strconv.FormatInt(version, 10); len(s) == 14 {
+		if t, err := time.Parse("20060102150405", s); err == nil {
+			return types.NewMigrationID(t), nil
+		}
+	}
+	return version, nil
+}
+
+// writeImported writes the imported migrations to dir and returns the directories it
+// created. Nothing is written if any of the directories already exists.
+func writeImported(dir string, imported []ImportedMigration, sources []importedMigration) ([]string, error) {
+	for i := range imported {
+		imported[i].Path = filepath.Join(dir, imported[i].FileName())
+		if _, err := os.Stat(imported[i].Path); err == nil {
+			return nil, errors.Newf("cannot import %s: %s already exists", sources[i].files[0], imported[i].Path)
+		}
+	}
+
+	written := make([]string, 0, len(imported))
+	for i, m := range imported {
+		files := newMigrationFiles(dir, m.Timestamp, m.Name)
+		if err := saveMigration(files, m.MigrationMetadata, sources[i].up+"\n", sources[i].down+"\n"); err != nil {
+			for _, path := range written {
+				_ = os.RemoveAll(path)
+			}
+			return nil, errors.Wrapf(err, "writing %s", m.Path)
+		}
+		written = append(written, m.Path)
+	}
+	return written, nil
+}
+
+// recordImported records the imported migrations the other tool applied in Kat's tracking
+// table and marks them as applied.
+func recordImported(c *cli.Context, cfg types.Config, db database.DB, imported []ImportedMigration, sources []importedMigration, applied map[string]time.Time) error {
+	defs, err := ComputeDefinitionsFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int64]time.Time, len(applied))
+	index := make(map[int64]int, len(imported))
+	for i, source := range sources {
+		if appliedAt, ok := applied[source.id]; ok {
+			byID[imported[i].Timestamp] = appliedAt
+			index[imported[i].Timestamp] = i
+		}
+	}
+
+	r, err := runner.NewRunner(c.Context, db, LoggerFromCtx(c))
+	if err != nil {
+		return err
+	}
+	recorded, err := r.MarkApplied(c.Context, runner.Options{
+		Definitions:   defs,
+		MigrationInfo: cfg.Migration,
+	}, byID)
+	if err != nil {
+		return err
+	}
+	for _, def := range recorded {
+		imported[index[def.Timestamp]].Applied = true
+	}
+	return nil
+}
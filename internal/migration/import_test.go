@@ -0,0 +1,230 @@
+package migration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/database"
+	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
+	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/loggr"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestImportTools(t *testing.T) {
+	tests := []struct {
+		name        string
+		tool        string
+		files       fstest.MapFS
+		expected    []importedMigration
+		expectedErr string
+	}{
+		{
+			name: "goose",
+			tool: "goose",
+			files: fstest.MapFS{
+				"00002_add_email.sql": {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY users_email ON users (email);\n\n-- +goose Down\nDROP INDEX users_email;\n")},
+				"00001_create_users.sql": {Data: []byte(`-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users (id SERIAL PRIMARY KEY);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE users;
+`)},
+				"README.md": {Data: []byte("not a migration")},
+			},
+			expected: []importedMigration{
+				{id: "1", version: 1, name: "create_users", files: []string{"00001_create_users.sql"}, up: "CREATE TABLE users (id SERIAL PRIMARY KEY);", down: "DROP TABLE users;"},
+				{id: "2", version: 2, name: "add_email", files: []string{"00002_add_email.sql"}, up: "CREATE INDEX CONCURRENTLY users_email ON users (email);", down: "DROP INDEX users_email;", noTransaction: true},
+			},
+		},
+		{
+			name: "goose go migration",
+			tool: "goose",
+			files: fstest.MapFS{
+				"00001_create_users.go": {Data: []byte("package migrations\n")},
+			},
+			expectedErr: "00001_create_users.go is a migration written in Go and cannot be imported; port it to SQL first",
+		},
+		{
+			name: "golang-migrate",
+			tool: "golang-migrate",
+			files: fstest.MapFS{
+				"1747578808_create-users.up.sql":   {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);\n")},
+				"1747578808_create-users.down.sql": {Data: []byte("DROP TABLE users;\n")},
+				"1747578900_seed.up.sql":           {Data: []byte("INSERT INTO users DEFAULT VALUES;\n")},
+			},
+			expected: []importedMigration{
+				{id: "1747578808", version: 1747578808, name: "create-users", files: []string{"1747578808_create-users.down.sql", "1747578808_create-users.up.sql"}, up: "CREATE TABLE users (id SERIAL PRIMARY KEY);", down: "DROP TABLE users;"},
+				{id: "1747578900", version: 1747578900, name: "seed", files: []string{"1747578900_seed.up.sql"}, up: "INSERT INTO users DEFAULT VALUES;"},
+			},
+		},
+		{
+			name: "golang-migrate without up",
+			tool: "golang-migrate",
+			files: fstest.MapFS{
+				"1_create_users.down.sql": {Data: []byte("DROP TABLE users;\n")},
+			},
+			expectedErr: "1_create_users.down.sql has no matching up migration",
+		},
+		{
+			name: "dbmate",
+			tool: "dbmate",
+			files: fstest.MapFS{
+				"20250518143328_create_users.sql": {Data: []byte("-- migrate:up transaction:false\nCREATE TABLE users (id SERIAL PRIMARY KEY);\n\n-- migrate:down\nDROP TABLE users;\n")},
+			},
+			expected: []importedMigration{
+				{id: "20250518143328", version: 20250518143328, name: "create_users", files: []string{"20250518143328_create_users.sql"}, up: "CREATE TABLE users (id SERIAL PRIMARY KEY);", down: "DROP TABLE users;", noTransaction: true},
+			},
+		},
+		{
+			name: "sql-migrate",
+			tool: "sql-migrate",
+			files: fstest.MapFS{
+				"1_create_users.sql": {Data: []byte("-- +migrate Up notransaction\nCREATE TABLE users (id SERIAL PRIMARY KEY);\n-- +migrate Down\nDROP TABLE users;\n")},
+			},
+			expected: []importedMigration{
+				{id: "1_create_users.sql", version: 1, name: "create_users", files: []string{"1_create_users.sql"}, up: "CREATE TABLE users (id SERIAL PRIMARY KEY);", down: "DROP TABLE users;", noTransaction: true},
+			},
+		},
+		{
+			name: "sql before the up marker",
+			tool: "sql-migrate",
+			files: fstest.MapFS{
+				"1_create_users.sql": {Data: []byte("-- setup\nCREATE TABLE users (id SERIAL PRIMARY KEY);\n")},
+			},
+			expectedErr: "1_create_users.sql: line 2: SQL must follow the up marker",
+		},
+		{
+			name: "duplicate version",
+			tool: "dbmate",
+			files: fstest.MapFS{
+				"1_create_users.sql":  {Data: []byte("-- migrate:up\nSELECT 1;\n")},
+				"01_create_posts.sql": {Data: []byte("-- migrate:up\nSELECT 1;\n")},
+			},
+			expectedErr: "version 1 is used by both 01_create_posts.sql and 1_create_users.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := importTools[tt.tool].read(tt.files)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, migrations)
+		})
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	g, err := ComputeDefinitionsFromSource(MemorySource(MigrationSpec{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_roles", Timestamp: 1747578000123456789},
+		Up:                "SELECT 1;",
+		Down:              "SELECT 1;",
+	}))
+	require.NoError(t, err)
+
+	imported, err := planImport(g, "", "dbmate", []importedMigration{
+		{id: "20250518143328", version: 20250518143328, name: "create-users", files: []string{"20250518143328_create-users.sql"}},
+		{id: "20250519090000", version: 20250519090000, name: "", files: []string{"20250519090000.sql"}, noTransaction: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+
+	first := time.Date(2025, 5, 18, 14, 33, 28, 0, time.UTC).UnixNano()
+	require.Equal(t, types.MigrationMetadata{
+		Name:        "create_users",
+		Timestamp:   first,
+		Description: "Imported from dbmate: 20250518143328_create-users.sql",
+		Parents:     []int64{1747578000123456789},
+	}, imported[0].MigrationMetadata)
+	require.Equal(t, types.MigrationMetadata{
+		Name:          "migration",
+		Timestamp:     time.Date(2025, 5, 19, 9, 0, 0, 0, time.UTC).UnixNano(),
+		Description:   "Imported from dbmate: 20250519090000.sql",
+		Parents:       []int64{first},
+		NoTransaction: true,
+	}, imported[1].MigrationMetadata)
+
+	// Sequence numbers would sort before the migrations they build on, so they get new IDs.
+	imported, err = planImport(g, "", "goose", []importedMigration{
+		{id: "1", version: 1, name: "g1", files: []string{"00001_g1.sql"}},
+		{id: "2", version: 2, name: "g2", files: []string{"00002_g2.sql"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, imported, 2)
+	require.Greater(t, imported[0].Timestamp, int64(1747578000123456789))
+	require.Equal(t, []int64{1747578000123456789}, imported[0].Parents)
+	require.Greater(t, imported[1].Timestamp, imported[0].Timestamp)
+	require.Equal(t, []int64{imported[0].Timestamp}, imported[1].Parents)
+
+	specs := []MigrationSpec{{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_roles", Timestamp: 1747578000123456789},
+		Up:                "SELECT 1;",
+		Down:              "SELECT 1;",
+	}}
+	for _, m := range imported {
+		specs = append(specs, MigrationSpec{MigrationMetadata: m.MigrationMetadata, Up: "SELECT 1;", Down: "SELECT 1;"})
+	}
+	reloaded, err := ComputeDefinitionsFromSource(MemorySource(specs...))
+	require.NoError(t, err)
+	order, err := reloaded.TopologicalSort()
+	require.NoError(t, err)
+	require.Equal(t, []int64{1747578000123456789, imported[0].Timestamp, imported[1].Timestamp}, order)
+
+	// In a project without migrations, sequence numbers are kept.
+	imported, err = planImport(graph.New(), "", "goose", []importedMigration{
+		{id: "1", version: 1, name: "g1", files: []string{"00001_g1.sql"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), imported[0].Timestamp)
+
+	_, err = planImport(g, "", "goose", []importedMigration{
+		{id: "1747578000123456789", version: 1747578000123456789, name: "create_roles", files: []string{"1747578000123456789_create_roles.sql"}},
+	})
+	require.EqualError(t, err, "cannot import 1747578000123456789_create_roles.sql: migration ID 1747578000123456789 is already used by 1747578000123456789_create_roles")
+}
+
+func TestImportApplied(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(dbdriver.SqliteDriver, filepath.Join(t.TempDir(), "import.db"), loggr.NewDefault())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE goose_db_version (id INTEGER PRIMARY KEY AUTOINCREMENT, version_id INTEGER NOT NULL, is_applied INTEGER NOT NULL, tstamp TIMESTAMP DEFAULT (datetime('now')))`,
+		`INSERT INTO goose_db_version (version_id, is_applied, tstamp) VALUES (0, 1, '2021-03-04 05:06:07'), (1, 1, '2021-03-04 05:06:07'), (2, 1, '2021-03-05 05:06:07'), (2, 0, '2021-03-06 05:06:07')`,
+		`CREATE TABLE schema_migrations (version INTEGER NOT NULL, dirty INTEGER NOT NULL)`,
+		`INSERT INTO schema_migrations VALUES (2, 0)`,
+		`CREATE TABLE gorp_migrations (id TEXT PRIMARY KEY, applied_at TEXT)`,
+		`INSERT INTO gorp_migrations VALUES ('1_create_users.sql', '2021-03-04T05:06:07Z')`,
+	} {
+		require.NoError(t, db.Exec(ctx, sqlf.Sprintf(q)))
+	}
+
+	migrations := []importedMigration{{id: "1", version: 1}, {id: "2", version: 2}, {id: "3", version: 3}}
+	appliedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	applied, err := gooseApplied(ctx, db, "goose_db_version", migrations)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	require.True(t, applied["1"].Equal(appliedAt), "got %s", applied["1"])
+
+	applied, err = golangMigrateApplied(ctx, db, "schema_migrations", migrations)
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Time{"1": {}, "2": {}}, applied)
+
+	applied, err = sqlMigrateApplied(ctx, db, "gorp_migrations", nil)
+	require.NoError(t, err)
+	require.True(t, applied["1_create_users.sql"].Equal(appliedAt), "got %s", applied["1_create_users.sql"])
+}
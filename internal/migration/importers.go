@@ -0,0 +1,490 @@
+package migration
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/keegancsmith/sqlf"
+
+	"github.com/BolajiOlajide/kat/internal/database"
+)
+
+// importedMigration is a migration read from the files of another migration tool.
+type importedMigration struct {
+	// id identifies the migration in the other tool's tracking table: its version, or its
+	// file name for sql-migrate.
+	id      string
+	version int64
+	name    string
+	// files are the names of the files the migration was read from.
+	files         []string
+	up, down      string
+	noTransaction bool
+}
+
+// importTool describes the file layout and tracking table of another migration tool.
+type importTool struct {
+	// table is the name of the tool's tracking table.
+	table string
+	// read returns the migrations in fsys, sorted by version.
+	read func(fsys fs.FS) ([]importedMigration, error)
+	// applied returns the time each of migrations was applied at, keyed by id, as recorded
+	// in the tool's tracking table. A zero time means the tool doesn't record it.
+	applied func(ctx context.Context, db database.DB, table string, migrations []importedMigration) (map[string]time.Time, error)
+}
+
+// importTools are the migration tools `kat import` converts from.
+var importTools = map[string]importTool{
+	"goose": {
+		table: "goose_db_version",
+		read: func(fsys fs.FS) ([]importedMigration, error) {
+			return readAnnotatedMigrations(fsys, parseGooseLine, false)
+		},
+		applied: gooseApplied,
+	},
+	"golang-migrate": {
+		table:   "schema_migrations",
+		read:    readGolangMigrate,
+		applied: golangMigrateApplied,
+	},
+	"dbmate": {
+		table: "schema_migrations",
+		read: func(fsys fs.FS) ([]importedMigration, error) {
+			return readAnnotatedMigrations(fsys, parseDbmateLine, false)
+		},
+		applied: dbmateApplied,
+	},
+	"sql-migrate": {
+		table: "gorp_migrations",
+		read: func(fsys fs.FS) ([]importedMigration, error) {
+			return readAnnotatedMigrations(fsys, parseSQLMigrateLine, true)
+		},
+		applied: sqlMigrateApplied,
+	},
+}
+
+// importToolNames returns the names of the tools `kat import` converts from.
+func importToolNames() []string {
+	names := make([]string, 0, len(importTools))
+	for name := range importTools {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+var (
+	// versionedFileName matches `<version>_<name>.sql`, the file names used by goose,
+	// dbmate and, by convention, sql-migrate.
+	versionedFileName = regexp.MustCompile(`^(\d+)_?(.*)\.sql$`)
+	// golangMigrateFileName matches `<version>_<name>.up.sql` and `<version>_<name>.down.sql`.
+	golangMigrateFileName = regexp.MustCompile(`^(\d+)_?(.*)\.(up|down)\.sql$`)
+)
+
+// sectionMarker is the meaning of a line in a migration file that holds both directions.
+type sectionMarker int
+
+const (
+	sqlLine sectionMarker = iota
+	upMarker
+	downMarker
+	// skipLine is an annotation without SQL meaning, such as goose's StatementBegin.
+	skipLine
+)
+
+// lineParser classifies a line of a migration file, trimmed of surrounding spaces, and
+// reports whether it turns off transactions for the migration.
+type lineParser func(line string) (marker sectionMarker, noTransaction bool, err error)
+
+// parseGooseLine parses the `-- +goose` annotations.
+func parseGooseLine(line string) (sectionMarker, bool, error) {
+	annotation, ok := strings.CutPrefix(line, "-- +goose ")
+	if !ok {
+		return sqlLine, false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(annotation)) {
+	case "up":
+		return upMarker, false, nil
+	case "down":
+		return downMarker, false, nil
+	case "statementbegin", "statementend":
+		return skipLine, false, nil
+	case "no transaction":
+		return skipLine, true, nil
+	}
+	return sqlLine, false, errors.Newf("unsupported goose annotation %q", line)
+}
+
+// parseSQLMigrateLine parses the `-- +migrate` annotations.
+func parseSQLMigrateLine(line string) (sectionMarker, bool, error) {
+	annotation, ok := strings.CutPrefix(line, "-- +migrate ")
+	if !ok {
+		return sqlLine, false, nil
+	}
+	fields := strings.Fields(annotation)
+	marker := sqlLine
+	switch strings.ToLower(fields[0]) {
+	case "up":
+		marker = upMarker
+	case "down":
+		marker = downMarker
+	case "statementbegin", "statementend":
+		return skipLine, false, nil
+	default:
+		return sqlLine, false, errors.Newf("unsupported sql-migrate annotation %q", line)
+	}
+	var noTransaction bool
+	for _, option := range fields[1:] {
+		if option != "notransaction" {
+			return sqlLine, false, errors.Newf("unsupported sql-migrate option %q", option)
+		}
+		noTransaction = true
+	}
+	return marker, noTransaction, nil
+}
+
+// parseDbmateLine parses the `-- migrate:up` and `-- migrate:down` markers.
+func parseDbmateLine(line string) (sectionMarker, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "--" {
+		return sqlLine, false, nil
+	}
+	marker := sqlLine
+	switch fields[1] {
+	case "migrate:up":
+		marker = upMarker
+	case "migrate:down":
+		marker = downMarker
+	default:
+		return sqlLine, false, nil
+	}
+	var noTransaction bool
+	for _, option := range fields[2:] {
+		if option != "transaction:false" {
+			return sqlLine, false, errors.Newf("unsupported dbmate option %q", option)
+		}
+		noTransaction = true
+	}
+	return marker, noTransaction, nil
+}
+
+// readAnnotatedMigrations reads migrations stored one per file, with annotations that
+// separate the up and down SQL. With byFileName, migrations are identified by their file
+// name in the tool's tracking table instead of their version.
+func readAnnotatedMigrations(fsys fs.FS, parse lineParser, byFileName bool) ([]importedMigration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "reading migrations")
+	}
+
+	var migrations []importedMigration
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch path.Ext(entry.Name()) {
+		case ".sql":
+		case ".go":
+			if !strings.HasSuffix(entry.Name(), "_test.go") {
+				return nil, errors.Newf("%s is a migration written in Go and cannot be imported; port it to SQL first", entry.Name())
+			}
+			continue
+		default:
+			continue
+		}
+
+		match := versionedFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, errors.Newf("%s is not named <version>_<name>.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", entry.Name())
+		}
+		up, down, noTransaction, err := splitSections(string(content), parse)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", entry.Name())
+		}
+
+		id := strconv.FormatInt(version, 10)
+		if byFileName {
+			id = entry.Name()
+		}
+		migrations = append(migrations, importedMigration{
+			id:            id,
+			version:       version,
+			name:          match[2],
+			files:         []string{entry.Name()},
+			up:            up,
+			down:          down,
+			noTransaction: noTransaction,
+		})
+	}
+	return sortImported(migrations)
+}
+
+// splitSections splits the content of a migration file into its up and down SQL.
+func splitSections(content string, parse lineParser) (up, down string, noTransaction bool, err error) {
+	var (
+		section        = sqlLine
+		upSQL, downSQL strings.Builder
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+
+		marker, noTx, err := parse(trimmed)
+		if err != nil {
+			return "", "", false, errors.Wrapf(err, "line %d", line)
+		}
+		noTransaction = noTransaction || noTx
+
+		switch marker {
+		case upMarker:
+			if section != sqlLine {
+				return "", "", false, errors.Newf("line %d: unexpected %q", line, trimmed)
+			}
+			section = upMarker
+		case downMarker:
+			if section != upMarker {
+				return "", "", false, errors.Newf("line %d: %q must follow the up marker", line, trimmed)
+			}
+			section = downMarker
+		case skipLine:
+		default:
+			switch section {
+			case upMarker:
+				upSQL.WriteString(text + "\n")
+			case downMarker:
+				downSQL.WriteString(text + "\n")
+			default:
+				if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+					return "", "", false, errors.Newf("line %d: SQL must follow the up marker", line)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", false, err
+	}
+	if section == sqlLine {
+		return "", "", false, errors.New("missing the up marker")
+	}
+	return strings.TrimSpace(upSQL.String()), strings.TrimSpace(downSQL.String()), noTransaction, nil
+}
+
+// readGolangMigrate reads golang-migrate migrations, stored as pairs of
+// `<version>_<name>.up.sql` and `<version>_<name>.down.sql` files.
+func readGolangMigrate(fsys fs.FS) ([]importedMigration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.Wrap(err, "reading migrations")
+	}
+
+	byVersion := map[int64]*importedMigration{}
+	for _, entry := range entries {
+		match := golangMigrateFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid version in %s", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &importedMigration{id: strconv.FormatInt(version, 10), version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		m.files = append(m.files, entry.Name())
+		if match[3] == "up" {
+			m.up = strings.TrimSpace(string(content))
+		} else {
+			m.down = strings.TrimSpace(string(content))
+		}
+	}
+
+	migrations := make([]importedMigration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(m.files) > 2 {
+			return nil, errors.Newf("version %d is used by %s", m.version, strings.Join(m.files, ", "))
+		}
+		if !slices.ContainsFunc(m.files, func(file string) bool { return strings.HasSuffix(file, ".up.sql") }) {
+			return nil, errors.Newf("%s has no matching up migration", m.files[0])
+		}
+		migrations = append(migrations, *m)
+	}
+	return sortImported(migrations)
+}
+
+// sortImported sorts migrations by version and checks that no version is used twice.
+func sortImported(migrations []importedMigration) ([]importedMigration, error) {
+	slices.SortFunc(migrations, func(a, b importedMigration) int {
+		return cmp.Or(cmp.Compare(a.version, b.version), strings.Compare(a.files[0], b.files[0]))
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, errors.Newf("version %d is used by both %s and %s", migrations[i].version, migrations[i-1].files[0], migrations[i].files[0])
+		}
+	}
+	return migrations, nil
+}
+
+// gooseApplied reads goose_db_version, which logs every up and down run. A version is
+// applied when its latest entry says so.
+func gooseApplied(ctx context.Context, db database.DB, table string, _ []importedMigration) (map[string]time.Time, error) {
+	rows, err := db.Query(ctx, sqlf.Sprintf(fmt.Sprintf(`SELECT version_id, is_applied, tstamp FROM %q ORDER BY id`, table)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", table)
+	}
+	defer rows.Close()
+
+	applied := map[string]time.Time{}
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			tstamp    any
+		)
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, errors.Wrapf(err, "reading %s", table)
+		}
+		// goose records version 0 when it creates its table.
+		if version == 0 {
+			continue
+		}
+		id := strconv.FormatInt(version, 10)
+		if isApplied {
+			applied[id] = appliedTime(tstamp)
+		} else {
+			delete(applied, id)
+		}
+	}
+	return applied, rows.Err()
+}
+
+// golangMigrateApplied reads schema_migrations, which holds only the current version:
+// every migration up to it is applied.
+func golangMigrateApplied(ctx context.Context, db database.DB, table string, migrations []importedMigration) (map[string]time.Time, error) {
+	var (
+		current int64
+		dirty   bool
+	)
+	err := db.QueryRow(ctx, sqlf.Sprintf(fmt.Sprintf(`SELECT version, dirty FROM %q`, table))).Scan(&current, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return map[string]time.Time{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", table)
+	}
+	if dirty {
+		return nil, errors.Newf("golang-migrate marks version %d as dirty; fix the database and force a clean version before importing", current)
+	}
+
+	applied := map[string]time.Time{}
+	for _, m := range migrations {
+		if m.version <= current {
+			applied[m.id] = time.Time{}
+		}
+	}
+	return applied, nil
+}
+
+// dbmateApplied reads schema_migrations, which holds one row per applied version.
+func dbmateApplied(ctx context.Context, db database.DB, table string, _ []importedMigration) (map[string]time.Time, error) {
+	rows, err := db.Query(ctx, sqlf.Sprintf(fmt.Sprintf(`SELECT version FROM %q`, table)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", table)
+	}
+	defer rows.Close()
+
+	applied := map[string]time.Time{}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, errors.Wrapf(err, "reading %s", table)
+		}
+		// Versions are strings, so normalize leading zeros.
+		if n, err := strconv.ParseInt(strings.TrimSpace(version), 10, 64); err == nil {
+			version = strconv.FormatInt(n, 10)
+		}
+		applied[version] = time.Time{}
+	}
+	return applied, rows.Err()
+}
+
+// sqlMigrateApplied reads gorp_migrations, which holds the file name of every applied
+// migration.
+func sqlMigrateApplied(ctx context.Context, db database.DB, table string, _ []importedMigration) (map[string]time.Time, error) {
+	rows, err := db.Query(ctx, sqlf.Sprintf(fmt.Sprintf(`SELECT id, applied_at FROM %q`, table)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", table)
+	}
+	defer rows.Close()
+
+	applied := map[string]time.Time{}
+	for rows.Next() {
+		var (
+			id        string
+			appliedAt any
+		)
+		if err := rows.Scan(&id, &appliedAt); err != nil {
+			return nil, errors.Wrapf(err, "reading %s", table)
+		}
+		applied[id] = appliedTime(appliedAt)
+	}
+	return applied, rows.Err()
+}
+
+// appliedTimeFormats are the formats timestamps are stored in as TEXT (SQLite).
+var appliedTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// appliedTime converts a timestamp read from a tracking table. It returns the zero time
+// when the value can't be understood.
+func appliedTime(v any) time.Time {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return v
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return time.Time{}
+	}
+	for _, layout := range appliedTimeFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
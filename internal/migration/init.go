@@ -11,7 +11,6 @@ import (
 
 	"github.com/BolajiOlajide/kat/internal/constants"
 	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
-	"github.com/BolajiOlajide/kat/internal/output"
 )
 
 // configData holds the template data for generating a kat configuration file.
@@ -45,23 +44,24 @@ func generateConfigFile(data configData) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// Init initializes a project for use with kat.
-func Init(c *cli.Context) (err error) {
+// Init initializes a project for use with kat and returns the path of the configuration
+// file it created.
+func Init(c *cli.Context) (_ string, err error) {
 	wd, err := os.Getwd()
 	if err != nil {
-		return errors.Wrap(err, "getting working directory")
+		return "", errors.Wrap(err, "getting working directory")
 	}
 
 	configFilePath := fmt.Sprintf("%s/%s", wd, constants.KatConfigurationFileName)
 
 	_, err = os.Stat(configFilePath)
 	if !os.IsNotExist(err) {
-		return errors.New("kat is already initialized")
+		return "", errors.New("kat is already initialized")
 	}
 
 	driver, err := dbdriver.ParseDBDriver(c.String("driver"))
 	if err != nil {
-		return errors.Wrap(err, "parsing driver flag")
+		return "", errors.Wrap(err, "parsing driver flag")
 	}
 
 	// Get parameters from CLI context
@@ -128,7 +128,7 @@ func Init(c *cli.Context) (err error) {
 		Driver:        driver,
 	})
 	if err != nil {
-		return errors.Wrap(err, "generating config file")
+		return "", errors.Wrap(err, "generating config file")
 	}
 
 	// Note: For backward compatibility, we would normally create a config struct here,
@@ -138,10 +138,8 @@ func Init(c *cli.Context) (err error) {
 	// Save the generated config to file
 	err = os.WriteFile(constants.KatConfigurationFileName, configContent, os.FileMode(0755))
 	if err != nil {
-		return errors.Wrap(err, "writing configuration file")
+		return "", errors.Wrap(err, "writing configuration file")
 	}
 
-	fmt.Printf("%sKat initialized successfully!%s\n", output.StyleSuccess, output.StyleReset)
-	fmt.Printf("%sConfig file: %s%s\n", output.StyleInfo, configFilePath, output.StyleReset)
-	return nil
+	return configFilePath, nil
 }
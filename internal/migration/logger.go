@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/config"
+	"github.com/BolajiOlajide/kat/internal/loggr"
+	"github.com/BolajiOlajide/kat/internal/output"
+)
+
+// OutputFormatFromCtx returns the output format selected with the global --output flag.
+// Unknown or missing values fall back to text.
+func OutputFormatFromCtx(c *cli.Context) output.Format {
+	f, err := output.ParseFormat(c.String("output"))
+	if err != nil {
+		return output.FormatText
+	}
+	return f
+}
+
+// LoggerFromCtx returns the logger a CLI command should use. In JSON mode stdout is
+// reserved for the command's result, so logs are written to stderr as JSON lines. Debug
+// messages are only shown with --verbose or `verbose: true` in kat.conf.yaml.
+func LoggerFromCtx(c *cli.Context) loggr.StructuredLogger {
+	verbose := c.Bool("verbose")
+	if cfg, err := config.GetKatConfigFromCtx(c); err == nil {
+		// The loaded configuration already takes --verbose into account.
+		verbose = cfg.Verbose
+	}
+
+	if OutputFormatFromCtx(c) != output.FormatJSON {
+		if verbose {
+			return loggr.NewVerbose()
+		}
+		return loggr.NewDefault()
+	}
+
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/config"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Merge creates an empty migration whose parents are the given heads, joining the
+// branches that lead to them back into one. If there was an error, the filesystem is
+// rolled-back.
+func Merge(c *cli.Context, timestamps []int64) (types.TemporaryMigrationInfo, error) {
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	if len(timestamps) < 2 {
+		return types.TemporaryMigrationInfo{}, errors.New("at least two heads are needed to merge")
+	}
+
+	defs, err := ComputeDefinitionsFromConfig(cfg)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+	heads, err := computeHeads(defs)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	parents, namespace, err := mergeParents(heads, timestamps)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	dir, err := cfg.Migration.MigrationDirectory(namespace)
+	if err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	name := sanitizeName(c.String("name"))
+	if name == "" {
+		return types.TemporaryMigrationInfo{}, errors.New("merge migration name cannot be empty")
+	}
+	description := c.String("description")
+	if description == "" {
+		description = fmt.Sprintf("Merge heads %s", joinTimestamps(parents, ", "))
+	}
+
+	timestamp := nextMigrationID(defs)
+	m := newMigrationFiles(dir.Path, timestamp, name)
+	md := types.MigrationMetadata{
+		Name:        name,
+		Timestamp:   timestamp,
+		Description: description,
+		Parents:     parents,
+	}
+
+	if err := saveMigration(m, md, mergeMigrationFileTemplate, mergeMigrationFileTemplate); err != nil {
+		return types.TemporaryMigrationInfo{}, err
+	}
+
+	return m, nil
+}
+
+// mergeParents checks that timestamps are distinct heads of one namespace and returns
+// them sorted together with that namespace.
+func mergeParents(heads []Head, timestamps []int64) ([]int64, string, error) {
+	byTimestamp := make(map[int64]Head, len(heads))
+	for _, h := range heads {
+		byTimestamp[h.Timestamp] = h
+	}
+
+	var namespace string
+	parents := make([]int64, 0, len(timestamps))
+	for i, ts := range timestamps {
+		head, ok := byTimestamp[ts]
+		if !ok {
+			return nil, "", errors.Newf("migration %d is not a head; run `kat heads` to list them", ts)
+		}
+		if slices.Contains(parents, ts) {
+			return nil, "", errors.Newf("head %d is given more than once", ts)
+		}
+		if i == 0 {
+			namespace = head.Namespace
+		} else if head.Namespace != namespace {
+			return nil, "", errors.Newf("cannot merge heads of different namespaces: %s and %s",
+				byTimestamp[timestamps[0]].QualifiedName(), head.QualifiedName())
+		}
+		parents = append(parents, ts)
+	}
+
+	slices.Sort(parents)
+	return parents, namespace, nil
+}
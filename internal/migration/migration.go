@@ -24,7 +24,7 @@ import (
 // FilePerm is the standard permission for migration files (readable by all, writable by owner)
 const FilePerm = 0644
 
-func saveMigration(m types.TemporaryMigrationInfo, metadata types.MigrationMetadata) (err error) {
+func saveMigration(m types.TemporaryMigrationInfo, metadata types.MigrationMetadata, up, down string) (err error) {
 	defer func() {
 		if err != nil {
 			// undo any changes to the fs on error. we don't care about the errors here.
@@ -41,8 +41,8 @@ func saveMigration(m types.TemporaryMigrationInfo, metadata types.MigrationMetad
 	}
 
 	// Prepare all file contents
-	upContent := []byte(upMigrationFileTemplate)
-	downContent := []byte(downMigrationFileTemplate)
+	upContent := []byte(up)
+	downContent := []byte(down)
 	metadataContent, err := yaml.Marshal(&metadata)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal metadata")
@@ -64,4 +64,26 @@ func saveMigration(m types.TemporaryMigrationInfo, metadata types.MigrationMetad
 	return nil
 }
 
+// saveSingleFileMigration writes a single-file migration to path.
+func saveSingleFileMigration(path string, metadata types.MigrationMetadata, up, down string) error {
+	content, err := formatSingleFileMigration(metadata, up, down)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return errors.Wrap(err, "failed to create migrations directory")
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(FilePerm))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", filepath.Base(path))
+	}
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return errors.Wrapf(err, "failed to write %s", filepath.Base(path))
+	}
+	return f.Close()
+}
+
 var nonAlphaNumericOrUnderscore = regexp.MustCompile("[^a-z0-9_]+")
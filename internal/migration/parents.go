@@ -0,0 +1,187 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mattn/go-isatty"
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// pickerSize is the number of recent migrations the parent picker lists.
+const pickerSize = 10
+
+// addParents returns the parents of a migration created by `kat add` in namespace, as
+// chosen with --parent or --no-parent. Without either flag the migration builds on the
+// namespace's heads; when stdin is a terminal, the user picks, with the heads preselected.
+func addParents(c *cli.Context, g *graph.Graph, namespace string) ([]int64, map[int64]string, error) {
+	refs := c.StringSlice("parent")
+	if c.Bool("no-parent") {
+		if len(refs) > 0 {
+			return nil, nil, errors.New("--parent and --no-parent cannot be combined")
+		}
+		return nil, nil, nil
+	}
+	if len(refs) > 0 {
+		return resolveParents(g, namespace, refs)
+	}
+
+	heads, err := namespaceLeaves(g, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(heads) == 0 || OutputFormatFromCtx(c) != output.FormatText || !isTerminal(os.Stdin) {
+		return heads, nil, nil
+	}
+
+	parents, err := pickParents(os.Stdin, os.Stdout, g, namespace, heads)
+	return parents, nil, err
+}
+
+// resolveParents looks up each parent reference in the graph. A reference is a
+// timestamp, a `namespace:timestamp` pair, or a migration name. Parents in another
+// namespace than the new migration's are qualified with their namespace.
+func resolveParents(g *graph.Graph, namespace string, refs []string) ([]int64, map[int64]string, error) {
+	var (
+		parents    []int64
+		namespaces map[int64]string
+	)
+	for _, ref := range refs {
+		def, err := resolveParent(g, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		if slices.Contains(parents, def.Timestamp) {
+			return nil, nil, errors.Newf("parent %s is given more than once", def.QualifiedName())
+		}
+		parents = append(parents, def.Timestamp)
+		if def.Namespace != namespace && def.Namespace != "" {
+			if namespaces == nil {
+				namespaces = map[int64]string{}
+			}
+			namespaces[def.Timestamp] = def.Namespace
+		}
+	}
+	slices.Sort(parents)
+	return parents, namespaces, nil
+}
+
+func resolveParent(g *graph.Graph, ref string) (types.Definition, error) {
+	if ns, ts, err := types.ParseParentRef(ref); err == nil {
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return types.Definition{}, errors.Newf("parent %q does not exist", ref)
+		}
+		if ns != "" && def.Namespace != ns {
+			return types.Definition{}, errors.Newf("parent %q is in namespace %q, not %q", ref, def.Namespace, ns)
+		}
+		return def, nil
+	}
+
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return types.Definition{}, errors.Wrap(err, "sorting migrations")
+	}
+	var matches []types.Definition
+	for _, ts := range order {
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return types.Definition{}, err
+		}
+		if def.Name == ref || def.FileName() == ref || def.QualifiedName() == ref || def.Namespace+":"+def.Name == ref {
+			matches = append(matches, def)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return types.Definition{}, errors.Newf("parent %q does not exist", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, def := range matches {
+			names[i] = def.QualifiedName()
+		}
+		return types.Definition{}, errors.Newf("parent %q is ambiguous: it matches %s", ref, strings.Join(names, ", "))
+	}
+}
+
+// pickParents lists the most recent migrations of namespace on out and reads the
+// parents chosen by the user from in. The heads are marked and chosen by default.
+func pickParents(in io.Reader, out io.Writer, g *graph.Graph, namespace string, heads []int64) ([]int64, error) {
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, errors.Wrap(err, "sorting migrations")
+	}
+
+	var recent []types.Definition
+	for i := len(order) - 1; i >= 0 && len(recent) < pickerSize; i-- {
+		def, err := g.GetDefinition(order[i])
+		if err != nil {
+			return nil, err
+		}
+		if def.Namespace == namespace {
+			recent = append(recent, def)
+		}
+	}
+	// Heads older than the listed migrations still have to be selectable.
+	for _, head := range heads {
+		if !slices.ContainsFunc(recent, func(def types.Definition) bool { return def.Timestamp == head }) {
+			def, err := g.GetDefinition(head)
+			if err != nil {
+				return nil, err
+			}
+			recent = append(recent, def)
+		}
+	}
+
+	fmt.Fprintf(out, "%sChoose the parents of the new migration:%s\n", output.StyleInfo, output.StyleReset)
+	for i, def := range recent {
+		mark := " "
+		if slices.Contains(heads, def.Timestamp) {
+			mark = "*"
+		}
+		fmt.Fprintf(out, "  %s %2d) %s\n", mark, i+1, def.QualifiedName())
+	}
+	fmt.Fprint(out, "Numbers separated by spaces (Enter for the heads marked *, 0 for none): ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "reading parents")
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return heads, nil
+	}
+	if line == "0" {
+		return nil, nil
+	}
+
+	var parents []int64
+	for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == ',' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(recent) {
+			return nil, errors.Newf("invalid choice %q: must be a number between 1 and %d", field, len(recent))
+		}
+		if ts := recent[n-1].Timestamp; !slices.Contains(parents, ts) {
+			parents = append(parents, ts)
+		}
+	}
+	slices.Sort(parents)
+	return parents, nil
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
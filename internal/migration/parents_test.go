@@ -0,0 +1,151 @@
+package migration
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func parentsTestGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+
+	spec := func(ns string, ts int64, name string, parents ...int64) MigrationSpec {
+		return MigrationSpec{
+			MigrationMetadata: types.MigrationMetadata{Name: name, Timestamp: ts, Parents: parents, Namespace: ns},
+			Up:                "SELECT 1;",
+			Down:              "SELECT 1;",
+		}
+	}
+
+	g, err := ComputeDefinitionsFromSource(MemorySource(
+		spec("core", 1, "create_users"),
+		spec("core", 2, "create_posts", 1),
+		spec("core", 3, "add_email", 1),
+		spec("billing", 4, "create_invoices"),
+		spec("billing", 5, "create_users"),
+	))
+	require.NoError(t, err)
+	return g
+}
+
+func TestResolveParents(t *testing.T) {
+	tests := []struct {
+		name               string
+		refs               []string
+		expectedParents    []int64
+		expectedNamespaces map[int64]string
+		expectedErr        string
+	}{
+		{
+			name:            "timestamps and names",
+			refs:            []string{"3", "create_posts"},
+			expectedParents: []int64{2, 3},
+		},
+		{
+			name:               "parent in another namespace",
+			refs:               []string{"2", "billing:create_invoices"},
+			expectedParents:    []int64{2, 4},
+			expectedNamespaces: map[int64]string{4: "billing"},
+		},
+		{
+			name:        "ambiguous name",
+			refs:        []string{"create_users"},
+			expectedErr: `parent "create_users" is ambiguous: it matches core:1_create_users, billing:5_create_users`,
+		},
+		{
+			name:        "unknown parent",
+			refs:        []string{"42"},
+			expectedErr: `parent "42" does not exist`,
+		},
+		{
+			name:        "wrong namespace",
+			refs:        []string{"billing:1"},
+			expectedErr: `parent "billing:1" is in namespace "core", not "billing"`,
+		},
+		{
+			name:        "repeated parent",
+			refs:        []string{"2", "core:create_posts"},
+			expectedErr: "parent core:2_create_posts is given more than once",
+		},
+	}
+
+	g := parentsTestGraph(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parents, namespaces, err := resolveParents(g, "core", tt.refs)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedParents, parents)
+			require.Equal(t, tt.expectedNamespaces, namespaces)
+		})
+	}
+}
+
+func TestPickParents(t *testing.T) {
+	tests := []struct {
+		name        string
+		heads       []int64
+		input       string
+		expected    []int64
+		expectedErr string
+	}{
+		{
+			name:     "enter keeps the heads",
+			input:    "\n",
+			expected: []int64{2, 3},
+		},
+		{
+			name:     "enter keeps a single head",
+			heads:    []int64{3},
+			input:    "\n",
+			expected: []int64{3},
+		},
+		{
+			name:     "pick one migration",
+			input:    "2\n",
+			expected: []int64{2},
+		},
+		{
+			name:     "pick several migrations",
+			input:    "3, 1\n",
+			expected: []int64{1, 3},
+		},
+		{
+			name:  "no parents",
+			input: "0\n",
+		},
+		{
+			name:        "out of range",
+			input:       "9\n",
+			expectedErr: `invalid choice "9": must be a number between 1 and 3`,
+		},
+	}
+
+	g := parentsTestGraph(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heads := tt.heads
+			if heads == nil {
+				heads = []int64{2, 3}
+			}
+
+			var out bytes.Buffer
+			parents, err := pickParents(strings.NewReader(tt.input), &out, g, "core", heads)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, parents)
+			require.Contains(t, out.String(), "*  1) core:3_add_email")
+		})
+	}
+}
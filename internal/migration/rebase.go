@@ -0,0 +1,307 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/BolajiOlajide/kat/internal/config"
+	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// RebasedMigration describes a migration rewritten by Rebase.
+type RebasedMigration struct {
+	// From is the migration before the rebase.
+	From types.Definition
+	// To is the migration after the rebase.
+	To types.Definition
+	// Path is the migration's directory, or file for single-file migrations, after the
+	// rebase.
+	Path string
+}
+
+// Rebase moves the given migrations on top of the current heads of their namespace by
+// rewriting the parents in their metadata. With --renumber, the migrations also get new
+// IDs and their directories or files are renamed to match. Unless --offline is given, Rebase
+// refuses to touch migrations recorded as applied in the database.
+func Rebase(c *cli.Context, timestamps []int64) ([]RebasedMigration, error) {
+	cfg, err := config.GetKatConfigFromCtx(c)
+	if err != nil {
+		return nil, err
+	}
+
+	defs, err := ComputeDefinitionsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstID int64
+	if c.Bool("renumber") {
+		firstID = nextMigrationID(defs)
+	}
+	plan, err := planRebase(defs, timestamps, firstID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Bool("offline") {
+		if err := checkNotApplied(c, cfg, plan); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := cfg.Migration.MigrationDirectory(plan[0].From.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find every migration before changing any of them.
+	froms := make([]string, len(plan))
+	for i := range plan {
+		from, to := filepath.Join(dir.Path, plan[i].From.FileName()), filepath.Join(dir.Path, plan[i].To.FileName())
+		if _, err := os.Stat(from); err != nil {
+			from = filepath.Join(dir.Path, singleFileMigrationName(plan[i].From.Timestamp, plan[i].From.Name))
+			to = filepath.Join(dir.Path, singleFileMigrationName(plan[i].To.Timestamp, plan[i].To.Name))
+			if _, err := os.Stat(from); err != nil {
+				return nil, errors.Newf("cannot find the directory or file of migration %s in %s", plan[i].From.FileName(), dir.Path)
+			}
+		}
+		if from != to {
+			if _, err := os.Stat(to); err == nil {
+				return nil, errors.Newf("cannot rename %s: %s already exists", from, to)
+			}
+		}
+		froms[i], plan[i].Path = from, to
+	}
+
+	for i, m := range plan {
+		if err := rewriteMetadata(froms[i], m.To.MigrationMetadata); err != nil {
+			return nil, errors.Wrapf(err, "failed to rewrite metadata of %s", m.From.FileName())
+		}
+		if froms[i] != m.Path {
+			if err := os.Rename(froms[i], m.Path); err != nil {
+				return nil, errors.Wrapf(err, "failed to rename %s", froms[i])
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// rewriteMetadata replaces the metadata of the migration at path, which is either a
+// migration directory or a single-file migration.
+func rewriteMetadata(path string, metadata types.MigrationMetadata) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		content, err := yaml.Marshal(&metadata)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal metadata")
+		}
+		return os.WriteFile(filepath.Join(path, "metadata.yaml"), content, os.FileMode(FilePerm))
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rewritten, err := rewriteSingleFileHeader(string(content), metadata)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(rewritten), info.Mode().Perm())
+}
+
+// checkNotApplied returns an error if any migration in plan is recorded as applied in
+// the database.
+func checkNotApplied(c *cli.Context, cfg types.Config, plan []RebasedMigration) error {
+	statuses, err := Status(c, cfg)
+	if err != nil {
+		return errors.Wrap(err, "checking which migrations are applied (use --offline to skip the check)")
+	}
+
+	applied := make(map[string]bool, len(statuses))
+	for _, s := range statuses {
+		if s.Applied() {
+			applied[s.Definition.FileName()] = true
+		}
+	}
+	for _, m := range plan {
+		if applied[m.From.FileName()] {
+			return errors.Newf("migration %s is applied in the database and cannot be rebased", m.From.QualifiedName())
+		}
+	}
+	return nil
+}
+
+// planRebase computes the new metadata of the migrations with the given timestamps. The
+// migrations that don't depend on another rebased migration get the heads of the rest of
+// their namespace as parents; parents in other namespaces are kept. When firstID is not
+// zero, the migrations are renumbered in dependency order starting at firstID.
+func planRebase(g *graph.Graph, timestamps []int64, firstID int64) ([]RebasedMigration, error) {
+	if len(timestamps) == 0 {
+		return nil, errors.New("no migrations to rebase")
+	}
+
+	rebased := make(map[int64]bool, len(timestamps))
+	var first types.Definition
+	for i, ts := range timestamps {
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return nil, errors.Newf("migration %d does not exist", ts)
+		}
+		if i == 0 {
+			first = def
+		} else if def.Namespace != first.Namespace {
+			return nil, errors.Newf("cannot rebase migrations of different namespaces together: %s and %s",
+				first.QualifiedName(), def.QualifiedName())
+		}
+		rebased[ts] = true
+	}
+	namespace := first.Namespace
+
+	adj, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting adjacency map")
+	}
+
+	// Everything that depends on a rebased migration has to move with it, otherwise the
+	// new parents could depend on their own children.
+	for _, ts := range timestamps {
+		for child := range adj[ts] {
+			if rebased[child] {
+				continue
+			}
+			childDef, err := g.GetDefinition(child)
+			if err != nil {
+				return nil, err
+			}
+			parentDef, err := g.GetDefinition(ts)
+			if err != nil {
+				return nil, err
+			}
+			return nil, errors.Newf("migration %s depends on %s; include it in the rebase",
+				childDef.QualifiedName(), parentDef.QualifiedName())
+		}
+	}
+
+	heads, err := baseHeads(g, namespace, rebased)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := g.TopologicalSort()
+	if err != nil {
+		return nil, errors.Wrap(err, "sorting migrations")
+	}
+
+	newIDs := make(map[int64]int64, len(rebased))
+	nextID := firstID
+	var plan []RebasedMigration
+	for _, ts := range order {
+		if !rebased[ts] {
+			continue
+		}
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return nil, err
+		}
+
+		to := def
+		to.Parents = nil
+		to.ParentNamespaces = nil
+		root := true
+		for _, parent := range def.Parents {
+			parentDef, err := g.GetDefinition(parent)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case rebased[parent]:
+				root = false
+				to.MigrationMetadata = withParent(to.MigrationMetadata, newIDs[parent], def.ParentNamespaces[parent])
+			case parentDef.Namespace != namespace:
+				to.MigrationMetadata = withParent(to.MigrationMetadata, parent, def.ParentNamespaces[parent])
+			}
+		}
+		if root {
+			for _, head := range heads {
+				to.MigrationMetadata = withParent(to.MigrationMetadata, head, "")
+			}
+		}
+		slices.Sort(to.Parents)
+
+		newIDs[ts] = ts
+		if firstID != 0 {
+			for g.Has(nextID) {
+				nextID++
+			}
+			to.Timestamp = nextID
+			newIDs[ts] = nextID
+			nextID++
+		}
+
+		plan = append(plan, RebasedMigration{From: def, To: to})
+	}
+	return plan, nil
+}
+
+// baseHeads returns the migrations of namespace that no migration of the namespace
+// depends on once the rebased migrations are left out.
+func baseHeads(g *graph.Graph, namespace string, rebased map[int64]bool) ([]int64, error) {
+	adj, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting adjacency map")
+	}
+
+	var heads []int64
+	for ts, children := range adj {
+		if rebased[ts] {
+			continue
+		}
+		def, err := g.GetDefinition(ts)
+		if err != nil {
+			return nil, err
+		}
+		if def.Namespace != namespace {
+			continue
+		}
+
+		head := true
+		for child := range children {
+			childDef, err := g.GetDefinition(child)
+			if err != nil {
+				return nil, err
+			}
+			if childDef.Namespace == namespace && !rebased[child] {
+				head = false
+				break
+			}
+		}
+		if head {
+			heads = append(heads, ts)
+		}
+	}
+	slices.Sort(heads)
+	return heads, nil
+}
+
+// withParent adds parent to md's parents, keeping its namespace qualifier if it had one.
+func withParent(md types.MigrationMetadata, parent int64, namespace string) types.MigrationMetadata {
+	md.Parents = append(md.Parents, parent)
+	if namespace != "" {
+		if md.ParentNamespaces == nil {
+			md.ParentNamespaces = map[int64]string{}
+		}
+		md.ParentNamespaces[parent] = namespace
+	}
+	return md
+}
@@ -0,0 +1,105 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestPlanRebase(t *testing.T) {
+	spec := func(ts int64, name string, parents ...int64) MigrationSpec {
+		return MigrationSpec{
+			MigrationMetadata: types.MigrationMetadata{Name: name, Timestamp: ts, Parents: parents},
+			Up:                "SELECT 1;",
+			Down:              "SELECT 1;",
+		}
+	}
+
+	// 1 <- 2 <- 5 is main; 3 <- 4 is a feature branch started from 1.
+	specs := []MigrationSpec{
+		spec(1, "create_users"),
+		spec(2, "create_posts", 1),
+		spec(3, "add_email", 1),
+		spec(4, "index_email", 3),
+		spec(5, "add_post_index", 2),
+	}
+
+	type rebased struct {
+		from    string
+		to      string
+		parents []int64
+	}
+
+	tests := []struct {
+		name        string
+		timestamps  []int64
+		firstID     int64
+		expected    []rebased
+		expectedErr string
+	}{
+		{
+			name:       "moves the branch onto the head of main",
+			timestamps: []int64{3, 4},
+			expected: []rebased{
+				{from: "3_add_email", to: "3_add_email", parents: []int64{5}},
+				{from: "4_index_email", to: "4_index_email", parents: []int64{3}},
+			},
+		},
+		{
+			name:       "renumbers in dependency order",
+			timestamps: []int64{4, 3},
+			firstID:    10,
+			expected: []rebased{
+				{from: "3_add_email", to: "10_add_email", parents: []int64{5}},
+				{from: "4_index_email", to: "11_index_email", parents: []int64{10}},
+			},
+		},
+		{
+			name:        "children must move too",
+			timestamps:  []int64{3},
+			expectedErr: "migration 4_index_email depends on 3_add_email; include it in the rebase",
+		},
+		{
+			name:        "unknown migration",
+			timestamps:  []int64{42},
+			expectedErr: "migration 42 does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ComputeDefinitionsFromSource(MemorySource(specs...))
+			require.NoError(t, err)
+
+			plan, err := planRebase(g, tt.timestamps, tt.firstID)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var actual []rebased
+			for _, m := range plan {
+				actual = append(actual, rebased{from: m.From.FileName(), to: m.To.FileName(), parents: m.To.Parents})
+			}
+			require.Equal(t, tt.expected, actual)
+
+			// The rewritten migrations must still load.
+			rebased := make(map[int64]types.MigrationMetadata, len(plan))
+			for _, m := range plan {
+				rebased[m.From.Timestamp] = m.To.MigrationMetadata
+			}
+			var reloaded []MigrationSpec
+			for _, s := range specs {
+				if md, ok := rebased[s.Timestamp]; ok {
+					s.MigrationMetadata = md
+				}
+				reloaded = append(reloaded, s)
+			}
+			_, err = ComputeDefinitionsFromSource(MemorySource(reloaded...))
+			require.NoError(t, err)
+		})
+	}
+}
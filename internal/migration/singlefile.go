@@ -0,0 +1,253 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Markers that start the sections of a single-file migration.
+const (
+	singleFileUpMarker   = "-- +kat Up"
+	singleFileDownMarker = "-- +kat Down"
+)
+
+// singleFileMetadataKeys are the metadata.yaml fields a single-file migration's header
+// can set.
+var singleFileMetadataKeys = []string{"name", "timestamp", "description", "parents", "no_transaction", "irreversible"}
+
+// errNotKatMigration is returned by readMigrationEntry for a `<timestamp>_<name>.sql` file
+// without any `+kat` annotation, such as a seed script kept next to the migrations. Such
+// files aren't migrations and are ignored.
+var errNotKatMigration = errors.New("SQL file is ignored because it has no \"-- +kat Up\" marker")
+
+// singleFileName matches the name of a single-file migration: `<timestamp>_<name>.sql`.
+var singleFileName = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+// isMigrationEntry reports whether an entry at the root of a migrations directory holds
+// a migration: either a migration directory or a single-file migration.
+func isMigrationEntry(file fs.FileInfo) bool {
+	return file.IsDir() || singleFileName.MatchString(file.Name())
+}
+
+// readMigrationEntry reads the metadata and SQL of the migration stored in the directory
+// or single file called name.
+func readMigrationEntry(f fs.FS, file fs.FileInfo) (types.MigrationMetadata, string, string, error) {
+	if file.IsDir() {
+		return readMigration(f, file.Name())
+	}
+
+	content, err := fs.ReadFile(f, file.Name())
+	if err != nil {
+		return types.MigrationMetadata{}, "", "", errors.Wrapf(err, "failed to read migration %s", file.Name())
+	}
+	if !strings.Contains(strings.ToLower(string(content)), "+kat") {
+		return types.MigrationMetadata{}, "", "", errNotKatMigration
+	}
+	return parseSingleFileMigration(file.Name(), string(content))
+}
+
+// parseSingleFileMigration parses a migration stored in one SQL file. The file starts
+// with an optional header of comment lines, followed by the up SQL after a `-- +kat Up`
+// line and the down SQL after a `-- +kat Down` line. Header lines starting with a
+// metadata.yaml field, and the lines continuing them, hold the metadata; other comments
+// are ignored. The name and timestamp default to the ones in the file name.
+func parseSingleFileMigration(filename, content string) (types.MigrationMetadata, string, string, error) {
+	match := singleFileName.FindStringSubmatch(filename)
+	if match == nil {
+		return types.MigrationMetadata{}, "", "", errors.Newf("invalid migration file name %s: must be <timestamp>_<name>.sql", filename)
+	}
+
+	const (
+		inHeader = iota
+		inUp
+		inDown
+	)
+	var (
+		section        = inHeader
+		header         []string
+		up, down       strings.Builder
+		sawUp, sawDown bool
+	)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case strings.EqualFold(trimmed, singleFileUpMarker):
+			if sawUp {
+				return types.MigrationMetadata{}, "", "", errors.Newf("migration %s: line %d: duplicate %q marker", filename, line, singleFileUpMarker)
+			}
+			if sawDown {
+				return types.MigrationMetadata{}, "", "", errors.Newf("migration %s: line %d: %q must come before %q", filename, line, singleFileUpMarker, singleFileDownMarker)
+			}
+			sawUp, section = true, inUp
+		case strings.EqualFold(trimmed, singleFileDownMarker):
+			if sawDown {
+				return types.MigrationMetadata{}, "", "", errors.Newf("migration %s: line %d: duplicate %q marker", filename, line, singleFileDownMarker)
+			}
+			if !sawUp {
+				return types.MigrationMetadata{}, "", "", errors.Newf("migration %s: line %d: %q must come before %q", filename, line, singleFileUpMarker, singleFileDownMarker)
+			}
+			sawDown, section = true, inDown
+		case section == inHeader:
+			if trimmed == "" {
+				continue
+			}
+			comment, ok := strings.CutPrefix(trimmed, "--")
+			if !ok {
+				return types.MigrationMetadata{}, "", "", errors.Newf("migration %s: line %d: SQL must follow the %q marker", filename, line, singleFileUpMarker)
+			}
+			header = append(header, strings.TrimPrefix(comment, " "))
+		case section == inUp:
+			up.WriteString(text)
+			up.WriteByte('\n')
+		default:
+			down.WriteString(text)
+			down.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return types.MigrationMetadata{}, "", "", errors.Wrapf(err, "failed to read migration %s", filename)
+	}
+	if !sawUp {
+		return types.MigrationMetadata{}, "", "", errors.Newf("migration %s: missing %q marker", filename, singleFileUpMarker)
+	}
+
+	var yamlHeader strings.Builder
+	for i, isMetadata := range metadataLines(header) {
+		if isMetadata {
+			yamlHeader.WriteString(header[i] + "\n")
+		}
+	}
+	metadata, err := parseMetadata([]byte(yamlHeader.String()))
+	if err != nil {
+		return types.MigrationMetadata{}, "", "", errors.Wrapf(err, "parsing the metadata header of migration %s", filename)
+	}
+	if metadata.Timestamp == 0 {
+		metadata.Timestamp, err = strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return types.MigrationMetadata{}, "", "", errors.Wrapf(err, "invalid timestamp in migration file name %s", filename)
+		}
+	}
+	if metadata.Name == "" {
+		metadata.Name = match[2]
+	}
+
+	return metadata, strings.TrimSpace(up.String()), strings.TrimSpace(down.String()), nil
+}
+
+// metadataLines reports which of the comment lines of a single-file migration header,
+// without their `--` prefix, hold metadata: the lines starting with one of
+// singleFileMetadataKeys and the indented or list lines that continue them.
+func metadataLines(comments []string) []bool {
+	isMetadata := make([]bool, len(comments))
+	inField := false
+	for i, comment := range comments {
+		switch {
+		case isMetadataKey(comment):
+			inField = true
+		case inField && (strings.HasPrefix(comment, " ") || strings.HasPrefix(comment, "\t") || strings.HasPrefix(comment, "- ")):
+		default:
+			inField = false
+		}
+		isMetadata[i] = inField
+	}
+	return isMetadata
+}
+
+// isMetadataKey reports whether a header comment starts with a metadata field.
+func isMetadataKey(comment string) bool {
+	key, value, ok := strings.Cut(comment, ":")
+	if !ok || (value != "" && value[0] != ' ' && value[0] != '\t') {
+		return false
+	}
+	return slices.Contains(singleFileMetadataKeys, key)
+}
+
+// singleFileMigrationName returns the file name of a single-file migration.
+func singleFileMigrationName(timestamp int64, name string) string {
+	return fmt.Sprintf("%d_%s.sql", timestamp, name)
+}
+
+// formatSingleFileMigration returns the content of a single-file migration.
+func formatSingleFileMigration(metadata types.MigrationMetadata, up, down string) (string, error) {
+	header, err := singleFileHeader(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	b.WriteString(singleFileUpMarker + "\n")
+	b.WriteString(strings.TrimRight(up, "\n") + "\n\n")
+	b.WriteString(singleFileDownMarker + "\n")
+	b.WriteString(strings.TrimRight(down, "\n") + "\n")
+	return b.String(), nil
+}
+
+// rewriteSingleFileHeader replaces the metadata in the header of a single-file migration
+// and keeps its other comments and its SQL as written.
+func rewriteSingleFileHeader(content string, metadata types.MigrationMetadata) (string, error) {
+	header, err := singleFileHeader(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	var lines, comments []string
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.EqualFold(trimmed, singleFileUpMarker) {
+			var b strings.Builder
+			for i, isMetadata := range metadataLines(comments) {
+				if !isMetadata {
+					b.WriteString(strings.TrimRight(lines[i], "\n") + "\n")
+				}
+			}
+			if b.Len() > 0 && header == "" {
+				b.WriteString("\n")
+			}
+			return b.String() + header + content[offset:], nil
+		}
+		if comment, ok := strings.CutPrefix(trimmed, "--"); ok {
+			lines = append(lines, line)
+			comments = append(comments, strings.TrimPrefix(comment, " "))
+		}
+		offset += len(line)
+	}
+	return "", errors.Newf("missing %q marker", singleFileUpMarker)
+}
+
+// singleFileHeader returns the metadata of a single-file migration as comment lines. The
+// name and timestamp are left out because the file name holds them.
+func singleFileHeader(metadata types.MigrationMetadata) (string, error) {
+	content, err := yaml.Marshal(&metadata)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal metadata")
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		if strings.HasPrefix(line, "name:") || strings.HasPrefix(line, "timestamp:") {
+			continue
+		}
+		b.WriteString("-- " + line + "\n")
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,167 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestParseSingleFileMigration(t *testing.T) {
+	tests := []struct {
+		name         string
+		filename     string
+		content      string
+		expected     types.MigrationMetadata
+		expectedUp   string
+		expectedDown string
+		expectedErr  string
+	}{
+		{
+			name:     "header and both sections",
+			filename: "1747578808123456789_create_users.sql",
+			content: `-- description: Create the users table
+-- parents: [1747578000123456789, core:1747577000]
+-- no_transaction: true
+
+-- +kat Up
+CREATE TABLE users (id SERIAL PRIMARY KEY);
+
+-- +kat Down
+DROP TABLE users;
+`,
+			expected: types.MigrationMetadata{
+				Name:             "create_users",
+				Timestamp:        1747578808123456789,
+				Description:      "Create the users table",
+				Parents:          []int64{1747578000123456789, 1747577000},
+				NoTransaction:    true,
+				ParentNamespaces: map[int64]string{1747577000: "core"},
+			},
+			expectedUp:   "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+			expectedDown: "DROP TABLE users;",
+		},
+		{
+			name:     "free-form comments in the header",
+			filename: "1747578808123456789_create_users.sql",
+			content: `-- Adds the users table for the auth service
+-- See: https://example.com/auth
+-- parents:
+--   - 1747578000123456789
+-- TODO drop the legacy table afterwards
+
+-- +kat Up
+CREATE TABLE users (id SERIAL PRIMARY KEY);
+`,
+			expected: types.MigrationMetadata{
+				Name:      "create_users",
+				Timestamp: 1747578808123456789,
+				Parents:   []int64{1747578000123456789},
+			},
+			expectedUp: "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		},
+		{
+			name:       "no header or down section",
+			filename:   "1747578808_seed.sql",
+			content:    "-- +kat up\nINSERT INTO roles VALUES (1);\n",
+			expected:   types.MigrationMetadata{Name: "seed", Timestamp: 1747578808},
+			expectedUp: "INSERT INTO roles VALUES (1);",
+		},
+		{
+			name:        "missing up marker",
+			filename:    "1747578808_seed.sql",
+			content:     "INSERT INTO roles VALUES (1);\n",
+			expectedErr: `migration 1747578808_seed.sql: line 1: SQL must follow the "-- +kat Up" marker`,
+		},
+		{
+			name:        "down before up",
+			filename:    "1747578808_seed.sql",
+			content:     "-- +kat Down\nDELETE FROM roles;\n-- +kat Up\nINSERT INTO roles VALUES (1);\n",
+			expectedErr: `migration 1747578808_seed.sql: line 1: "-- +kat Up" must come before "-- +kat Down"`,
+		},
+		{
+			name:        "empty file",
+			filename:    "1747578808_seed.sql",
+			expectedErr: `migration 1747578808_seed.sql: missing "-- +kat Up" marker`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, up, down, err := parseSingleFileMigration(tt.filename, tt.content)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, md)
+			require.Equal(t, tt.expectedUp, up)
+			require.Equal(t, tt.expectedDown, down)
+		})
+	}
+}
+
+func TestSingleFileMigrationRoundTrip(t *testing.T) {
+	md := types.MigrationMetadata{
+		Name:          "add_email",
+		Timestamp:     1747578808123456789,
+		Description:   "Add an email column",
+		Parents:       []int64{1747578000123456789},
+		NoTransaction: true,
+	}
+
+	content, err := formatSingleFileMigration(md, "ALTER TABLE users ADD COLUMN email TEXT;\n", "ALTER TABLE users DROP COLUMN email;\n")
+	require.NoError(t, err)
+	require.Equal(t, `-- description: Add an email column
+-- parents: [1747578000123456789]
+-- no_transaction: true
+
+-- +kat Up
+ALTER TABLE users ADD COLUMN email TEXT;
+
+-- +kat Down
+ALTER TABLE users DROP COLUMN email;
+`, content)
+
+	// Directory and single-file migrations load side by side.
+	g, err := ComputeDefinitions(fstest.MapFS{
+		"1747578000123456789_create_users/up.sql":        {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);\n")},
+		"1747578000123456789_create_users/down.sql":      {Data: []byte("DROP TABLE users;\n")},
+		"1747578000123456789_create_users/metadata.yaml": {Data: []byte("name: create_users\ntimestamp: 1747578000123456789\n")},
+		"1747578808123456789_add_email.sql":              {Data: []byte(content)},
+		"README.md":                                      {Data: []byte("not a migration")},
+		"001_seed.sql":                                   {Data: []byte("INSERT INTO users DEFAULT VALUES;\n")},
+	})
+	require.NoError(t, err)
+	order, err := g.Order()
+	require.NoError(t, err)
+	require.Equal(t, 2, order)
+	def, err := g.GetDefinition(md.Timestamp)
+	require.NoError(t, err)
+	require.Equal(t, md, def.MigrationMetadata)
+
+	md.Parents = []int64{1747578500123456789}
+	rewritten, err := rewriteSingleFileHeader(content, md)
+	require.NoError(t, err)
+	parsed, up, _, err := parseSingleFileMigration("1747578808123456789_add_email.sql", rewritten)
+	require.NoError(t, err)
+	require.Equal(t, md, parsed)
+	require.Equal(t, "ALTER TABLE users ADD COLUMN email TEXT;", up)
+
+	// Comments that aren't metadata survive the rewrite.
+	rewritten, err = rewriteSingleFileHeader("-- Adds the users.email column\n"+content, md)
+	require.NoError(t, err)
+	require.Equal(t, `-- Adds the users.email column
+-- description: Add an email column
+-- parents: [1747578500123456789]
+-- no_transaction: true
+
+-- +kat Up
+ALTER TABLE users ADD COLUMN email TEXT;
+
+-- +kat Down
+ALTER TABLE users DROP COLUMN email;
+`, rewritten)
+}
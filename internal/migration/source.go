@@ -0,0 +1,184 @@
+package migration
+
+import (
+	"io/fs"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Source provides migrations. Migrations lists every migration the source holds and
+// ReadSQL returns the SQL of one of them.
+type Source interface {
+	// Migrations returns the metadata of every migration in the source.
+	Migrations() ([]types.MigrationMetadata, error)
+
+	// ReadSQL returns the up and down SQL of a migration returned by Migrations.
+	ReadSQL(types.MigrationMetadata) (up string, down string, err error)
+}
+
+// DirSource returns a Source for Kat's directory layout: one directory per migration,
+// each holding up.sql, down.sql and metadata.yaml, or a single `<timestamp>_<name>.sql`
+// file per migration with `-- +kat Up` and `-- +kat Down` sections. Other files at the
+// root of f, including SQL files without `+kat` annotations, are ignored.
+func DirSource(f fs.FS) Source {
+	return &dirSource{f: f}
+}
+
+type dirSource struct {
+	f fs.FS
+
+	// sql holds the up and down SQL of each migration, keyed by timestamp. It is filled
+	// by Migrations.
+	sql map[int64][2]string
+}
+
+func (s *dirSource) Migrations() ([]types.MigrationMetadata, error) {
+	mf, err := extractMigrationFiles(s.f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.sql = make(map[int64][2]string, len(mf))
+	var migrations []types.MigrationMetadata
+	for _, file := range mf {
+		if !isMigrationEntry(file) {
+			// Kat expects migrations to live in directories or in single SQL files named
+			// after them, so this is one of the most important validations we can have
+			continue
+		}
+
+		payload, up, down, err := readMigrationEntry(s.f, file)
+		if errors.Is(err, errNotKatMigration) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.sql[payload.Timestamp] = [2]string{up, down}
+		migrations = append(migrations, payload)
+	}
+	return migrations, nil
+}
+
+func (s *dirSource) ReadSQL(m types.MigrationMetadata) (string, string, error) {
+	if s.sql == nil {
+		if _, err := s.Migrations(); err != nil {
+			return "", "", err
+		}
+	}
+	queries, ok := s.sql[m.Timestamp]
+	if !ok {
+		return "", "", errors.Newf("migration %d_%s not found", m.Timestamp, m.Name)
+	}
+	return queries[0], queries[1], nil
+}
+
+// NamespacedSource returns a Source that tags every migration of src with namespace.
+func NamespacedSource(namespace string, src Source) Source {
+	return namespacedSource{namespace: namespace, src: src}
+}
+
+type namespacedSource struct {
+	namespace string
+	src       Source
+}
+
+func (s namespacedSource) Migrations() ([]types.MigrationMetadata, error) {
+	migrations, err := s.src.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	for i := range migrations {
+		migrations[i].Namespace = s.namespace
+	}
+	return migrations, nil
+}
+
+func (s namespacedSource) ReadSQL(m types.MigrationMetadata) (string, string, error) {
+	return s.src.ReadSQL(m)
+}
+
+// MigrationSpec is a migration held in memory: its metadata and its SQL.
+type MigrationSpec struct {
+	types.MigrationMetadata
+
+	Up   string
+	Down string
+}
+
+// MemorySource returns a Source holding specs. It is mostly useful in tests and for
+// migrations generated at build time.
+func MemorySource(specs ...MigrationSpec) Source {
+	return memorySource(specs)
+}
+
+type memorySource []MigrationSpec
+
+func (s memorySource) Migrations() ([]types.MigrationMetadata, error) {
+	migrations := make([]types.MigrationMetadata, 0, len(s))
+	for _, spec := range s {
+		migrations = append(migrations, spec.MigrationMetadata)
+	}
+	return migrations, nil
+}
+
+func (s memorySource) ReadSQL(m types.MigrationMetadata) (string, string, error) {
+	for _, spec := range s {
+		if spec.Timestamp == m.Timestamp {
+			return spec.Up, spec.Down, nil
+		}
+	}
+	return "", "", errors.Newf("migration %d_%s not found", m.Timestamp, m.Name)
+}
+
+// MergeSources returns a Source holding the migrations of every source. Listing the
+// merged source fails if two migrations share a timestamp, even within one source.
+func MergeSources(sources ...Source) Source {
+	return &mergedSource{sources: sources}
+}
+
+type mergedSource struct {
+	sources []Source
+
+	// owners maps each migration's timestamp to the source that provides it. It is
+	// filled by Migrations.
+	owners map[int64]Source
+}
+
+func (s *mergedSource) Migrations() ([]types.MigrationMetadata, error) {
+	s.owners = map[int64]Source{}
+	seen := map[int64]types.MigrationMetadata{}
+
+	var migrations []types.MigrationMetadata
+	for _, src := range s.sources {
+		list, err := src.Migrations()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range list {
+			if existing, ok := seen[m.Timestamp]; ok {
+				return nil, errors.Newf("duplicate migration timestamp %d: %s and %s", m.Timestamp, existing.QualifiedName(), m.QualifiedName())
+			}
+			seen[m.Timestamp] = m
+			s.owners[m.Timestamp] = src
+			migrations = append(migrations, m)
+		}
+	}
+	return migrations, nil
+}
+
+func (s *mergedSource) ReadSQL(m types.MigrationMetadata) (string, string, error) {
+	if s.owners == nil {
+		if _, err := s.Migrations(); err != nil {
+			return "", "", err
+		}
+	}
+	src, ok := s.owners[m.Timestamp]
+	if !ok {
+		return "", "", errors.Newf("migration %d_%s not found", m.Timestamp, m.Name)
+	}
+	return src.ReadSQL(m)
+}
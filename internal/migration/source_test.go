@@ -0,0 +1,78 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestMergeSources(t *testing.T) {
+	dir := DirSource(fstest.MapFS{
+		"1651234567/up.sql":        {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);\n")},
+		"1651234567/down.sql":      {Data: []byte("DROP TABLE users;\n")},
+		"1651234567/metadata.yaml": {Data: []byte("name: create_users\ntimestamp: 1651234567\nparents: []\n")},
+	})
+	generated := MemorySource(MigrationSpec{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_posts", Timestamp: 1651234568, Parents: []int64{1651234567}},
+		Up:                "CREATE TABLE posts (id SERIAL PRIMARY KEY);",
+		Down:              "DROP TABLE posts;",
+	})
+	duplicate := MemorySource(MigrationSpec{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_comments", Timestamp: 1651234568},
+	})
+
+	tests := []struct {
+		name        string
+		sources     []Source
+		expectError string
+		expectedUp  map[int64]string
+	}{
+		{
+			name:    "directory and memory sources",
+			sources: []Source{dir, generated},
+			expectedUp: map[int64]string{
+				1651234567: "CREATE TABLE users (id SERIAL PRIMARY KEY);\n",
+				1651234568: "CREATE TABLE posts (id SERIAL PRIMARY KEY);",
+			},
+		},
+		{
+			name:        "duplicate timestamps across sources",
+			sources:     []Source{dir, generated, duplicate},
+			expectError: "duplicate migration timestamp 1651234568: 1651234568_create_posts and 1651234568_create_comments",
+		},
+		{
+			name:        "duplicate timestamps within a source",
+			sources:     []Source{MemorySource(MigrationSpec{MigrationMetadata: types.MigrationMetadata{Name: "a", Timestamp: 1}}, MigrationSpec{MigrationMetadata: types.MigrationMetadata{Name: "b", Timestamp: 1}})},
+			expectError: "duplicate migration timestamp 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := MergeSources(tt.sources...)
+
+			migrations, err := src.Migrations()
+			if tt.expectError != "" {
+				require.ErrorContains(t, err, tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, migrations, len(tt.expectedUp))
+
+			for _, m := range migrations {
+				up, _, err := src.ReadSQL(m)
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedUp[m.Timestamp], up)
+			}
+
+			g, err := ComputeDefinitionsFromSource(src)
+			require.NoError(t, err)
+			order, err := g.TopologicalSort()
+			require.NoError(t, err)
+			require.Equal(t, []int64{1651234567, 1651234568}, order)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/BolajiOlajide/kat/internal/database"
+	"github.com/BolajiOlajide/kat/internal/output"
+	"github.com/BolajiOlajide/kat/internal/runner"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Status is the command that returns every migration and whether it has been applied.
+func Status(c *cli.Context, cfg types.Config) ([]types.MigrationStatus, error) {
+	definitions, err := ComputeDefinitionsFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dbConn, err := cfg.Database.ConnString()
+	if err != nil {
+		return nil, err
+	}
+
+	dbConfig, err := DBConfigFromCfg(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := LoggerFromCtx(c)
+
+	db, err := database.NewWithConfig(cfg.Database.Driver, dbConn, logger, dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	r, err := runner.NewRunner(c.Context, db, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Status(c.Context, runner.Options{
+		Definitions:   definitions,
+		MigrationInfo: cfg.Migration,
+	})
+}
+
+// PrintStatus writes a table of migration statuses followed by a short summary.
+func PrintStatus(w io.Writer, statuses []types.MigrationStatus) error {
+	if len(statuses) == 0 {
+		_, err := fmt.Fprintf(w, "%sNo migrations found.%s\n", output.StyleInfo, output.StyleReset)
+		return err
+	}
+
+	// Only projects with several migration directories get a namespace column.
+	var namespaced bool
+	for _, s := range statuses {
+		if s.Definition.Namespace != "" {
+			namespaced = true
+			break
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if namespaced {
+		fmt.Fprintln(tw, "STATUS\tNAMESPACE\tMIGRATION\tAPPLIED AT\tNOTES")
+	} else {
+		fmt.Fprintln(tw, "STATUS\tMIGRATION\tAPPLIED AT\tNOTES")
+	}
+
+	var pending, orphaned int
+	for _, s := range statuses {
+		state, name, appliedAt, notes := "pending", s.Definition.FileName(), "-", statusNotes(s.Definition)
+		switch {
+		case s.Orphaned:
+			state, name, notes = "orphaned", s.Log.Name, "no matching definition"
+			appliedAt = s.Log.MigrationTime.Format("2006-01-02 15:04:05")
+			orphaned++
+		case s.Applied():
+			state = "applied"
+			appliedAt = s.Log.MigrationTime.Format("2006-01-02 15:04:05")
+		default:
+			pending++
+		}
+		if namespaced {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", state, s.Definition.Namespace, name, appliedAt, notes)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", state, name, appliedAt, notes)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	total := len(statuses) - orphaned
+	summary := fmt.Sprintf("Total: %d migration(s), %d applied, %d pending.", total, total-pending, pending)
+	if orphaned > 0 {
+		summary += fmt.Sprintf(" %d orphaned migration(s) in the database have no matching definition.", orphaned)
+	}
+	_, err := fmt.Fprintf(w, "\n%s%s%s\n", output.StyleInfo, summary, output.StyleReset)
+	return err
+}
+
+// statusNotes returns the flags worth calling out for a definition in the status table.
+func statusNotes(def types.Definition) string {
+	var notes []string
+	if def.Irreversible {
+		notes = append(notes, "irreversible")
+	}
+	if def.NoTransaction {
+		notes = append(notes, "no_transaction")
+	}
+	return strings.Join(notes, ", ")
+}
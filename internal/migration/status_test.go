@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestPrintStatus(t *testing.T) {
+	statuses := []types.MigrationStatus{
+		{
+			Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "create_users", Timestamp: 1747578808}},
+			Log:        &types.MigrationLog{Name: "1747578808_create_users", MigrationTime: time.Date(2025, 5, 18, 14, 33, 28, 0, time.UTC)},
+		},
+		{
+			Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "drop_legacy", Timestamp: 1747578819, Irreversible: true}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintStatus(&buf, statuses))
+
+	out := buf.String()
+	require.Contains(t, out, "applied  1747578808_create_users  2025-05-18 14:33:28")
+	require.Contains(t, out, "pending  1747578819_drop_legacy")
+	require.Contains(t, out, "irreversible")
+	require.Contains(t, out, "Total: 2 migration(s), 1 applied, 1 pending.")
+}
+
+func TestPrintStatus_Namespaces(t *testing.T) {
+	statuses := []types.MigrationStatus{
+		{
+			Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "create_users", Timestamp: 1747578808, Namespace: "core"}},
+			Log:        &types.MigrationLog{Name: "1747578808_create_users", MigrationTime: time.Date(2025, 5, 18, 14, 33, 28, 0, time.UTC)},
+		},
+		{
+			Definition: types.Definition{MigrationMetadata: types.MigrationMetadata{Name: "create_invoices", Timestamp: 1747578819, Namespace: "billing"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintStatus(&buf, statuses))
+
+	out := buf.String()
+	require.Contains(t, out, "STATUS   NAMESPACE  MIGRATION")
+	require.Contains(t, out, "applied  core       1747578808_create_users")
+	require.Contains(t, out, "pending  billing    1747578819_create_invoices")
+}
@@ -1,16 +1,137 @@
 package migration
 
-const downMigrationFileTemplate = `-- Undo the changes made in the up migration
---
--- Note: All migrations in kat are automatically wrapped in a transaction.
--- You don't need to add BEGIN/COMMIT statements manually.
-`
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
 
-const upMigrationFileTemplate = `-- Perform migration here.
---
---  It's helpful to make migrations idempotent, that way migrations can be executed multiple times
--- and the database structure will be the same.
---
--- Note: All migrations in kat are automatically wrapped in a transaction.
--- You don't need to add BEGIN/COMMIT statements manually.
+// defaultTemplate is the template `kat add` uses when --template isn't given.
+const defaultTemplate = "default"
+
+const mergeMigrationFileTemplate = `-- This migration merges divergent branches of the migration graph and
+-- doesn't change the database. Keep it empty unless the merged branches
+-- need reconciling.
 `
+
+// templateData holds the variables available to migration templates.
+type templateData struct {
+	// Driver is the configured database driver, "postgres" or "sqlite".
+	Driver        string
+	Name          string
+	Namespace     string
+	Timestamp     int64
+	Parents       []int64
+	Description   string
+	NoTransaction bool
+	Irreversible  bool
+}
+
+// templateDefaults holds the metadata defaults a template sets in its metadata.yaml.
+type templateDefaults struct {
+	Description   string `yaml:"description"`
+	NoTransaction bool   `yaml:"no_transaction"`
+	Irreversible  bool   `yaml:"irreversible"`
+}
+
+// migrationTemplate is a named template for the files of a new migration. Like a
+// migration, it is a directory with up.sql, down.sql and an optional metadata.yaml.
+type migrationTemplate struct {
+	name string
+	fsys fs.FS
+}
+
+// findTemplate returns the template called name. Templates in dir, the configured
+// `migration.templates` directory, take precedence over the built-in ones.
+func findTemplate(dir, name string) (migrationTemplate, error) {
+	if name == "." || !fs.ValidPath(name) || strings.Contains(name, "/") {
+		return migrationTemplate{}, errors.Newf("invalid template name %q", name)
+	}
+
+	if dir != "" {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return migrationTemplate{name: name, fsys: os.DirFS(path)}, nil
+		}
+	}
+
+	builtin, err := fs.Sub(templatesFS, "templates/migrations/"+name)
+	if err == nil {
+		if _, err := fs.Stat(builtin, "up.sql"); err == nil {
+			return migrationTemplate{name: name, fsys: builtin}, nil
+		}
+	}
+
+	names, err := templateNames(dir)
+	if err != nil {
+		return migrationTemplate{}, err
+	}
+	return migrationTemplate{}, errors.Newf("unknown template %q: available templates are %s", name, strings.Join(names, ", "))
+}
+
+// templateNames returns the names of the built-in templates and those in dir.
+func templateNames(dir string) ([]string, error) {
+	entries, err := fs.ReadDir(templatesFS, "templates/migrations")
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		custom, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "reading templates directory %s", dir)
+		}
+		entries = append(entries, custom...)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !slices.Contains(names, entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// defaults renders the template's metadata.yaml, if it has one, and returns the metadata
+// defaults it sets.
+func (t migrationTemplate) defaults(data templateData) (templateDefaults, error) {
+	var defaults templateDefaults
+	content, err := t.render("metadata.yaml", data)
+	if errors.Is(err, fs.ErrNotExist) {
+		return defaults, nil
+	}
+	if err != nil {
+		return defaults, err
+	}
+	if err := yaml.Unmarshal([]byte(content), &defaults); err != nil {
+		return defaults, errors.Wrapf(err, "parsing metadata.yaml of template %q", t.name)
+	}
+	return defaults, nil
+}
+
+// render executes one of the template's files with data.
+func (t migrationTemplate) render(file string, data templateData) (string, error) {
+	content, err := fs.ReadFile(t.fsys, file)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading %s of template %q", file, t.name)
+	}
+
+	tmpl, err := template.New(file).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing %s of template %q", file, t.name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "executing %s of template %q", file, t.name)
+	}
+	return buf.String(), nil
+}
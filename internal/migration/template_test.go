@@ -0,0 +1,114 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationTemplates(t *testing.T) {
+	custom := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(custom, "seed"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(custom, "seed", "up.sql"), []byte("INSERT INTO {{.Name}} VALUES (1); -- {{.Timestamp}}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(custom, "seed", "down.sql"), []byte("DELETE FROM {{.Name}};\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(custom, "seed", "metadata.yaml"), []byte("description: Seed {{.Name}}\nirreversible: true\n"), 0644))
+
+	tests := []struct {
+		name             string
+		template         string
+		data             templateData
+		expectedDefaults templateDefaults
+		expectedUp       string
+		expectedDown     string
+		expectedErr      string
+	}{
+		{
+			name:             "create_table",
+			template:         "create_table",
+			data:             templateData{Name: "users"},
+			expectedDefaults: templateDefaults{Description: "Create the users table"},
+			expectedDown:     "DROP TABLE IF EXISTS users;\n",
+		},
+		{
+			name:             "create_table for sqlite",
+			template:         "create_table",
+			data:             templateData{Driver: "sqlite", Name: "users"},
+			expectedDefaults: templateDefaults{Description: "Create the users table"},
+			expectedUp:       "CREATE TABLE IF NOT EXISTS users (\n    id INTEGER PRIMARY KEY AUTOINCREMENT,\n    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,\n    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP\n);\n",
+			expectedDown:     "DROP TABLE IF EXISTS users;\n",
+		},
+		{
+			name:             "concurrent_index sets no_transaction",
+			template:         "concurrent_index",
+			data:             templateData{Name: "users_email_idx"},
+			expectedDefaults: templateDefaults{Description: "Create the users_email_idx index without locking writes", NoTransaction: true},
+			expectedDown:     "DROP INDEX CONCURRENTLY IF EXISTS users_email_idx;\n",
+		},
+		{
+			name:             "concurrent_index for sqlite",
+			template:         "concurrent_index",
+			data:             templateData{Driver: "sqlite", Name: "users_email_idx"},
+			expectedDefaults: templateDefaults{Description: "Create the users_email_idx index"},
+			expectedUp:       "-- SQLite can't build indexes concurrently; the index is created in the migration's\n-- transaction and locks writes to the table while it is built.\nCREATE INDEX IF NOT EXISTS users_email_idx ON table_name (column_name);\n",
+			expectedDown:     "DROP INDEX IF EXISTS users_email_idx;\n",
+		},
+		{
+			name:             "custom template",
+			template:         "seed",
+			data:             templateData{Name: "roles", Timestamp: 42},
+			expectedDefaults: templateDefaults{Description: "Seed roles", Irreversible: true},
+			expectedUp:       "INSERT INTO roles VALUES (1); -- 42\n",
+			expectedDown:     "DELETE FROM roles;\n",
+		},
+		{
+			name:        "unknown template",
+			template:    "nope",
+			expectedErr: `unknown template "nope": available templates are add_column, concurrent_index, create_table, default, seed`,
+		},
+		{
+			name:        "path as template name",
+			template:    "../seed",
+			expectedErr: `invalid template name "../seed"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := findTemplate(custom, tt.template)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			defaults, err := tmpl.defaults(tt.data)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedDefaults, defaults)
+
+			up, err := tmpl.render("up.sql", tt.data)
+			require.NoError(t, err)
+			if tt.expectedUp != "" {
+				require.Equal(t, tt.expectedUp, up)
+			}
+			down, err := tmpl.render("down.sql", tt.data)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedDown, down)
+		})
+	}
+}
+
+func TestDefaultTemplate(t *testing.T) {
+	tmpl, err := findTemplate("", defaultTemplate)
+	require.NoError(t, err)
+
+	up, err := tmpl.render("up.sql", templateData{Name: "create_users"})
+	require.NoError(t, err)
+	require.Contains(t, up, "automatically wrapped in a transaction")
+
+	up, err = tmpl.render("up.sql", templateData{Name: "create_users", NoTransaction: true})
+	require.NoError(t, err)
+	require.Contains(t, up, "runs outside a transaction")
+	require.NotContains(t, up, "automatically wrapped in a transaction")
+}
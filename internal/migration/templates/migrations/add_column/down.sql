@@ -0,0 +1,5 @@
+{{- if eq .Driver "sqlite" -}}
+ALTER TABLE table_name DROP COLUMN column_name;
+{{- else -}}
+ALTER TABLE table_name DROP COLUMN IF EXISTS column_name;
+{{- end}}
@@ -0,0 +1,6 @@
+-- Replace the table, column and type below.
+{{- if eq .Driver "sqlite"}}
+ALTER TABLE table_name ADD COLUMN column_name TEXT;
+{{- else}}
+ALTER TABLE table_name ADD COLUMN IF NOT EXISTS column_name TEXT;
+{{- end}}
@@ -0,0 +1,5 @@
+{{- if eq .Driver "sqlite" -}}
+DROP INDEX IF EXISTS {{.Name}};
+{{- else -}}
+DROP INDEX CONCURRENTLY IF EXISTS {{.Name}};
+{{- end}}
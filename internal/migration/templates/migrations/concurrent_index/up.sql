@@ -0,0 +1,10 @@
+{{- if eq .Driver "sqlite" -}}
+-- SQLite can't build indexes concurrently; the index is created in the migration's
+-- transaction and locks writes to the table while it is built.
+CREATE INDEX IF NOT EXISTS {{.Name}} ON table_name (column_name);
+{{- else -}}
+-- CREATE INDEX CONCURRENTLY can't run inside a transaction, so this migration sets
+-- no_transaction. Keep it to this single statement: if it fails, the index may be left
+-- INVALID and has to be dropped before the migration is run again.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS {{.Name}} ON table_name (column_name);
+{{- end}}
@@ -0,0 +1,8 @@
+-- Undo the changes made in the up migration
+--
+{{- if .NoTransaction}}
+-- Note: This migration runs outside a transaction (no_transaction is set).
+{{- else}}
+-- Note: All migrations in kat are automatically wrapped in a transaction.
+-- You don't need to add BEGIN/COMMIT statements manually.
+{{- end}}
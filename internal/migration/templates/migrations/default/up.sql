@@ -0,0 +1,12 @@
+-- Perform migration here.
+--
+--  It's helpful to make migrations idempotent, that way migrations can be executed multiple times
+-- and the database structure will be the same.
+--
+{{- if .NoTransaction}}
+-- Note: This migration runs outside a transaction (no_transaction is set). Statements
+-- that succeed stay applied if a later one fails.
+{{- else}}
+-- Note: All migrations in kat are automatically wrapped in a transaction.
+-- You don't need to add BEGIN/COMMIT statements manually.
+{{- end}}
@@ -0,0 +1,135 @@
+package migration
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// Problem is an issue found by Validate.
+type Problem struct {
+	// Migration is the path of the migration directory the problem was found in, relative
+	// to the working directory.
+	Migration string
+	Message   string
+}
+
+// validatedMigration is a migration read by Validate together with the directory it
+// was read from.
+type validatedMigration struct {
+	metadata types.MigrationMetadata
+	path     string
+}
+
+// Validate checks every migrations directory in cfg and returns the problems it finds:
+// migrations sharing an ID, directory names that don't match the ID in metadata.yaml,
+// parents that don't exist or are in another namespace, and dependency cycles. Unlike
+// the other commands, it reports every problem instead of stopping at the first one.
+func Validate(cfg types.Config) ([]Problem, int, error) {
+	var (
+		migrations []validatedMigration
+		problems   []Problem
+	)
+	for _, dir := range cfg.Migration.MigrationDirectories() {
+		f, err := getMigrationsFS(dir.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+		files, err := extractMigrationFiles(f)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, file := range files {
+			path := filepath.Join(dir.Path, file.Name())
+			if !isMigrationEntry(file) {
+				if strings.HasSuffix(file.Name(), ".sql") {
+					problems = append(problems, Problem{Migration: path, Message: "SQL file is ignored because it is not named <timestamp>_<name>.sql"})
+				}
+				continue
+			}
+
+			metadata, _, _, err := readMigrationEntry(f, file)
+			if err != nil {
+				problems = append(problems, Problem{Migration: path, Message: err.Error()})
+				continue
+			}
+			metadata.Namespace = dir.Namespace
+
+			if prefix, _, _ := strings.Cut(file.Name(), "_"); prefix != strconv.FormatInt(metadata.Timestamp, 10) {
+				kind := "directory"
+				if !file.IsDir() {
+					kind = "file"
+				}
+				problems = append(problems, Problem{
+					Migration: path,
+					Message:   fmt.Sprintf("%s name does not start with the migration's timestamp %d", kind, metadata.Timestamp),
+				})
+			}
+			migrations = append(migrations, validatedMigration{metadata: metadata, path: path})
+		}
+	}
+
+	problems = append(problems, idCollisions(migrations)...)
+	problems = append(problems, parentProblems(migrations)...)
+
+	// The graph can only be built once IDs are unique and every parent exists. It then
+	// catches anything left, such as cycles.
+	if len(problems) == 0 {
+		if _, err := ComputeDefinitionsFromConfig(cfg); err != nil {
+			problems = append(problems, Problem{Message: err.Error()})
+		}
+	}
+
+	return problems, len(migrations), nil
+}
+
+// idCollisions reports every migration whose ID is already used by another migration.
+func idCollisions(migrations []validatedMigration) []Problem {
+	var problems []Problem
+	first := make(map[int64]validatedMigration, len(migrations))
+	for _, m := range migrations {
+		existing, ok := first[m.metadata.Timestamp]
+		if !ok {
+			first[m.metadata.Timestamp] = m
+			continue
+		}
+		problems = append(problems, Problem{
+			Migration: m.path,
+			Message:   fmt.Sprintf("timestamp %d is already used by %s", m.metadata.Timestamp, existing.path),
+		})
+	}
+	return problems
+}
+
+// parentProblems reports parents that don't exist or are in a different namespace than
+// the one they are qualified with.
+func parentProblems(migrations []validatedMigration) []Problem {
+	byID := make(map[int64]types.MigrationMetadata, len(migrations))
+	for _, m := range migrations {
+		byID[m.metadata.Timestamp] = m.metadata
+	}
+
+	var problems []Problem
+	for _, m := range migrations {
+		for _, parent := range m.metadata.Parents {
+			ref := strconv.FormatInt(parent, 10)
+			ns, qualified := m.metadata.ParentNamespaces[parent]
+			if qualified {
+				ref = ns + ":" + ref
+			}
+
+			p, ok := byID[parent]
+			switch {
+			case !ok:
+				problems = append(problems, Problem{Migration: m.path, Message: fmt.Sprintf("parent %s does not exist", ref)})
+			case qualified && p.Namespace != ns:
+				problems = append(problems, Problem{Migration: m.path, Message: fmt.Sprintf("parent %s is in namespace %q", ref, p.Namespace)})
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestValidate(t *testing.T) {
+	type migration struct {
+		dir      string
+		metadata string
+	}
+
+	tests := []struct {
+		name       string
+		migrations []migration
+		expected   []Problem
+	}{
+		{
+			name: "valid migrations with second and nanosecond IDs",
+			migrations: []migration{
+				{dir: "1747578808_create_users", metadata: "name: create_users\ntimestamp: 1747578808\n"},
+				{dir: "1747578808123456789_add_email", metadata: "name: add_email\ntimestamp: 1747578808123456789\nparents: [1747578808]\n"},
+			},
+		},
+		{
+			name: "colliding IDs",
+			migrations: []migration{
+				{dir: "1747578808_create_users", metadata: "name: create_users\ntimestamp: 1747578808\n"},
+				{dir: "1747578808_create_posts", metadata: "name: create_posts\ntimestamp: 1747578808\n"},
+			},
+			expected: []Problem{
+				{Migration: "1747578808_create_users", Message: "timestamp 1747578808 is already used by 1747578808_create_posts"},
+			},
+		},
+		{
+			name: "directory name and timestamp disagree",
+			migrations: []migration{
+				{dir: "1747578808_create_users", metadata: "name: create_users\ntimestamp: 1747578809\n"},
+			},
+			expected: []Problem{
+				{Migration: "1747578808_create_users", Message: "directory name does not start with the migration's timestamp 1747578809"},
+			},
+		},
+		{
+			name: "missing parent",
+			migrations: []migration{
+				{dir: "1747578808_create_users", metadata: "name: create_users\ntimestamp: 1747578808\nparents: [1747578000]\n"},
+			},
+			expected: []Problem{
+				{Migration: "1747578808_create_users", Message: "parent 1747578000 does not exist"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, m := range tt.migrations {
+				dir := filepath.Join(root, m.dir)
+				require.NoError(t, os.MkdirAll(dir, 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "up.sql"), []byte("SELECT 1;"), 0644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "down.sql"), []byte("SELECT 1;"), 0644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "metadata.yaml"), []byte(m.metadata), 0644))
+			}
+
+			problems, count, err := Validate(types.Config{Migration: types.MigrationInfo{Directory: root}})
+			require.NoError(t, err)
+			require.Equal(t, len(tt.migrations), count)
+
+			// Make paths relative to the migrations directory to keep expectations short.
+			for i := range problems {
+				problems[i].Migration = strings.TrimPrefix(problems[i].Migration, root+string(filepath.Separator))
+				problems[i].Message = strings.ReplaceAll(problems[i].Message, root+string(filepath.Separator), "")
+			}
+			require.Equal(t, tt.expected, problems)
+		})
+	}
+}
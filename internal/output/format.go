@@ -0,0 +1,50 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Format is the shape in which the CLI renders the result of a command.
+type Format string
+
+const (
+	// FormatText renders coloured, human-readable text. It is the default.
+	FormatText Format = "text"
+	// FormatJSON renders a single JSON document per command on stdout.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat converts a string to a Format, returning an error for unknown values.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	default:
+		return "", errors.Newf("unsupported output format %q: must be one of text, json", s)
+	}
+}
+
+// Document is the envelope written to stdout for every command in JSON mode.
+type Document struct {
+	Command string         `json:"command"`
+	Success bool           `json:"success"`
+	Result  any            `json:"result,omitempty"`
+	Error   *ErrorDocument `json:"error,omitempty"`
+}
+
+// ErrorDocument describes a failed command. Code is stable and safe to match on;
+// Message is meant for humans.
+type ErrorDocument struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteJSON writes doc to w as a single indented JSON document.
+func WriteJSON(w io.Writer, doc Document) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,49 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Format
+		wantErr  bool
+	}{
+		{input: "text", expected: FormatText},
+		{input: "json", expected: FormatJSON},
+		{input: "yaml", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			f, err := ParseFormat(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, f)
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, Document{
+		Command: "up",
+		Error:   &ErrorDocument{Code: "migration_failed", Message: "boom"},
+	}))
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, "up", decoded["command"])
+	require.Equal(t, false, decoded["success"])
+	require.NotContains(t, decoded, "result")
+	require.Equal(t, map[string]any{"code": "migration_failed", "message": "boom"}, decoded["error"])
+}
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/keegancsmith/sqlf"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// HookTx runs extra SQL inside a migration's transaction. It is handed to
+// BeforeMigration and AfterMigration hooks for transactional migrations.
+type HookTx interface {
+	Exec(context.Context, *sqlf.Query) error
+	QueryRow(context.Context, *sqlf.Query) *sql.Row
+	Query(context.Context, *sqlf.Query) (*sql.Rows, error)
+}
+
+// RunEvent describes a whole Up or Down run.
+type RunEvent struct {
+	Operation types.MigrationOperationType
+	DryRun    bool
+
+	// Planned lists the migrations the run intends to execute, in execution order.
+	Planned []types.MigrationMetadata
+
+	// Results, Duration and Err are only set for AfterRun.
+	Results  []types.MigrationResult
+	Duration time.Duration
+	Err      error
+}
+
+// MigrationEvent describes a single migration within a run.
+type MigrationEvent struct {
+	// Name is the migration's file name, e.g. 1679012345_create_users.
+	Name      string
+	Migration types.MigrationMetadata
+	Operation types.MigrationOperationType
+	DryRun    bool
+
+	// Tx is the migration's transaction. It is nil for migrations marked
+	// no_transaction and during dry runs.
+	Tx HookTx
+
+	// Duration is set for AfterMigration and OnError; Err is only set for OnError.
+	Duration time.Duration
+	Err      error
+}
+
+// Hooks are callbacks invoked around a run and each migration. Every field is optional.
+type Hooks struct {
+	// BeforeRun is called once the plan is known and before any migration runs.
+	// Returning an error aborts the run without executing anything.
+	BeforeRun func(context.Context, RunEvent) error
+
+	// BeforeMigration is called before a migration's SQL runs, inside its transaction
+	// for transactional migrations. Returning an error aborts the run; the migration's
+	// transaction is rolled back.
+	BeforeMigration func(context.Context, MigrationEvent) error
+
+	// AfterMigration is called after a migration and its tracking table update
+	// succeeded, inside the transaction for transactional migrations. Returning an
+	// error fails the migration and rolls back the transaction; migrations marked
+	// no_transaction have already been committed.
+	AfterMigration func(context.Context, MigrationEvent) error
+
+	// OnError is called when a migration fails, including when a hook aborted it.
+	OnError func(context.Context, MigrationEvent)
+
+	// AfterRun is called when a run that passed BeforeRun finishes, whether or not it
+	// succeeded.
+	AfterRun func(context.Context, RunEvent)
+}
+
+func (h Hooks) beforeRun(ctx context.Context, e RunEvent) error {
+	if h.BeforeRun == nil {
+		return nil
+	}
+	return h.BeforeRun(ctx, e)
+}
+
+func (h Hooks) beforeMigration(ctx context.Context, e MigrationEvent) error {
+	if h.BeforeMigration == nil {
+		return nil
+	}
+	return h.BeforeMigration(ctx, e)
+}
+
+func (h Hooks) afterMigration(ctx context.Context, e MigrationEvent) error {
+	if h.AfterMigration == nil {
+		return nil
+	}
+	return h.AfterMigration(ctx, e)
+}
+
+func (h Hooks) onError(ctx context.Context, e MigrationEvent) {
+	if h.OnError != nil {
+		h.OnError(ctx, e)
+	}
+}
+
+func (h Hooks) afterRun(ctx context.Context, e RunEvent) {
+	if h.AfterRun != nil {
+		h.AfterRun(ctx, e)
+	}
+}
+
+// newMigrationEvent builds the event passed to migration hooks. tx is dropped during dry
+// runs so hooks can't write while nothing else does.
+func newMigrationEvent(definition types.Definition, options Options, tx HookTx) MigrationEvent {
+	if options.DryRun {
+		tx = nil
+	}
+	return MigrationEvent{
+		Name:      definition.FileName(),
+		Migration: definition.MigrationMetadata,
+		Operation: options.Operation,
+		DryRun:    options.DryRun,
+		Tx:        tx,
+	}
+}
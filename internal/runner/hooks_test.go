@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/keegancsmith/sqlf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+var hookDefinitions = []types.Definition{
+	{
+		MigrationMetadata: types.MigrationMetadata{
+			Name:      "create_users",
+			Timestamp: 1747525262,
+		},
+		UpQuery:   sqlf.Sprintf("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		DownQuery: sqlf.Sprintf("DROP TABLE users;"),
+	},
+	{
+		MigrationMetadata: types.MigrationMetadata{
+			Name:      "create_posts",
+			Timestamp: 1747525318,
+			Parents:   []int64{1747525262},
+		},
+		UpQuery:   sqlf.Sprintf("CREATE TABLE posts (id INTEGER PRIMARY KEY);"),
+		DownQuery: sqlf.Sprintf("DROP TABLE posts;"),
+	},
+}
+
+func TestRun_Hooks(t *testing.T) {
+	hookErr := errors.New("hook failed")
+
+	tests := []struct {
+		name            string
+		hooks           func(calls *[]string) Hooks
+		expectErr       bool
+		expectedCalls   []string
+		expectedApplied []string
+		expectedAudit   int
+	}{
+		{
+			name: "invokes every hook in order",
+			hooks: func(calls *[]string) Hooks {
+				return Hooks{
+					BeforeRun: func(_ context.Context, e RunEvent) error {
+						*calls = append(*calls, "before_run")
+						require.Len(t, e.Planned, 2)
+						return nil
+					},
+					BeforeMigration: func(_ context.Context, e MigrationEvent) error {
+						*calls = append(*calls, "before "+e.Name)
+						return nil
+					},
+					AfterMigration: func(_ context.Context, e MigrationEvent) error {
+						*calls = append(*calls, "after "+e.Name)
+						return nil
+					},
+					AfterRun: func(_ context.Context, e RunEvent) {
+						*calls = append(*calls, "after_run")
+						require.NoError(t, e.Err)
+						require.Len(t, e.Results, 2)
+					},
+				}
+			},
+			expectedCalls: []string{
+				"before_run",
+				"before 1747525262_create_users", "after 1747525262_create_users",
+				"before 1747525318_create_posts", "after 1747525318_create_posts",
+				"after_run",
+			},
+			expectedApplied: []string{"1747525262_create_users", "1747525318_create_posts"},
+		},
+		{
+			name: "before-run error aborts without running migrations",
+			hooks: func(calls *[]string) Hooks {
+				return Hooks{
+					BeforeRun: func(context.Context, RunEvent) error { return hookErr },
+					BeforeMigration: func(_ context.Context, e MigrationEvent) error {
+						*calls = append(*calls, "before "+e.Name)
+						return nil
+					},
+				}
+			},
+			expectErr: true,
+		},
+		{
+			name: "before-migration error aborts the run",
+			hooks: func(calls *[]string) Hooks {
+				return Hooks{
+					BeforeMigration: func(_ context.Context, e MigrationEvent) error {
+						if e.Migration.Name == "create_posts" {
+							return hookErr
+						}
+						return nil
+					},
+					OnError: func(_ context.Context, e MigrationEvent) {
+						*calls = append(*calls, "error "+e.Name)
+						require.ErrorIs(t, e.Err, hookErr)
+					},
+					AfterRun: func(_ context.Context, e RunEvent) {
+						*calls = append(*calls, "after_run")
+						require.ErrorIs(t, e.Err, hookErr)
+					},
+				}
+			},
+			expectErr:       true,
+			expectedCalls:   []string{"error 1747525318_create_posts", "after_run"},
+			expectedApplied: []string{"1747525262_create_users"},
+		},
+		{
+			name: "extra SQL commits with the migration",
+			hooks: func(*[]string) Hooks {
+				return Hooks{
+					AfterMigration: func(ctx context.Context, e MigrationEvent) error {
+						return e.Tx.Exec(ctx, sqlf.Sprintf("INSERT INTO audit (name) VALUES (%s)", e.Name))
+					},
+				}
+			},
+			expectedApplied: []string{"1747525262_create_users", "1747525318_create_posts"},
+			expectedAudit:   2,
+		},
+		{
+			name: "extra SQL rolls back with the migration",
+			hooks: func(*[]string) Hooks {
+				return Hooks{
+					AfterMigration: func(ctx context.Context, e MigrationEvent) error {
+						if err := e.Tx.Exec(ctx, sqlf.Sprintf("INSERT INTO audit (name) VALUES (%s)", e.Name)); err != nil {
+							return err
+						}
+						if e.Migration.Name == "create_posts" {
+							return hookErr
+						}
+						return nil
+					},
+				}
+			},
+			expectErr:       true,
+			expectedApplied: []string{"1747525262_create_users"},
+			expectedAudit:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, db := newSQLiteRunner(t)
+			require.NoError(t, db.Exec(ctx, sqlf.Sprintf("CREATE TABLE audit (name TEXT NOT NULL)")))
+
+			var calls []string
+			_, err := r.Run(ctx, Options{
+				Operation:     types.UpMigrationOperation,
+				Definitions:   createMigrationDef(t, hookDefinitions...),
+				MigrationInfo: types.MigrationInfo{TableName: migrationTableName},
+				Hooks:         tt.hooks(&calls),
+			})
+			if tt.expectErr {
+				require.ErrorIs(t, err, hookErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.expectedCalls, calls)
+			require.ElementsMatch(t, tt.expectedApplied, appliedNames(t, r))
+
+			var audit int
+			require.NoError(t, db.QueryRow(ctx, sqlf.Sprintf("SELECT COUNT(*) FROM audit")).Scan(&audit))
+			require.Equal(t, tt.expectedAudit, audit)
+		})
+	}
+}
@@ -22,6 +22,9 @@ type noTransactTx struct {
 var _ database.Tx = &noTransactTx{}
 
 func (n *noTransactTx) Exec(ctx context.Context, q *sqlf.Query) error { return n.db.Exec(ctx, q) }
+func (n *noTransactTx) ExecResult(ctx context.Context, q *sqlf.Query) (sql.Result, error) {
+	return n.db.ExecResult(ctx, q)
+}
 func (n *noTransactTx) QueryRow(ctx context.Context, q *sqlf.Query) *sql.Row {
 	return n.db.QueryRow(ctx, q)
 }
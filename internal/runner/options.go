@@ -1,7 +1,10 @@
 package runner
 
 import (
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/BolajiOlajide/kat/internal/graph"
+	"github.com/BolajiOlajide/kat/internal/metrics"
 	"github.com/BolajiOlajide/kat/internal/types"
 )
 
@@ -13,4 +16,37 @@ type Options struct {
 	DryRun        bool
 	Verbose       bool
 	Count         int
+
+	// Force allows down operations to roll back migrations marked as irreversible.
+	Force bool
+
+	// Batch makes a down operation roll back the migrations recorded by the most recent
+	// up run, in reverse order of application, instead of Count migrations.
+	Batch bool
+
+	// Only restricts an up operation to the migration with this timestamp. Its parents
+	// must already be applied. Zero applies every pending migration.
+	Only int64
+
+	// Tracer creates spans for the run, each migration and tracking table operations.
+	// A nil Tracer disables tracing.
+	Tracer trace.Tracer
+
+	// Metrics receives durations, failures and the pending count for the run. A nil
+	// Metrics disables reporting.
+	Metrics metrics.Metrics
+
+	// Hooks are invoked around the run and each migration.
+	Hooks Hooks
+
+	// batch is the batch recorded with every migration applied by the run. Run sets it.
+	batch int64
+}
+
+// metricsFor returns the Metrics configured in options, or a no-op implementation.
+func metricsFor(options Options) metrics.Metrics {
+	if options.Metrics == nil {
+		return metrics.NewNoop()
+	}
+	return options.Metrics
 }
@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/keegancsmith/sqlf"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/BolajiOlajide/kat/internal/database"
 	"github.com/BolajiOlajide/kat/internal/loggr"
@@ -18,32 +20,30 @@ import (
 
 // Runner is the interface that every runner must implement.
 type Runner interface {
-	Run(context.Context, Options) error
+	Run(context.Context, Options) ([]types.MigrationResult, error)
+	Status(context.Context, Options) ([]types.MigrationStatus, error)
+	MarkApplied(context.Context, Options, map[int64]time.Time) ([]types.Definition, error)
 }
 
 type runner struct {
 	db     database.DB
-	logger loggr.Logger
-}
-
-// executionDetails tracks information of a successful execution.
-type executionDetails struct {
-	Name      string
-	Operation string
-	Duration  time.Duration
+	logger loggr.StructuredLogger
 }
 
 var _ Runner = (*runner)(nil)
 
 // NewRunner returns a new instance of the runner.
-func NewRunner(ctx context.Context, db database.DB, logger loggr.Logger) (Runner, error) {
+func NewRunner(ctx context.Context, db database.DB, logger loggr.StructuredLogger) (Runner, error) {
 	if err := db.Ping(ctx); err != nil {
 		return nil, err
 	}
 	return &runner{db: db, logger: logger}, nil
 }
 
-func (r *runner) executeMigrationLogQuery(ctx context.Context, tblName string) error {
+func (r *runner) executeMigrationLogQuery(ctx context.Context, tr trace.Tracer, tblName string) (err error) {
+	ctx, span := tr.Start(ctx, "kat.tracking.ensure_table", trace.WithAttributes(attrTrackingTable.String(tblName)))
+	defer func() { endSpan(span, err) }()
+
 	var driver = r.db.Driver()
 	createMigrationLogQuery, err := computeCreateMigrationLogQuery(tblName, driver.IsSQLite())
 	if err != nil {
@@ -56,11 +56,72 @@ func (r *runner) executeMigrationLogQuery(ctx context.Context, tblName string) e
 		return errors.Wrap(err, "initializing migration table")
 	}
 
+	return r.addBatchColumn(ctx, tblName)
+}
+
+// trackingColumns returns the columns of the tracking table, or none if it doesn't exist.
+func (r *runner) trackingColumns(ctx context.Context, tblName string) ([]string, error) {
+	query := sqlf.Sprintf("SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = %s", tblName)
+	if r.db.Driver().IsSQLite() {
+		query = sqlf.Sprintf("SELECT name FROM pragma_table_info(%s)", tblName)
+	}
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking migration table columns")
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// addBatchColumn adds the batch column to tracking tables created by older versions of kat.
+func (r *runner) addBatchColumn(ctx context.Context, tblName string) error {
+	columns, err := r.trackingColumns(ctx, tblName)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(columns, "batch") {
+		return nil
+	}
+
+	addColumnQuery, err := computeAddBatchColumnQuery(tblName)
+	if err != nil {
+		return errors.Wrap(err, "compute add batch column query")
+	}
+	if err := r.db.Exec(ctx, sqlf.Sprintf(addColumnQuery)); err != nil {
+		return errors.Wrap(err, "adding batch column to migration table")
+	}
 	return nil
 }
 
-func (r *runner) getAppliedMigrations(ctx context.Context, tblName string) (map[string]*types.MigrationLog, error) {
-	migrationLogColumns := computeMigrationLogColumns()
+// nextBatch returns the batch to record for the migrations of a new run.
+func (r *runner) nextBatch(ctx context.Context, db database.DB, tblName string) (int64, error) {
+	nextBatchQuery, err := computeNextBatchQuery(tblName)
+	if err != nil {
+		return 0, errors.Wrap(err, "compute next batch query")
+	}
+	var batch int64
+	if err := db.QueryRow(ctx, sqlf.Sprintf(nextBatchQuery)).Scan(&batch); err != nil {
+		return 0, errors.Wrap(err, "computing migration batch")
+	}
+	return batch, nil
+}
+
+// getAppliedMigrations reads the tracking table, whose columns are given in columns. Columns
+// that older versions of kat didn't create read as NULL when the table lacks them.
+func (r *runner) getAppliedMigrations(ctx context.Context, tr trace.Tracer, tblName string, columns []string) (_ map[string]*types.MigrationLog, err error) {
+	ctx, span := tr.Start(ctx, "kat.tracking.read", trace.WithAttributes(attrTrackingTable.String(tblName)))
+	defer func() { endSpan(span, err) }()
+
+	migrationLogColumns := computeSelectColumns(columns)
 	selectLogQuery, err := computeSelectMigrationLogQuery(tblName)
 	if err != nil {
 		return nil, errors.Wrap(err, "compute select log query")
@@ -89,7 +150,7 @@ func (r *runner) getAppliedMigrations(ctx context.Context, tblName string) (map[
 	return logsMap, rows.Err()
 }
 
-func (r *runner) computePostExecutionQuery(fileName, tblName string, duration time.Duration, migrationStart time.Time, operation types.MigrationOperationType) (*sqlf.Query, error) {
+func (r *runner) computePostExecutionQuery(fileName, tblName string, duration time.Duration, migrationStart time.Time, batch int64, operation types.MigrationOperationType) (*sqlf.Query, error) {
 	drv := r.db.Driver()
 	// For UP operations, insert a log entry
 	// For DOWN operations, remove the log entry
@@ -116,6 +177,7 @@ func (r *runner) computePostExecutionQuery(fileName, tblName string, duration ti
 					sqlf.Sprintf("%s", fileName),
 					sqlf.Sprintf("%s", migrationTime),
 					durationQuery,
+					sqlf.Sprintf("%s", batch),
 				},
 				", ",
 			),
@@ -130,14 +192,28 @@ func (r *runner) computePostExecutionQuery(fileName, tblName string, duration ti
 	return sqlf.Sprintf(deleteQueryTmpl, fileName), nil
 }
 
-func (r *runner) Run(ctx context.Context, options Options) error {
-	if err := r.executeMigrationLogQuery(ctx, options.MigrationInfo.TableName); err != nil {
-		return err
+// Run executes the migrations described by options and returns the outcome of every
+// migration it attempted, including the one that failed, if any.
+func (r *runner) Run(ctx context.Context, options Options) (results []types.MigrationResult, err error) {
+	tr := tracer(options)
+	ctx, span := tr.Start(ctx, "kat."+options.Operation.String(), trace.WithAttributes(
+		attrOperation.String(options.Operation.String()),
+		attrDryRun.Bool(options.DryRun),
+		attrCount.Int(options.Count),
+		attrDBSystem.String(r.db.Driver().String()),
+	))
+	defer func() {
+		span.SetAttributes(attrMigrationsExecuted.Int(len(results)))
+		endSpan(span, err)
+	}()
+
+	if err := r.executeMigrationLogQuery(ctx, tr, options.MigrationInfo.TableName); err != nil {
+		return nil, err
 	}
 
-	logsMap, err := r.getAppliedMigrations(ctx, options.MigrationInfo.TableName)
+	logsMap, err := r.getAppliedMigrations(ctx, tr, options.MigrationInfo.TableName, migrationLogColumns)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// we use a topological sort to determine the correct sequence of execution
@@ -145,161 +221,693 @@ func (r *runner) Run(ctx context.Context, options Options) error {
 	// the definitions and the sorting of elements with the same order.
 	sortedDefs, err := options.Definitions.TopologicalSort()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	orphans, err := orphanedLogs(options, sortedDefs, logsMap)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkOrphans(orphans, options.MigrationInfo.Orphans); err != nil {
+		return nil, err
 	}
 
 	if options.Operation.IsDownMigration() {
 		slices.Reverse(sortedDefs)
 	}
 
-	var execs []executionDetails
+	var plan []types.Definition
+	switch {
+	case options.Batch && options.Operation.IsDownMigration():
+		plan, err = computeBatchPlan(options, sortedDefs, logsMap)
+	case options.Only != 0 && options.Operation.IsUpMigration():
+		plan, err = computeOnlyPlan(options, logsMap)
+	default:
+		plan, err = computePlan(options, sortedDefs, logsMap)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	for _, hash := range sortedDefs {
-		// We want to respect the count flag when it's provided, so we don't exceed the number
-		// of migrations the user expects to be processed.
-		if options.Count > 0 && len(execs) >= options.Count {
-			break
+	if options.Operation.IsUpMigration() {
+		outOfOrder, latest, err := outOfOrderDefinitions(options, sortedDefs, logsMap, plan)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkOutOfOrder(outOfOrder, latest, options.MigrationInfo.OutOfOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Operation.IsUpMigration() && !options.DryRun && len(plan) > 0 {
+		if options.batch, err = r.nextBatch(ctx, r.db, options.MigrationInfo.TableName); err != nil {
+			return nil, err
+		}
+	}
+
+	if !options.DryRun {
+		pending, err := countPending(options, sortedDefs, logsMap)
+		if err != nil {
+			return nil, err
+		}
+		metricsFor(options).MigrationsPending(pending)
+	}
+
+	runEvent := RunEvent{Operation: options.Operation, DryRun: options.DryRun}
+	for _, definition := range plan {
+		runEvent.Planned = append(runEvent.Planned, definition.MigrationMetadata)
+	}
+	if err := options.Hooks.beforeRun(ctx, runEvent); err != nil {
+		return nil, errors.Wrap(err, "before-run hook")
+	}
+	runStart := time.Now()
+	defer func() {
+		runEvent.Results, runEvent.Duration, runEvent.Err = results, time.Since(runStart), err
+		options.Hooks.afterRun(ctx, runEvent)
+	}()
+
+	for _, definition := range plan {
+		// Irreversible migrations can't be undone, so we stop before touching them unless
+		// the caller explicitly asked us to force the rollback.
+		if options.Operation.IsDownMigration() && definition.Irreversible && !options.Force {
+			r.logger.Error(fmt.Sprintf("Migration %q is marked as irreversible; stopping rollback", definition.FileName()),
+				r.logFields(definition, options.Operation)...)
+			r.printMigrationSummary(results, options.Operation, options.DryRun, options.Verbose)
+			return results, &types.IrreversibleMigrationError{Name: definition.FileName()}
+		}
+
+		result, execErr := r.runDefinition(ctx, tr, definition, options)
+
+		if execErr != nil {
+			// Print detailed error information
+			r.logger.Error(fmt.Sprintf("Migration failed: %s", definition.Name),
+				append(r.logFields(definition, options.Operation), loggr.KeyError, execErr)...)
+			r.logger.Error(fmt.Sprintf("Error: %s", execErr.Error()))
+			r.logger.Error("Migration process stopped to preserve database integrity")
+
+			results = append(results, types.MigrationResult{
+				Name:      definition.FileName(),
+				Operation: options.Operation,
+				Err:       execErr,
+			})
+			return results, errors.Wrapf(execErr, "executing %s", definition.FileName())
+		}
+
+		if !result.DryRun {
+			r.logger.Debug(fmt.Sprintf("Executed %s migration %q in %s", options.Operation, definition.FileName(), result.Duration),
+				append(r.logFields(definition, options.Operation), loggr.KeyDuration, result.Duration)...)
 		}
+		results = append(results, result)
+	}
+
+	r.printMigrationSummary(results, options.Operation, options.DryRun, options.Verbose)
+	return results, nil
+}
+
+// Status returns every definition in topological order together with its tracking table
+// entry, if any. Only the Definitions and MigrationInfo fields of options are used.
+func (r *runner) Status(ctx context.Context, options Options) ([]types.MigrationStatus, error) {
+	tr := tracer(options)
 
+	// Status only reads: a missing tracking table means nothing has been applied yet.
+	columns, err := r.trackingColumns(ctx, options.MigrationInfo.TableName)
+	if err != nil {
+		return nil, err
+	}
+	logsMap := map[string]*types.MigrationLog{}
+	if len(columns) > 0 {
+		logsMap, err = r.getAppliedMigrations(ctx, tr, options.MigrationInfo.TableName, columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sortedDefs, err := options.Definitions.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]types.MigrationStatus, 0, len(sortedDefs))
+	for _, hash := range sortedDefs {
 		definition, err := options.Definitions.GetDefinition(hash)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		statuses = append(statuses, types.MigrationStatus{
+			Definition: definition,
+			Log:        logsMap[definition.FileName()],
+		})
+	}
 
-		// Use retry functionality for transaction if configured
-		var txFunc = func(tx database.Tx) (err error) {
-			var q *sqlf.Query
-			if options.Operation.IsUpMigration() {
-				// Skip migrations that have already been executed
-				if _, exists := logsMap[definition.FileName()]; exists {
-					return nil
-				}
-				q = definition.UpQuery
-			} else {
-				// Skip migrations that haven't been executed
-				if _, exists := logsMap[definition.FileName()]; !exists {
-					return nil
-				}
-				q = definition.DownQuery
-			}
+	orphans, err := orphanedLogs(options, sortedDefs, logsMap)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range orphans {
+		statuses = append(statuses, types.MigrationStatus{
+			Definition: definitionFromLogName(l.Name),
+			Log:        l,
+			Orphaned:   true,
+		})
+	}
+	return statuses, nil
+}
 
-			// In dry-run mode, don't execute the SQL
-			if options.DryRun {
-				r.logger.Info(fmt.Sprintf("[DRY RUN] Would execute %s migration for %q", options.Operation, definition.FileName()))
+// MarkApplied records the definitions in applied as applied without running them, for
+// example when another tool already ran them. applied maps migration timestamps to the
+// time they were applied; a zero time records the current time. Definitions that are
+// already in the tracking table are left alone. It returns the definitions it recorded.
+func (r *runner) MarkApplied(ctx context.Context, options Options, applied map[int64]time.Time) ([]types.Definition, error) {
+	tr := tracer(options)
+	tblName := options.MigrationInfo.TableName
+	if err := r.executeMigrationLogQuery(ctx, tr, tblName); err != nil {
+		return nil, err
+	}
 
-				// Add to successful migrations list for summary
-				execs = append(execs, executionDetails{
-					Name:      definition.FileName(),
-					Operation: options.Operation.String(),
-				})
+	logsMap, err := r.getAppliedMigrations(ctx, tr, tblName, migrationLogColumns)
+	if err != nil {
+		return nil, err
+	}
 
-				return nil
-			}
+	sortedDefs, err := options.Definitions.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
 
-			start := time.Now()
-			if err := tx.Exec(ctx, q); err != nil {
-				return errors.Wrapf(err, "executing %s query", options.Operation)
+	var recorded []types.Definition
+	now := time.Now()
+	err = r.db.WithTransact(ctx, func(tx database.Tx) error {
+		batch, err := r.nextBatch(ctx, tx, tblName)
+		if err != nil {
+			return err
+		}
+		for _, hash := range sortedDefs {
+			appliedAt, ok := applied[hash]
+			if !ok {
+				continue
+			}
+			definition, err := options.Definitions.GetDefinition(hash)
+			if err != nil {
+				return err
+			}
+			if _, ok := logsMap[definition.FileName()]; ok {
+				continue
+			}
+			if appliedAt.IsZero() {
+				appliedAt = now
 			}
-			duration := time.Since(start)
 
-			query, err := r.computePostExecutionQuery(definition.FileName(), options.MigrationInfo.TableName, duration, start, options.Operation)
-			if err := tx.Exec(ctx, query); err != nil {
+			query, err := r.computePostExecutionQuery(definition.FileName(), tblName, 0, appliedAt, batch, types.UpMigrationOperation)
+			if err != nil {
 				return err
 			}
+			if err := tx.Exec(ctx, query); err != nil {
+				return errors.Wrapf(err, "recording %s as applied", definition.FileName())
+			}
+			recorded = append(recorded, definition)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
 
-			// Add to successful migrations list for summary
-			execs = append(execs, executionDetails{
-				Name:      definition.FileName(),
-				Operation: options.Operation.String(),
-				Duration:  duration,
-			})
-			return nil
+// computePlan returns the definitions a run will execute, in execution order. Migrations
+// that are already in the desired state are skipped, and the count limit is respected so we
+// don't exceed the number of migrations the user expects to be processed.
+func computePlan(options Options, sortedDefs []int64, logsMap map[string]*types.MigrationLog) ([]types.Definition, error) {
+	var plan []types.Definition
+	for _, hash := range sortedDefs {
+		if options.Count > 0 && len(plan) >= options.Count {
+			break
 		}
 
-		var execErr error
-		if definition.NoTransaction {
-			execErr = r.runNoTransaction(ctx, definition, options, logsMap, &execs)
-		} else {
-			// Execute within a transaction
-			execErr = r.db.WithTransact(ctx, txFunc)
+		definition, err := options.Definitions.GetDefinition(hash)
+		if err != nil {
+			return nil, err
 		}
 
-		if execErr != nil {
-			// Print detailed error information
-			r.logger.Error(fmt.Sprintf("Migration failed: %s", definition.Name))
-			r.logger.Error(fmt.Sprintf("Error: %s", execErr.Error()))
-			r.logger.Error("Migration process stopped to preserve database integrity")
+		// Up migrations skip what has been applied, down migrations skip what hasn't.
+		_, applied := logsMap[definition.FileName()]
+		if applied == options.Operation.IsUpMigration() {
+			continue
+		}
+
+		plan = append(plan, definition)
+	}
+	return plan, nil
+}
+
+// computeOnlyPlan returns the definition with the timestamp options.Only unless it is
+// already applied. It fails if any of the definition's parents is still pending.
+func computeOnlyPlan(options Options, logsMap map[string]*types.MigrationLog) ([]types.Definition, error) {
+	definition, err := options.Definitions.GetDefinition(options.Only)
+	if err != nil {
+		return nil, errors.Newf("migration %d does not exist", options.Only)
+	}
+	if _, applied := logsMap[definition.FileName()]; applied {
+		return nil, nil
+	}
+
+	var pending []string
+	for _, hash := range definition.Parents {
+		parent, err := options.Definitions.GetDefinition(hash)
+		if err != nil {
+			return nil, err
+		}
+		if _, applied := logsMap[parent.FileName()]; !applied {
+			pending = append(pending, parent.QualifiedName())
+		}
+	}
+	if len(pending) > 0 {
+		return nil, errors.Newf("cannot apply %s on its own: parent migration(s) %s are not applied yet",
+			definition.QualifiedName(), strings.Join(pending, ", "))
+	}
+	return []types.Definition{definition}, nil
+}
 
-			return errors.Wrapf(execErr, "executing %s", definition.FileName())
+// computeBatchPlan returns the definitions of the migrations recorded by the most recent
+// batch, in reverse order of application.
+func computeBatchPlan(options Options, sortedDefs []int64, logsMap map[string]*types.MigrationLog) ([]types.Definition, error) {
+	var latest int64
+	for _, l := range logsMap {
+		latest = max(latest, l.Batch)
+	}
+	if latest == 0 {
+		if len(logsMap) == 0 {
+			return nil, nil
 		}
+		return nil, errors.New("no migration in the tracking table has a batch; migrations applied before kat recorded batches can only be rolled back by count")
 	}
 
-	r.printMigrationSummary(execs, options.Operation, options.DryRun, options.Verbose)
+	definitions := make(map[string]types.Definition, len(sortedDefs))
+	for _, hash := range sortedDefs {
+		definition, err := options.Definitions.GetDefinition(hash)
+		if err != nil {
+			return nil, err
+		}
+		definitions[definition.FileName()] = definition
+	}
+
+	var logs []*types.MigrationLog
+	for _, l := range logsMap {
+		if l.Batch == latest {
+			logs = append(logs, l)
+		}
+	}
+	slices.SortFunc(logs, func(a, b *types.MigrationLog) int { return b.ID - a.ID })
+
+	plan := make([]types.Definition, 0, len(logs))
+	for _, l := range logs {
+		definition, ok := definitions[l.Name]
+		if !ok {
+			return nil, errors.Newf("migration %s of batch %d has no matching definition and can't be rolled back", l.Name, latest)
+		}
+		plan = append(plan, definition)
+	}
+	return plan, nil
+}
+
+// countPending returns the number of definitions that have not been applied yet.
+func countPending(options Options, sortedDefs []int64, logsMap map[string]*types.MigrationLog) (int, error) {
+	var pending int
+	for _, hash := range sortedDefs {
+		definition, err := options.Definitions.GetDefinition(hash)
+		if err != nil {
+			return 0, err
+		}
+		if _, applied := logsMap[definition.FileName()]; !applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// orphanedLogs returns the tracking table entries that match none of the definitions, in
+// the order they were applied.
+func orphanedLogs(options Options, sortedDefs []int64, logsMap map[string]*types.MigrationLog) ([]*types.MigrationLog, error) {
+	known := make(map[string]bool, len(sortedDefs))
+	for _, hash := range sortedDefs {
+		definition, err := options.Definitions.GetDefinition(hash)
+		if err != nil {
+			return nil, err
+		}
+		known[definition.FileName()] = true
+	}
+
+	var orphans []*types.MigrationLog
+	for name, l := range logsMap {
+		if !known[name] {
+			orphans = append(orphans, l)
+		}
+	}
+	slices.SortFunc(orphans, func(a, b *types.MigrationLog) int { return a.ID - b.ID })
+	return orphans, nil
+}
+
+// checkOrphans applies policy to the orphaned tracking table entries of a run.
+func (r *runner) checkOrphans(orphans []*types.MigrationLog, policy types.OrphanPolicy) error {
+	if len(orphans) == 0 || policy == types.OrphanPolicyIgnore {
+		return nil
+	}
+
+	names := make([]string, 0, len(orphans))
+	for _, l := range orphans {
+		names = append(names, l.Name)
+	}
+	if policy == types.OrphanPolicyError {
+		return &types.OrphanedMigrationsError{Names: names}
+	}
+
+	r.logger.Warn(fmt.Sprintf("The database has %d migration(s) with no matching definition, possibly applied by a newer release: %s",
+		len(names), strings.Join(names, ", ")), loggr.KeyOrphans, names)
+	return nil
+}
+
+// outOfOrderDefinitions returns the definitions of plan that come before the last applied
+// migration in sortedDefs, together with that migration's file name.
+func outOfOrderDefinitions(options Options, sortedDefs []int64, logsMap map[string]*types.MigrationLog, plan []types.Definition) ([]types.Definition, string, error) {
+	position := make(map[int64]int, len(sortedDefs))
+	lastApplied, latest := -1, ""
+	for i, hash := range sortedDefs {
+		definition, err := options.Definitions.GetDefinition(hash)
+		if err != nil {
+			return nil, "", err
+		}
+		position[hash] = i
+		if _, applied := logsMap[definition.FileName()]; applied {
+			lastApplied, latest = i, definition.FileName()
+		}
+	}
+
+	var outOfOrder []types.Definition
+	for _, definition := range plan {
+		if position[definition.Timestamp] < lastApplied {
+			outOfOrder = append(outOfOrder, definition)
+		}
+	}
+	return outOfOrder, latest, nil
+}
+
+// checkOutOfOrder applies policy to the out-of-order migrations of an up run.
+func (r *runner) checkOutOfOrder(outOfOrder []types.Definition, latest string, policy types.OutOfOrderPolicy) error {
+	if len(outOfOrder) == 0 || policy == "" || policy == types.OutOfOrderAllow {
+		return nil
+	}
+
+	names := make([]string, 0, len(outOfOrder))
+	for _, definition := range outOfOrder {
+		names = append(names, definition.FileName())
+	}
+	if policy == types.OutOfOrderError {
+		return &types.OutOfOrderMigrationsError{Names: names, Latest: latest}
+	}
+
+	r.logger.Warn(fmt.Sprintf("Applying %d migration(s) out of order, before the already applied %s: %s",
+		len(names), latest, strings.Join(names, ", ")), loggr.KeyOutOfOrder, names)
 	return nil
 }
 
+// definitionFromLogName rebuilds the timestamp and name of a migration from its tracking
+// table entry, which is named after Definition.FileName.
+func definitionFromLogName(name string) types.Definition {
+	ts, rest, ok := strings.Cut(name, "_")
+	if timestamp, err := strconv.ParseInt(ts, 10, 64); ok && err == nil {
+		return types.Definition{MigrationMetadata: types.MigrationMetadata{Timestamp: timestamp, Name: rest}}
+	}
+	return types.Definition{MigrationMetadata: types.MigrationMetadata{Name: name}}
+}
+
+// runDefinition executes a single migration inside its own span, in a transaction unless
+// the migration opted out of one.
+func (r *runner) runDefinition(ctx context.Context, tr trace.Tracer, definition types.Definition, options Options) (result types.MigrationResult, err error) {
+	ctx, span := tr.Start(ctx, "kat.migration", trace.WithAttributes(
+		append(migrationAttributes(definition, options.Operation), attrDryRun.Bool(options.DryRun))...,
+	))
+	defer func() {
+		if err == nil && !result.DryRun {
+			span.SetAttributes(attrRowsAffected.Int64(result.RowsAffected))
+		}
+		endSpan(span, err)
+	}()
+
+	m := metricsFor(options)
+	operation := options.Operation.String()
+	start := time.Now()
+	if !options.DryRun {
+		m.MigrationStarted(definition.FileName(), operation)
+		defer func() {
+			if err != nil {
+				m.MigrationFailed(definition.FileName(), operation, time.Since(start), err)
+			} else {
+				m.MigrationCompleted(definition.FileName(), operation, result.Duration)
+			}
+		}()
+	}
+
+	if definition.NoTransaction {
+		event := newMigrationEvent(definition, options, nil)
+		defer func() { r.onMigrationError(ctx, options, event, start, err) }()
+
+		if err := options.Hooks.beforeMigration(ctx, event); err != nil {
+			return types.MigrationResult{}, errors.Wrap(err, "before-migration hook")
+		}
+		result, err = r.runNoTransaction(ctx, tr, definition, options)
+		if err != nil {
+			return types.MigrationResult{}, err
+		}
+		event.Duration = result.Duration
+		if err := options.Hooks.afterMigration(ctx, event); err != nil {
+			return types.MigrationResult{}, errors.Wrap(err, "after-migration hook")
+		}
+		return result, nil
+	}
+
+	event := newMigrationEvent(definition, options, nil)
+	defer func() { r.onMigrationError(ctx, options, event, start, err) }()
+
+	err = r.db.WithTransact(ctx, func(tx database.Tx) (err error) {
+		if !options.DryRun {
+			m.LockWaited(operation, time.Since(start))
+		}
+
+		event = newMigrationEvent(definition, options, tx)
+		if err := options.Hooks.beforeMigration(ctx, event); err != nil {
+			return errors.Wrap(err, "before-migration hook")
+		}
+		result, err = r.runInTransaction(ctx, tr, tx, definition, options)
+		if err != nil {
+			return err
+		}
+		event.Duration = result.Duration
+		if err := options.Hooks.afterMigration(ctx, event); err != nil {
+			return errors.Wrap(err, "after-migration hook")
+		}
+		return nil
+	})
+	if err != nil {
+		return types.MigrationResult{}, err
+	}
+	return result, nil
+}
+
+// onMigrationError reports a failed migration to the OnError hook.
+func (r *runner) onMigrationError(ctx context.Context, options Options, event MigrationEvent, start time.Time, err error) {
+	if err == nil {
+		return
+	}
+	// The transaction is over by the time OnError runs.
+	event.Tx = nil
+	event.Duration = time.Since(start)
+	event.Err = err
+	options.Hooks.onError(ctx, event)
+}
+
+// recordExecution writes or removes the tracking table entry for a migration.
+func (r *runner) recordExecution(ctx context.Context, tr trace.Tracer, tx database.Tx, definition types.Definition, options Options, start time.Time, duration time.Duration) (err error) {
+	ctx, span := tr.Start(ctx, "kat.tracking.update", trace.WithAttributes(
+		attrTrackingTable.String(options.MigrationInfo.TableName),
+		attrMigrationName.String(definition.FileName()),
+		attrOperation.String(options.Operation.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	query, err := r.computePostExecutionQuery(definition.FileName(), options.MigrationInfo.TableName, duration, start, options.batch, options.Operation)
+	if err != nil {
+		return err
+	}
+	return tx.Exec(ctx, query)
+}
+
+// runInTransaction executes a migration and its bookkeeping query inside the given transaction.
+func (r *runner) runInTransaction(ctx context.Context, tr trace.Tracer, tx database.Tx, definition types.Definition, options Options) (types.MigrationResult, error) {
+	// In dry-run mode, don't execute the SQL
+	if options.DryRun {
+		r.logger.Info(fmt.Sprintf("[DRY RUN] Would execute %s migration for %q", options.Operation, definition.FileName()),
+			append(r.logFields(definition, options.Operation), "dry_run", true)...)
+
+		return types.MigrationResult{
+			Name:      definition.FileName(),
+			Operation: options.Operation,
+			DryRun:    true,
+		}, nil
+	}
+
+	start := time.Now()
+	res, err := execInTransaction(ctx, tx, definition, options.Operation)
+	if err != nil {
+		return types.MigrationResult{}, errors.Wrapf(err, "executing %s query", options.Operation)
+	}
+	duration := time.Since(start)
+
+	if err := r.recordExecution(ctx, tr, tx, definition, options, start, duration); err != nil {
+		return types.MigrationResult{}, err
+	}
+
+	return types.MigrationResult{
+		Name:         definition.FileName(),
+		Operation:    options.Operation,
+		Duration:     duration,
+		RowsAffected: rowsAffected(res),
+	}, nil
+}
+
 // runNoTransaction executes a migration without wrapping it in a transaction.
 // The migration SQL runs in autocommit mode (required for operations like CREATE INDEX
 // CONCURRENTLY), while the bookkeeping log update is wrapped in its own transaction
 // to reduce the chance of "applied but not recorded" drift.
-func (r *runner) runNoTransaction(ctx context.Context, definition types.Definition, options Options, logsMap map[string]*types.MigrationLog, execs *[]executionDetails) error {
-	var q *sqlf.Query
-	if options.Operation.IsUpMigration() {
-		// Skip migrations that have already been executed
-		if _, exists := logsMap[definition.FileName()]; exists {
-			return nil
-		}
-		q = definition.UpQuery
-	} else {
-		// Skip migrations that haven't been executed
-		if _, exists := logsMap[definition.FileName()]; !exists {
-			return nil
-		}
-		q = definition.DownQuery
-	}
+func (r *runner) runNoTransaction(ctx context.Context, tr trace.Tracer, definition types.Definition, options Options) (types.MigrationResult, error) {
+	q := migrationQuery(definition, options.Operation)
 
 	// In dry-run mode, don't execute the SQL
 	if options.DryRun {
-		r.logger.Info(fmt.Sprintf("[DRY RUN] Would execute %s migration for %q (no transaction)", options.Operation, definition.FileName()))
-		*execs = append(*execs, executionDetails{
+		r.logger.Info(fmt.Sprintf("[DRY RUN] Would execute %s migration for %q (no transaction)", options.Operation, definition.FileName()),
+			append(r.logFields(definition, options.Operation), "dry_run", true)...)
+		return types.MigrationResult{
 			Name:      definition.FileName(),
-			Operation: options.Operation.String(),
-		})
-		return nil
+			Operation: options.Operation,
+			DryRun:    true,
+		}, nil
 	}
 
-	r.logger.Warn(fmt.Sprintf("Executing %q without a transaction; partial application is possible on failure", definition.FileName()))
+	r.logger.Warn(fmt.Sprintf("Executing %q without a transaction; partial application is possible on failure", definition.FileName()),
+		r.logFields(definition, options.Operation)...)
 
 	// Warn if the migration contains multiple statements
-	if hasMultipleStatements(q) {
-		r.logger.Warn(fmt.Sprintf("Migration %q contains multiple SQL statements; each will commit independently outside a transaction", definition.FileName()))
+	if definition.Go == nil && hasMultipleStatements(q) {
+		r.logger.Warn(fmt.Sprintf("Migration %q contains multiple SQL statements; each will commit independently outside a transaction", definition.FileName()),
+			r.logFields(definition, options.Operation)...)
 	}
 
 	// Execute the migration SQL directly (autocommit mode)
 	start := time.Now()
-	if err := r.db.Exec(ctx, q); err != nil {
-		return errors.Wrapf(err, "executing %s query", options.Operation)
+	res, err := r.execNoTransaction(ctx, definition, options.Operation)
+	if err != nil {
+		return types.MigrationResult{}, errors.Wrapf(err, "executing %s query", options.Operation)
 	}
 	duration := time.Since(start)
 
 	// Record the migration log in a transaction for bookkeeping integrity
 	if err := r.db.WithTransact(ctx, func(tx database.Tx) error {
-		query, err := r.computePostExecutionQuery(definition.FileName(), options.MigrationInfo.TableName, duration, start, options.Operation)
-		if err != nil {
-			return err
-		}
-		return tx.Exec(ctx, query)
+		return r.recordExecution(ctx, tr, tx, definition, options, start, duration)
 	}); err != nil {
-		r.logger.Error(fmt.Sprintf("Migration SQL for %q executed successfully but failed to update migration log; you may need to update the record manually", definition.FileName()))
-		return errors.Wrap(err, "updating migration log")
+		r.logger.Error(fmt.Sprintf("Migration SQL for %q executed successfully but failed to update migration log; you may need to update the record manually", definition.FileName()),
+			append(r.logFields(definition, options.Operation), loggr.KeyError, err)...)
+		return types.MigrationResult{}, errors.Wrap(err, "updating migration log")
 	}
 
-	*execs = append(*execs, executionDetails{
-		Name:      definition.FileName(),
-		Operation: options.Operation.String(),
-		Duration:  duration,
-	})
-	return nil
+	return types.MigrationResult{
+		Name:         definition.FileName(),
+		Operation:    options.Operation,
+		Duration:     duration,
+		RowsAffected: rowsAffected(res),
+	}, nil
+}
+
+// migrationQuery returns the SQL a definition runs for op.
+func migrationQuery(definition types.Definition, op types.MigrationOperationType) *sqlf.Query {
+	if op.IsDownMigration() {
+		return definition.DownQuery
+	}
+	return definition.UpQuery
+}
+
+// execInTransaction runs a definition's SQL, or its Go function for migrations written in
+// Go, inside tx. Go migrations report no result.
+func execInTransaction(ctx context.Context, tx database.Tx, definition types.Definition, op types.MigrationOperationType) (sql.Result, error) {
+	if definition.Go == nil {
+		return tx.ExecResult(ctx, migrationQuery(definition, op))
+	}
+
+	fn := definition.Go.Up
+	if op.IsDownMigration() {
+		fn = definition.Go.Down
+	}
+	if fn == nil {
+		return nil, nil
+	}
+
+	sqlTx, ok := database.SQLTx(tx)
+	if !ok {
+		return nil, errors.New("Go migrations require a database/sql transaction")
+	}
+	return nil, fn(ctx, sqlTx)
+}
+
+// execNoTransaction runs a definition's SQL, or its Go function for migrations written in
+// Go, outside a transaction. Go migrations report no result.
+func (r *runner) execNoTransaction(ctx context.Context, definition types.Definition, op types.MigrationOperationType) (sql.Result, error) {
+	if definition.Go == nil {
+		return r.db.ExecResult(ctx, migrationQuery(definition, op))
+	}
+
+	fn := definition.Go.UpNoTx
+	if op.IsDownMigration() {
+		fn = definition.Go.DownNoTx
+	}
+	if fn == nil {
+		return nil, nil
+	}
+
+	sqlDB, ok := database.SQLDB(r.db)
+	if !ok {
+		return nil, errors.New("Go migrations require a database/sql connection")
+	}
+	return nil, fn(ctx, sqlDB)
+}
+
+// rowsAffected returns the number of rows a statement affected, or zero when the driver
+// can't report it.
+func rowsAffected(res sql.Result) (n int64) {
+	if res == nil {
+		return 0
+	}
+
+	// The SQLite driver returns no result for statements that contain only comments, and
+	// database/sql panics when asked for the rows affected of a missing result.
+	defer func() {
+		if recover() != nil {
+			n = 0
+		}
+	}()
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// logFields returns the structured fields that identify a migration in log records.
+func (r *runner) logFields(definition types.Definition, operation types.MigrationOperationType) []any {
+	return []any{
+		loggr.KeyMigration, definition.FileName(),
+		loggr.KeyOperation, operation.String(),
+		loggr.KeyDriver, r.db.Driver().String(),
+		"no_transaction", definition.NoTransaction,
+	}
 }
 
 // hasMultipleStatements checks if a SQL query contains multiple semicolon-terminated statements.
@@ -311,14 +919,14 @@ func hasMultipleStatements(q *sqlf.Query) bool {
 }
 
 // printMigrationSummary prints a summary of successful migrations
-func (r *runner) printMigrationSummary(details []executionDetails, operation types.MigrationOperationType, dryRun, verbose bool) {
+func (r *runner) printMigrationSummary(details []types.MigrationResult, operation types.MigrationOperationType, dryRun, verbose bool) {
 	var executionVerb = "apply"
 	if operation.IsDownMigration() {
 		executionVerb = "roll back"
 	}
 
 	if len(details) == 0 {
-		r.logger.Info(fmt.Sprintf("No migration(s) to %s.", executionVerb))
+		r.logger.Info(fmt.Sprintf("No migration(s) to %s.", executionVerb), loggr.KeyOperation, operation.String(), "count", 0)
 		return
 	}
 
@@ -351,7 +959,8 @@ func (r *runner) printMigrationSummary(details []executionDetails, operation typ
 		operationName = "validated"
 	}
 
-	r.logger.Info(fmt.Sprintf("Total: %d migration(s) %s.", len(details), operationName))
+	r.logger.Info(fmt.Sprintf("Total: %d migration(s) %s.", len(details), operationName),
+		loggr.KeyOperation, operation.String(), "count", len(details), "dry_run", dryRun)
 }
 
 // migrationTimeFormats are the time formats used when migration_time is stored as TEXT (SQLite).
@@ -364,14 +973,17 @@ var migrationTimeFormats = []string{
 func scanMigrationLog(sc database.Scanner) (*types.MigrationLog, error) {
 	var migrationLog types.MigrationLog
 	var rawTime any
+	var batch sql.NullInt64
 	if err := sc.Scan(
 		&migrationLog.ID,
 		&migrationLog.Name,
 		&rawTime,
 		&migrationLog.Duration,
+		&batch,
 	); err != nil {
 		return nil, err
 	}
+	migrationLog.Batch = batch.Int64
 
 	switch v := rawTime.(type) {
 	case time.Time:
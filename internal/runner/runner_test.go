@@ -358,7 +358,8 @@ func TestRun(t *testing.T) {
 				)
 			}
 
-			require.NoError(t, r.Run(ctx, tt.options), "expected error to be nil from Run() method")
+			_, err = r.Run(ctx, tt.options)
+			require.NoError(t, err, "expected error to be nil from Run() method")
 
 			rows, err := db.Query(ctx, sqlf.Sprintf(dumpSchemaQuery))
 			require.NoError(t, err, "fetching schema from database")
@@ -39,6 +39,13 @@ var migrationLogsSchema = []dbSchema{
 		DataType:    "interval",
 		IsNullable:  "NO",
 	},
+	{
+		TableSchema: "public",
+		TableName:   "migration_logs",
+		ColumnName:  "batch",
+		DataType:    "bigint",
+		IsNullable:  "YES",
+	},
 }
 
 var usersSchema = dbSchema{
@@ -9,7 +9,8 @@ var createMigrationTableTmpl = template.Must(template.New("createMigrationsLogSQ
     id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
     name TEXT NOT NULL,
     migration_time TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
-    duration INTERVAL NOT NULL
+    duration INTERVAL NOT NULL,
+    batch BIGINT
 );`))
 
 // createMigrationTableSQLiteTmpl is the SQLite-compatible variant of the migration log table.
@@ -17,9 +18,16 @@ var createMigrationTableSQLiteTmpl = template.Must(template.New("createMigration
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
     migration_time TEXT NOT NULL DEFAULT (datetime('now')),
-    duration TEXT NOT NULL
+    duration TEXT NOT NULL,
+    batch INTEGER
 );`))
 
+// addBatchColumnTmpl upgrades tracking tables created before kat recorded the batch of
+// every migration. Existing rows keep a NULL batch.
+var addBatchColumnTmpl = template.Must(template.New("addBatchColumn").Parse(`ALTER TABLE "{{ .TableName }}" ADD COLUMN batch BIGINT`))
+
+var nextBatchTmpl = template.Must(template.New("nextBatch").Parse(`SELECT COALESCE(MAX(batch), 0) + 1 FROM "{{ .TableName }}"`))
+
 var selectMigrationsTmpl = template.Must(template.New("selectMigrationLogTemplate").Parse(`SELECT %s FROM "{{ .TableName }}"`))
 
 var insertMigrationTmpl = template.Must(
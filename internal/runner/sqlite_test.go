@@ -0,0 +1,470 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/keegancsmith/sqlf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BolajiOlajide/kat/internal/database"
+	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
+	"github.com/BolajiOlajide/kat/internal/loggr"
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// newSQLiteRunner returns a runner backed by a fresh SQLite database in a temporary directory.
+func newSQLiteRunner(t *testing.T) (*runner, database.DB) {
+	t.Helper()
+
+	logger := loggr.NewDefault()
+	db, err := database.New(dbdriver.SqliteDriver, filepath.Join(t.TempDir(), "kat.db"), logger)
+	require.NoError(t, err, "creating sqlite database")
+	t.Cleanup(func() { db.Close() })
+
+	r, err := NewRunner(context.Background(), db, logger)
+	require.NoError(t, err, "initializing runner")
+	return r.(*runner), db
+}
+
+func appliedNames(t *testing.T, r *runner) []string {
+	t.Helper()
+
+	logs, err := r.getAppliedMigrations(context.Background(), tracer(Options{}), migrationTableName, migrationLogColumns)
+	require.NoError(t, err, "fetching applied migrations")
+
+	var names []string
+	for name := range logs {
+		names = append(names, name)
+	}
+	return names
+}
+
+var irreversibleDefinitions = []types.Definition{
+	{
+		MigrationMetadata: types.MigrationMetadata{
+			Name:      "create_users",
+			Timestamp: 1747525262,
+		},
+		UpQuery:   sqlf.Sprintf("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		DownQuery: sqlf.Sprintf("DROP TABLE users;"),
+	},
+	{
+		MigrationMetadata: types.MigrationMetadata{
+			Name:         "drop_legacy",
+			Timestamp:    1747525318,
+			Parents:      []int64{1747525262},
+			Irreversible: true,
+		},
+		UpQuery:   sqlf.Sprintf("CREATE TABLE audit (id INTEGER PRIMARY KEY);"),
+		DownQuery: sqlf.Sprintf("-- nothing to do"),
+	},
+	{
+		MigrationMetadata: types.MigrationMetadata{
+			Name:      "create_posts",
+			Timestamp: 1747527900,
+			Parents:   []int64{1747525318},
+		},
+		UpQuery:   sqlf.Sprintf("CREATE TABLE posts (id INTEGER PRIMARY KEY);"),
+		DownQuery: sqlf.Sprintf("DROP TABLE posts;"),
+	},
+}
+
+func TestRun_Irreversible(t *testing.T) {
+	tests := []struct {
+		name            string
+		force           bool
+		dryRun          bool
+		expectErr       bool
+		expectedApplied []string
+	}{
+		{
+			name:            "stops before the irreversible migration",
+			expectErr:       true,
+			expectedApplied: []string{"1747525262_create_users", "1747525318_drop_legacy"},
+		},
+		{
+			name:            "dry run reports the irreversible migration",
+			dryRun:          true,
+			expectErr:       true,
+			expectedApplied: []string{"1747525262_create_users", "1747525318_drop_legacy", "1747527900_create_posts"},
+		},
+		{
+			name:            "force rolls back the irreversible migration",
+			force:           true,
+			expectedApplied: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, _ := newSQLiteRunner(t)
+			defs := createMigrationDef(t, irreversibleDefinitions...)
+			info := types.MigrationInfo{TableName: migrationTableName}
+
+			_, err := r.Run(ctx, Options{
+				Operation:     types.UpMigrationOperation,
+				Definitions:   defs,
+				MigrationInfo: info,
+			})
+			require.NoError(t, err, "applying migrations")
+
+			_, err = r.Run(ctx, Options{
+				Operation:     types.DownMigrationOperation,
+				Definitions:   defs,
+				MigrationInfo: info,
+				DryRun:        tt.dryRun,
+				Force:         tt.force,
+			})
+			if tt.expectErr {
+				var irrErr *types.IrreversibleMigrationError
+				require.True(t, errors.As(err, &irrErr), "expected an IrreversibleMigrationError, got %v", err)
+				require.Equal(t, "1747525318_drop_legacy", irrErr.Name)
+			} else {
+				require.NoError(t, err, "rolling back migrations")
+			}
+
+			require.ElementsMatch(t, tt.expectedApplied, appliedNames(t, r))
+		})
+	}
+}
+
+func TestMarkApplied(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newSQLiteRunner(t)
+	defs := createMigrationDef(t, irreversibleDefinitions...)
+	info := types.MigrationInfo{TableName: migrationTableName}
+
+	appliedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	recorded, err := r.MarkApplied(ctx, Options{Definitions: defs, MigrationInfo: info}, map[int64]time.Time{
+		1747525262: appliedAt,
+		1747525318: {},
+	})
+	require.NoError(t, err, "marking migrations as applied")
+	require.Len(t, recorded, 2)
+	require.ElementsMatch(t, []string{"1747525262_create_users", "1747525318_drop_legacy"}, appliedNames(t, r))
+
+	statuses, err := r.Status(ctx, Options{Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err, "fetching statuses")
+	require.True(t, statuses[0].Log.MigrationTime.Equal(appliedAt), "got %s", statuses[0].Log.MigrationTime)
+
+	// Recorded migrations are skipped by up and not recorded twice.
+	results, err := r.Run(ctx, Options{Operation: types.UpMigrationOperation, Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err, "applying the remaining migration")
+	require.Len(t, results, 1)
+	require.Equal(t, "1747527900_create_posts", results[0].Name)
+
+	recorded, err = r.MarkApplied(ctx, Options{Definitions: defs, MigrationInfo: info}, map[int64]time.Time{1747525262: {}})
+	require.NoError(t, err)
+	require.Empty(t, recorded)
+}
+
+func TestRun_Orphans(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    types.OrphanPolicy
+		expectErr bool
+	}{
+		{name: "default policy warns", policy: ""},
+		{name: "ignore", policy: types.OrphanPolicyIgnore},
+		{name: "warn", policy: types.OrphanPolicyWarn},
+		{name: "error", policy: types.OrphanPolicyError, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, _ := newSQLiteRunner(t)
+
+			// A newer release applied all three migrations; this one only knows the first.
+			_, err := r.Run(ctx, Options{
+				Operation:     types.UpMigrationOperation,
+				Definitions:   createMigrationDef(t, irreversibleDefinitions...),
+				MigrationInfo: types.MigrationInfo{TableName: migrationTableName},
+			})
+			require.NoError(t, err, "applying migrations")
+
+			older := createMigrationDef(t, irreversibleDefinitions[0])
+			info := types.MigrationInfo{TableName: migrationTableName, Orphans: tt.policy}
+			results, err := r.Run(ctx, Options{Operation: types.UpMigrationOperation, Definitions: older, MigrationInfo: info})
+			if tt.expectErr {
+				var orphanErr *types.OrphanedMigrationsError
+				require.True(t, errors.As(err, &orphanErr), "expected an OrphanedMigrationsError, got %v", err)
+				require.Equal(t, []string{"1747525318_drop_legacy", "1747527900_create_posts"}, orphanErr.Names)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Empty(t, results)
+
+			statuses, err := r.Status(ctx, Options{Definitions: older, MigrationInfo: info})
+			require.NoError(t, err, "fetching statuses")
+			require.Len(t, statuses, 3)
+			require.False(t, statuses[0].Orphaned)
+			for i, name := range []string{"drop_legacy", "create_posts"} {
+				s := statuses[i+1]
+				require.True(t, s.Orphaned, "expected %s to be orphaned", name)
+				require.Equal(t, name, s.Definition.Name)
+				require.Equal(t, irreversibleDefinitions[i+1].Timestamp, s.Definition.Timestamp)
+			}
+		})
+	}
+}
+
+func TestRun_Batch(t *testing.T) {
+	ctx := context.Background()
+	r, db := newSQLiteRunner(t)
+	info := types.MigrationInfo{TableName: migrationTableName}
+
+	createUsers := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_users", Timestamp: 1747525262},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE users;"),
+	}
+	createPosts := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_posts", Timestamp: 1747527900, Parents: []int64{1747525262}},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE posts (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE posts;"),
+	}
+	// Merged from a branch after create_posts was deployed, with an older timestamp.
+	createAudit := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_audit", Timestamp: 1747526000, Parents: []int64{1747525262}},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE audit (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE audit;"),
+	}
+	createComments := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_comments", Timestamp: 1747528000, Parents: []int64{1747527900}},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE comments (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE comments;"),
+	}
+
+	// A tracking table from before batches were recorded gets the column added, and its
+	// rows have no batch.
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`CREATE TABLE "migration_logs" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    migration_time TEXT NOT NULL DEFAULT (datetime('now')),
+    duration TEXT NOT NULL
+)`)))
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`CREATE TABLE users (id INTEGER PRIMARY KEY)`)))
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`INSERT INTO "migration_logs" (name, duration) VALUES ('1747525262_create_users', '1ms')`)))
+
+	defs := createMigrationDef(t, createUsers)
+	_, err := r.Run(ctx, Options{Operation: types.DownMigrationOperation, Definitions: defs, MigrationInfo: info, Batch: true})
+	require.ErrorContains(t, err, "can only be rolled back by count")
+
+	defs = createMigrationDef(t, createUsers, createPosts)
+	_, err = r.Run(ctx, Options{Operation: types.UpMigrationOperation, Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err, "applying the first deploy")
+
+	defs = createMigrationDef(t, createUsers, createPosts, createAudit, createComments)
+	_, err = r.Run(ctx, Options{Operation: types.UpMigrationOperation, Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err, "applying the second deploy")
+
+	statuses, err := r.Status(ctx, Options{Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err)
+	batches := make(map[string]int64, len(statuses))
+	for _, s := range statuses {
+		batches[s.Definition.FileName()] = s.Log.Batch
+	}
+	require.Equal(t, map[string]int64{
+		"1747525262_create_users":    0,
+		"1747526000_create_audit":    2,
+		"1747527900_create_posts":    1,
+		"1747528000_create_comments": 2,
+	}, batches)
+
+	results, err := r.Run(ctx, Options{Operation: types.DownMigrationOperation, Definitions: defs, MigrationInfo: info, Batch: true})
+	require.NoError(t, err, "rolling back the second deploy")
+	var rolledBack []string
+	for _, result := range results {
+		rolledBack = append(rolledBack, result.Name)
+	}
+	require.Equal(t, []string{"1747528000_create_comments", "1747526000_create_audit"}, rolledBack)
+	require.ElementsMatch(t, []string{"1747525262_create_users", "1747527900_create_posts"}, appliedNames(t, r))
+
+	results, err = r.Run(ctx, Options{Operation: types.DownMigrationOperation, Definitions: defs, MigrationInfo: info, Batch: true})
+	require.NoError(t, err, "rolling back the first deploy")
+	require.Len(t, results, 1)
+	require.Equal(t, []string{"1747525262_create_users"}, appliedNames(t, r))
+}
+
+func TestRun_Only(t *testing.T) {
+	tests := []struct {
+		name            string
+		applied         int
+		only            int64
+		expectErr       string
+		expectedApplied []string
+	}{
+		{
+			name:            "parents applied",
+			applied:         1,
+			only:            1747525318,
+			expectedApplied: []string{"1747525262_create_users", "1747525318_drop_legacy"},
+		},
+		{
+			name:            "already applied",
+			applied:         2,
+			only:            1747525318,
+			expectedApplied: []string{"1747525262_create_users", "1747525318_drop_legacy"},
+		},
+		{
+			name:            "pending parent",
+			applied:         1,
+			only:            1747527900,
+			expectErr:       "cannot apply 1747527900_create_posts on its own: parent migration(s) 1747525318_drop_legacy are not applied yet",
+			expectedApplied: []string{"1747525262_create_users"},
+		},
+		{
+			name:            "unknown migration",
+			only:            42,
+			expectErr:       "migration 42 does not exist",
+			expectedApplied: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, _ := newSQLiteRunner(t)
+			defs := createMigrationDef(t, irreversibleDefinitions...)
+			info := types.MigrationInfo{TableName: migrationTableName}
+
+			if tt.applied > 0 {
+				_, err := r.Run(ctx, Options{Operation: types.UpMigrationOperation, Definitions: defs, MigrationInfo: info, Count: tt.applied})
+				require.NoError(t, err, "applying migrations")
+			}
+
+			_, err := r.Run(ctx, Options{Operation: types.UpMigrationOperation, Definitions: defs, MigrationInfo: info, Only: tt.only})
+			if tt.expectErr != "" {
+				require.EqualError(t, err, tt.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.ElementsMatch(t, tt.expectedApplied, appliedNames(t, r))
+		})
+	}
+}
+
+func TestRun_OutOfOrder(t *testing.T) {
+	createUsers := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_users", Timestamp: 1747525262},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE users;"),
+	}
+	createPosts := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_posts", Timestamp: 1747527900, Parents: []int64{1747525262}},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE posts (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE posts;"),
+	}
+	// Merged from a branch after create_posts was applied, with an older timestamp.
+	createAudit := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_audit", Timestamp: 1747526000, Parents: []int64{1747525262}},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE audit (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE audit;"),
+	}
+	createComments := types.Definition{
+		MigrationMetadata: types.MigrationMetadata{Name: "create_comments", Timestamp: 1747528000, Parents: []int64{1747527900}},
+		UpQuery:           sqlf.Sprintf("CREATE TABLE comments (id INTEGER PRIMARY KEY);"),
+		DownQuery:         sqlf.Sprintf("DROP TABLE comments;"),
+	}
+
+	tests := []struct {
+		name            string
+		policy          types.OutOfOrderPolicy
+		only            int64
+		expectErr       bool
+		expectedApplied []string
+	}{
+		{
+			name:            "default policy allows",
+			expectedApplied: []string{"1747525262_create_users", "1747526000_create_audit", "1747527900_create_posts", "1747528000_create_comments"},
+		},
+		{
+			name:            "warn",
+			policy:          types.OutOfOrderWarn,
+			expectedApplied: []string{"1747525262_create_users", "1747526000_create_audit", "1747527900_create_posts", "1747528000_create_comments"},
+		},
+		{
+			name:            "error",
+			policy:          types.OutOfOrderError,
+			expectErr:       true,
+			expectedApplied: []string{"1747525262_create_users", "1747527900_create_posts"},
+		},
+		{
+			name:            "error ignores migrations that are in order",
+			policy:          types.OutOfOrderError,
+			only:            1747528000,
+			expectedApplied: []string{"1747525262_create_users", "1747527900_create_posts", "1747528000_create_comments"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r, _ := newSQLiteRunner(t)
+
+			_, err := r.Run(ctx, Options{
+				Operation:     types.UpMigrationOperation,
+				Definitions:   createMigrationDef(t, createUsers, createPosts),
+				MigrationInfo: types.MigrationInfo{TableName: migrationTableName},
+			})
+			require.NoError(t, err, "applying the first deploy")
+
+			_, err = r.Run(ctx, Options{
+				Operation:     types.UpMigrationOperation,
+				Definitions:   createMigrationDef(t, createUsers, createPosts, createAudit, createComments),
+				MigrationInfo: types.MigrationInfo{TableName: migrationTableName, OutOfOrder: tt.policy},
+				Only:          tt.only,
+			})
+			if tt.expectErr {
+				var outOfOrderErr *types.OutOfOrderMigrationsError
+				require.True(t, errors.As(err, &outOfOrderErr), "expected an OutOfOrderMigrationsError, got %v", err)
+				require.Equal(t, []string{"1747526000_create_audit"}, outOfOrderErr.Names)
+				require.Equal(t, "1747527900_create_posts", outOfOrderErr.Latest)
+			} else {
+				require.NoError(t, err)
+			}
+			require.ElementsMatch(t, tt.expectedApplied, appliedNames(t, r))
+		})
+	}
+}
+
+func TestStatus_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	r, db := newSQLiteRunner(t)
+	defs := createMigrationDef(t, irreversibleDefinitions...)
+	info := types.MigrationInfo{TableName: migrationTableName}
+
+	// Without a tracking table every migration is pending and no table is created.
+	statuses, err := r.Status(ctx, Options{Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	for _, s := range statuses {
+		require.False(t, s.Applied())
+	}
+	columns, err := r.trackingColumns(ctx, migrationTableName)
+	require.NoError(t, err)
+	require.Empty(t, columns)
+
+	// A table from before batches were recorded is read as it is.
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`CREATE TABLE "migration_logs" (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    migration_time TEXT NOT NULL DEFAULT (datetime('now')),
+    duration TEXT NOT NULL
+)`)))
+	require.NoError(t, db.Exec(ctx, sqlf.Sprintf(`INSERT INTO "migration_logs" (name, duration) VALUES ('1747525262_create_users', '1ms')`)))
+
+	statuses, err = r.Status(ctx, Options{Definitions: defs, MigrationInfo: info})
+	require.NoError(t, err)
+	require.True(t, statuses[0].Applied())
+	require.Zero(t, statuses[0].Log.Batch)
+	columns, err = r.trackingColumns(ctx, migrationTableName)
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "name", "migration_time", "duration"}, columns)
+}
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// TracerName is the instrumentation scope name used for every span kat creates.
+const TracerName = "github.com/BolajiOlajide/kat"
+
+// Span attribute keys.
+const (
+	attrOperation          = attribute.Key("kat.operation")
+	attrDryRun             = attribute.Key("kat.dry_run")
+	attrCount              = attribute.Key("kat.count")
+	attrMigrationsExecuted = attribute.Key("kat.migrations.executed")
+	attrMigrationName      = attribute.Key("kat.migration.name")
+	attrMigrationTimestamp = attribute.Key("kat.migration.timestamp")
+	attrNoTransaction      = attribute.Key("kat.migration.no_transaction")
+	attrRowsAffected       = attribute.Key("kat.migration.rows_affected")
+	attrTrackingTable      = attribute.Key("kat.tracking.table")
+	attrDBSystem           = attribute.Key("db.system")
+)
+
+// tracer returns the tracer configured in options, or a no-op tracer when tracing is off.
+func tracer(options Options) trace.Tracer {
+	if options.Tracer == nil {
+		return noop.NewTracerProvider().Tracer(TracerName)
+	}
+	return options.Tracer
+}
+
+// migrationAttributes returns the span attributes that identify a migration.
+func migrationAttributes(definition types.Definition, operation types.MigrationOperationType) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attrMigrationName.String(definition.FileName()),
+		attrMigrationTimestamp.Int64(definition.Timestamp),
+		attrOperation.String(operation.String()),
+		attrNoTransaction.Bool(definition.NoTransaction),
+	}
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+func TestRun_Tracing(t *testing.T) {
+	tests := []struct {
+		name            string
+		definitions     []types.Definition
+		expectErr       bool
+		expectedSpans   []string
+		expectedStatus  codes.Code
+		migrationStatus codes.Code
+	}{
+		{
+			name: "successful run",
+			definitions: []types.Definition{{
+				MigrationMetadata: types.MigrationMetadata{Name: "create_users", Timestamp: 1747525262},
+				UpQuery:           sqlf.Sprintf("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+				DownQuery:         sqlf.Sprintf("DROP TABLE users;"),
+			}},
+			expectedSpans:   []string{"kat.tracking.ensure_table", "kat.tracking.read", "kat.tracking.update", "kat.migration", "kat.up"},
+			expectedStatus:  codes.Unset,
+			migrationStatus: codes.Unset,
+		},
+		{
+			name: "failed migration",
+			definitions: []types.Definition{{
+				MigrationMetadata: types.MigrationMetadata{Name: "broken", Timestamp: 1747525262, NoTransaction: true},
+				UpQuery:           sqlf.Sprintf("CREATE TABLE;"),
+				DownQuery:         sqlf.Sprintf("SELECT 1;"),
+			}},
+			expectErr:       true,
+			expectedSpans:   []string{"kat.tracking.ensure_table", "kat.tracking.read", "kat.migration", "kat.up"},
+			expectedStatus:  codes.Error,
+			migrationStatus: codes.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			r, _ := newSQLiteRunner(t)
+			_, err := r.Run(context.Background(), Options{
+				Operation:     types.UpMigrationOperation,
+				Definitions:   createMigrationDef(t, tt.definitions...),
+				MigrationInfo: types.MigrationInfo{TableName: migrationTableName},
+				Tracer:        tp.Tracer(TracerName),
+			})
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			spans := exporter.GetSpans()
+			var names []string
+			byName := map[string]tracetest.SpanStub{}
+			for _, s := range spans {
+				names = append(names, s.Name)
+				byName[s.Name] = s
+			}
+			require.Equal(t, tt.expectedSpans, names)
+
+			root := byName["kat.up"]
+			require.Equal(t, tt.expectedStatus, root.Status.Code)
+
+			migration := byName["kat.migration"]
+			require.Equal(t, root.SpanContext.SpanID(), migration.Parent.SpanID(), "migration span should be a child of the run span")
+			require.Equal(t, tt.migrationStatus, migration.Status.Code)
+
+			attrs := attribute.NewSet(migration.Attributes...)
+			name, _ := attrs.Value(attrMigrationName)
+			require.Equal(t, tt.definitions[0].FileName(), name.AsString())
+			ts, _ := attrs.Value(attrMigrationTimestamp)
+			require.Equal(t, tt.definitions[0].Timestamp, ts.AsInt64())
+			noTx, _ := attrs.Value(attrNoTransaction)
+			require.Equal(t, tt.definitions[0].NoTransaction, noTx.AsBool())
+			_, hasRows := attrs.Value(attrRowsAffected)
+			require.Equal(t, !tt.expectErr, hasRows)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package runner
 
 import (
 	"bytes"
+	"slices"
 	"text/template"
 
 	"github.com/keegancsmith/sqlf"
@@ -12,17 +13,40 @@ var migrationLogColumns = []string{
 	"name",
 	"migration_time",
 	"duration",
+	"batch",
 }
 
+// addedMigrationLogColumns are the columns kat adds to tracking tables created by older
+// versions the first time it runs against them.
+var addedMigrationLogColumns = []string{"batch"}
+
 var migrationLogInsertColumns = []*sqlf.Query{
 	sqlf.Sprintf("name"),
 	sqlf.Sprintf("migration_time"),
 	sqlf.Sprintf("duration"),
+	sqlf.Sprintf("batch"),
+}
+
+// MigrationLogColumns returns the columns of the tracking table.
+func MigrationLogColumns() []string {
+	return slices.Clone(migrationLogColumns)
+}
+
+// AddedMigrationLogColumns returns the columns of the tracking table that older versions
+// of kat didn't create. Kat adds them to an existing table when it next runs.
+func AddedMigrationLogColumns() []string {
+	return slices.Clone(addedMigrationLogColumns)
 }
 
-func computeMigrationLogColumns() []*sqlf.Query {
+// computeSelectColumns returns the columns to read from a tracking table that has the
+// given columns, with NULL in place of the added columns it lacks.
+func computeSelectColumns(existing []string) []*sqlf.Query {
 	var cols = make([]*sqlf.Query, len(migrationLogColumns))
 	for index, column := range migrationLogColumns {
+		if slices.Contains(addedMigrationLogColumns, column) && !slices.Contains(existing, column) {
+			cols[index] = sqlf.Sprintf("NULL")
+			continue
+		}
 		cols[index] = sqlf.Sprintf(column)
 	}
 	return cols
@@ -36,6 +60,14 @@ func computeCreateMigrationLogQuery(tableName string, isSQLite bool) (string, er
 	return computeSQLQueryFromTemplate(tableName, tmpl)
 }
 
+func computeAddBatchColumnQuery(tableName string) (string, error) {
+	return computeSQLQueryFromTemplate(tableName, addBatchColumnTmpl)
+}
+
+func computeNextBatchQuery(tableName string) (string, error) {
+	return computeSQLQueryFromTemplate(tableName, nextBatchTmpl)
+}
+
 func computeSelectMigrationLogQuery(tableName string) (string, error) {
 	return computeSQLQueryFromTemplate(tableName, selectMigrationsTmpl)
 }
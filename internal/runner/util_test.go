@@ -102,55 +102,47 @@ func TestHasMultipleStatements(t *testing.T) {
 	}
 }
 
-func TestComputeMigrationLogColumns(t *testing.T) {
+func TestComputeSelectColumns(t *testing.T) {
 	tests := []struct {
-		name      string
-		tableName string
-		want      []*sqlf.Query
+		name     string
+		existing []string
+		want     []*sqlf.Query
 	}{
 		{
-			name:      "valid table name",
-			tableName: "migrations",
-			want: []*sqlf.Query{
-				sqlf.Sprintf("id"),
-				sqlf.Sprintf("name"),
-				sqlf.Sprintf("migration_time"),
-				sqlf.Sprintf("duration"),
-			},
-		},
-		{
-			name:      "empty table name",
-			tableName: "",
+			name:     "current table",
+			existing: []string{"id", "name", "migration_time", "duration", "batch"},
 			want: []*sqlf.Query{
 				sqlf.Sprintf("id"),
 				sqlf.Sprintf("name"),
 				sqlf.Sprintf("migration_time"),
 				sqlf.Sprintf("duration"),
+				sqlf.Sprintf("batch"),
 			},
 		},
 		{
-			name:      "table name with schema",
-			tableName: "public.migrations",
+			name:     "table created before batches",
+			existing: []string{"id", "name", "migration_time", "duration"},
 			want: []*sqlf.Query{
 				sqlf.Sprintf("id"),
 				sqlf.Sprintf("name"),
 				sqlf.Sprintf("migration_time"),
 				sqlf.Sprintf("duration"),
+				sqlf.Sprintf("NULL"),
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := computeMigrationLogColumns()
+			got := computeSelectColumns(tt.existing)
 			if len(got) != len(tt.want) {
-				t.Errorf("computeMigrationLogColumns() length = %v, want %v", len(got), len(tt.want))
+				t.Errorf("computeSelectColumns() length = %v, want %v", len(got), len(tt.want))
 				return
 			}
 
 			for i := range got {
 				if got[i].Query(sqlf.PostgresBindVar) != tt.want[i].Query(sqlf.PostgresBindVar) {
-					t.Errorf("computeMigrationLogColumns()[%d] = %v, want %v", i, got[i].Query(sqlf.PostgresBindVar), tt.want[i].Query(sqlf.PostgresBindVar))
+					t.Errorf("computeSelectColumns()[%d] = %v, want %v", i, got[i].Query(sqlf.PostgresBindVar), tt.want[i].Query(sqlf.PostgresBindVar))
 				}
 			}
 		})
@@ -0,0 +1,64 @@
+// Package tracing builds the OpenTelemetry tracer provider used by the kat CLI when
+// tracing is enabled in the configuration file.
+package tracing
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/BolajiOlajide/kat/internal/types"
+)
+
+// DefaultServiceName is reported as service.name when the configuration doesn't set one.
+const DefaultServiceName = "kat"
+
+// ShutdownFunc flushes pending spans and releases the exporter.
+type ShutdownFunc func(context.Context) error
+
+// NewProvider returns a tracer provider that exports spans over OTLP/HTTP as described by
+// cfg. When tracing is disabled it returns a no-op provider. The returned ShutdownFunc must
+// be called before the process exits so buffered spans are sent.
+func NewProvider(ctx context.Context, cfg types.TracingInfo) (trace.TracerProvider, ShutdownFunc, error) {
+	if !cfg.Enabled {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	// Options left unset fall back to the standard OTEL_EXPORTER_OTLP_* environment variables.
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating OTLP trace exporter")
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "building trace resource")
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	return tp, tp.Shutdown, nil
+}
@@ -1,9 +1,14 @@
 package types
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/url"
+	"os/exec"
 	"regexp"
+	"runtime"
+	"strings"
 	"time"
 
 	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
@@ -15,16 +20,148 @@ var validTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 type Config struct {
 	Migration MigrationInfo `yaml:"migration"`
 	Database  DatabaseInfo  `yaml:"database"`
+	Tracing   TracingInfo   `yaml:"tracing,omitempty"`
 	Verbose   bool          `yaml:"verbose"`
 }
 
+// TracingInfo configures OpenTelemetry trace export for the CLI. Spans are sent over
+// OTLP/HTTP; unset fields fall back to the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingInfo struct {
+	Enabled     bool              `yaml:"enabled"`
+	Endpoint    string            `yaml:"endpoint,omitempty"`
+	Insecure    bool              `yaml:"insecure,omitempty"`
+	ServiceName string            `yaml:"service_name,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+}
+
 type MigrationInfo struct {
 	TableName string `yaml:"tablename"`
 	Directory string `yaml:"directory"`
+
+	// Directories lists several migration directories, each with its own namespace, that
+	// share one database and tracking table. It cannot be combined with Directory.
+	Directories []MigrationDirectory `yaml:"directories,omitempty"`
+
+	// RequireSingleHead makes `kat up` refuse to run while a namespace has more than one
+	// head, so diverged branches have to be merged with `kat merge` first.
+	RequireSingleHead bool `yaml:"require_single_head,omitempty"`
+
+	// Templates is a directory of named templates for `kat add --template`. Templates in it
+	// take precedence over the built-in ones with the same name.
+	Templates string `yaml:"templates,omitempty"`
+
+	// Orphans decides what runs do with tracking table entries that no migration matches.
+	// Empty means OrphanPolicyWarn.
+	Orphans OrphanPolicy `yaml:"orphans,omitempty"`
+
+	// OutOfOrder decides what `kat up` does with pending migrations that come before an
+	// applied one in topological order. Empty means OutOfOrderAllow.
+	OutOfOrder OutOfOrderPolicy `yaml:"out_of_order,omitempty"`
+}
+
+// OrphanPolicy decides how a run treats orphaned migrations: entries in the tracking table
+// without a matching definition, usually left by a newer release of the application.
+type OrphanPolicy string
+
+const (
+	// OrphanPolicyIgnore runs migrations as if the orphaned entries were not there.
+	OrphanPolicyIgnore OrphanPolicy = "ignore"
+	// OrphanPolicyWarn logs a warning naming the orphaned migrations and carries on.
+	OrphanPolicyWarn OrphanPolicy = "warn"
+	// OrphanPolicyError stops the run with an *OrphanedMigrationsError.
+	OrphanPolicyError OrphanPolicy = "error"
+)
+
+// Valid reports whether p is one of the known policies or empty.
+func (p OrphanPolicy) Valid() bool {
+	switch p {
+	case "", OrphanPolicyIgnore, OrphanPolicyWarn, OrphanPolicyError:
+		return true
+	}
+	return false
+}
+
+// OutOfOrderPolicy decides how an up run treats out-of-order migrations: pending
+// migrations that come before the most recently applied one in topological order, usually
+// because a branch with older migrations was merged after newer ones were deployed.
+type OutOfOrderPolicy string
+
+const (
+	// OutOfOrderAllow applies out-of-order migrations like any other.
+	OutOfOrderAllow OutOfOrderPolicy = "allow"
+	// OutOfOrderWarn logs a warning naming the out-of-order migrations and applies them.
+	OutOfOrderWarn OutOfOrderPolicy = "warn"
+	// OutOfOrderError stops the run with an *OutOfOrderMigrationsError.
+	OutOfOrderError OutOfOrderPolicy = "error"
+)
+
+// Valid reports whether p is one of the known policies or empty.
+func (p OutOfOrderPolicy) Valid() bool {
+	switch p {
+	case "", OutOfOrderAllow, OutOfOrderWarn, OutOfOrderError:
+		return true
+	}
+	return false
+}
+
+// MigrationDirectory is one entry of `migration.directories`.
+type MigrationDirectory struct {
+	Namespace string `yaml:"namespace"`
+	Path      string `yaml:"path"`
+}
+
+// MigrationDirectories returns every configured migrations directory. A project using the
+// single `directory` setting has one entry without a namespace.
+func (m MigrationInfo) MigrationDirectories() []MigrationDirectory {
+	if len(m.Directories) > 0 {
+		return m.Directories
+	}
+	return []MigrationDirectory{{Path: m.Directory}}
+}
+
+// MigrationDirectory returns the directory with the given namespace. An empty namespace
+// selects the only directory, or the first one when several are configured.
+func (m MigrationInfo) MigrationDirectory(namespace string) (MigrationDirectory, error) {
+	dirs := m.MigrationDirectories()
+	if namespace == "" {
+		return dirs[0], nil
+	}
+	for _, dir := range dirs {
+		if dir.Namespace == namespace {
+			return dir, nil
+		}
+	}
+	return MigrationDirectory{}, errors.Newf("unknown migration namespace %q", namespace)
+}
+
+var validNamespace = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func (m MigrationInfo) validateDirectories() error {
+	if len(m.Directories) == 0 {
+		return nil
+	}
+	if m.Directory != "" {
+		return errors.New("migration.directory and migration.directories cannot both be set")
+	}
+
+	seen := make(map[string]bool, len(m.Directories))
+	for _, dir := range m.Directories {
+		if !validNamespace.MatchString(dir.Namespace) {
+			return errors.Newf("invalid migration namespace %q: must match [a-z0-9][a-z0-9_-]*", dir.Namespace)
+		}
+		if seen[dir.Namespace] {
+			return errors.Newf("duplicate migration namespace %q", dir.Namespace)
+		}
+		seen[dir.Namespace] = true
+		if dir.Path == "" {
+			return errors.Newf("migration namespace %q has no path", dir.Namespace)
+		}
+	}
+	return nil
 }
 
 func (c *Config) SetDefault() error {
-	if c.Migration.Directory == "" {
+	if c.Migration.Directory == "" && len(c.Migration.Directories) == 0 {
 		c.Migration.Directory = "migrations"
 	}
 
@@ -53,7 +190,13 @@ func (c *Config) Validate() error {
 	if !validTableName.MatchString(c.Migration.TableName) {
 		return errors.Newf("invalid migration table name %q: must match [A-Za-z_][A-Za-z0-9_]*", c.Migration.TableName)
 	}
-	return nil
+	if !c.Migration.Orphans.Valid() {
+		return errors.Newf("invalid migration.orphans %q: must be one of ignore, warn or error", c.Migration.Orphans)
+	}
+	if !c.Migration.OutOfOrder.Valid() {
+		return errors.Newf("invalid migration.out_of_order %q: must be one of allow, warn or error", c.Migration.OutOfOrder)
+	}
+	return c.Migration.validateDirectories()
 }
 
 // ValidateTableName checks whether a table name is safe for use in SQL templates.
@@ -77,6 +220,17 @@ type DatabaseInfo struct {
 	Path string `yaml:"path,omitempty"`
 	URL  string `yaml:"url,omitempty"`
 
+	// PasswordFile and URLFile name files holding the password and URL, such as mounted
+	// secrets. They cannot be combined with Password and URL.
+	PasswordFile string `yaml:"password_file,omitempty"`
+	URLFile      string `yaml:"url_file,omitempty"`
+
+	// PasswordCommand and URLCommand are shell commands that print the password and URL,
+	// for credentials such as short-lived tokens that must be generated right before
+	// connecting. ConnString runs them every time it is called.
+	PasswordCommand string `yaml:"password_command,omitempty"`
+	URLCommand      string `yaml:"url_command,omitempty"`
+
 	ConnectTimeout   string `yaml:"connect_timeout,omitempty"`
 	StatementTimeout string `yaml:"statement_timeout,omitempty"`
 	MaxOpenConns     int    `yaml:"max_open_conns,omitempty"`
@@ -146,21 +300,83 @@ func (d *DatabaseInfo) ConnString() (string, error) {
 	}
 
 	// at this point, we can assume the driver is postgres
-	if d.URL != "" {
+	if d.URL != "" || d.URLCommand != "" {
+		u := d.URL
+		if d.URLCommand != "" {
+			var err error
+			if u, err = runCredentialCommand("url_command", d.URLCommand); err != nil {
+				return "", err
+			}
+		}
 		// Validate the scheme but return the original URL unchanged to preserve
 		// query params, special characters in passwords, and connection options.
-		if err := d.validateURL(); err != nil {
+		if err := validateURL(u); err != nil {
+			return "", err
+		}
+		return u, nil
+	}
+
+	password := d.Password
+	if d.PasswordCommand != "" {
+		var err error
+		if password, err = runCredentialCommand("password_command", d.PasswordCommand); err != nil {
 			return "", err
 		}
-		return d.URL, nil
 	}
 
 	// if a url isn't provided, use the traditional connection string format
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode), nil
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", d.Host, d.Port, d.User, quoteDSNValue(password), d.Name, d.SSLMode), nil
+}
+
+// HasCredentialCommand reports whether the password or URL is produced by a command, so
+// ConnString returns fresh credentials each time it is called.
+func (d *DatabaseInfo) HasCredentialCommand() bool {
+	return d.PasswordCommand != "" || d.URLCommand != ""
+}
+
+// credentialCommandTimeout bounds how long password_command and url_command can run.
+const credentialCommandTimeout = 30 * time.Second
+
+// runCredentialCommand runs the command set in field and returns what it printed, without
+// the trailing newline.
+func runCredentialCommand(field, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrapf(err, "running database.%s: %s", field, msg)
+		}
+		return "", errors.Wrapf(err, "running database.%s", field)
+	}
+	value := strings.TrimRight(string(out), "\r\n")
+	if value == "" {
+		return "", errors.Newf("database.%s printed nothing", field)
+	}
+	return value, nil
+}
+
+// quoteDSNValue quotes a value of a key=value connection string when it is empty or holds
+// spaces, quotes or backslashes, as generated tokens often do.
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t'\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
-func (d *DatabaseInfo) validateURL() error {
-	parsedURL, err := url.Parse(d.URL)
+func validateURL(u string) error {
+	parsedURL, err := url.Parse(u)
 	if err != nil {
 		return errors.Newf("failed to parse URL: %v", err)
 	}
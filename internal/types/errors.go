@@ -0,0 +1,46 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IrreversibleMigrationError is returned when a down operation reaches a migration
+// that is marked as irreversible and the caller did not ask to force the rollback.
+type IrreversibleMigrationError struct {
+	// Name is the file name of the irreversible migration, e.g. "1679012345_drop_legacy".
+	Name string
+}
+
+func (e *IrreversibleMigrationError) Error() string {
+	return fmt.Sprintf("migration %q is irreversible; use --force to roll it back anyway", e.Name)
+}
+
+// OrphanedMigrationsError is returned when the tracking table records migrations that no
+// definition matches and the orphan policy is OrphanPolicyError. This usually means the
+// database was migrated by a newer release of the application.
+type OrphanedMigrationsError struct {
+	// Names are the file names of the orphaned migrations in the order they were applied.
+	Names []string
+}
+
+func (e *OrphanedMigrationsError) Error() string {
+	return fmt.Sprintf("the database has %d migration(s) with no matching definition: %s",
+		len(e.Names), strings.Join(e.Names, ", "))
+}
+
+// OutOfOrderMigrationsError is returned when an up run would apply migrations that come
+// before an already applied one in topological order and the out-of-order policy is
+// OutOfOrderError.
+type OutOfOrderMigrationsError struct {
+	// Names are the file names of the out-of-order migrations in the order they would run.
+	Names []string
+
+	// Latest is the file name of the last applied migration in topological order.
+	Latest string
+}
+
+func (e *OutOfOrderMigrationsError) Error() string {
+	return fmt.Sprintf("%d pending migration(s) come before the already applied %s: %s",
+		len(e.Names), e.Latest, strings.Join(e.Names, ", "))
+}
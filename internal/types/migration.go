@@ -10,9 +10,16 @@
 package types
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/keegancsmith/sqlf"
+	"gopkg.in/yaml.v3"
 )
 
 // Definition represents the definition of a single migration.
@@ -22,14 +29,56 @@ type Definition struct {
 
 	UpQuery   *sqlf.Query
 	DownQuery *sqlf.Query
+
+	// Go is set for migrations written in Go instead of SQL. UpQuery and DownQuery
+	// are unused for these.
+	Go *GoMigration
+}
+
+// GoMigration holds the functions of a migration written in Go. Transactional
+// migrations set Up and Down; migrations marked NoTransaction set UpNoTx and DownNoTx.
+// A nil down function makes the rollback a no-op.
+type GoMigration struct {
+	Up   func(ctx context.Context, tx *sql.Tx) error
+	Down func(ctx context.Context, tx *sql.Tx) error
+
+	UpNoTx   func(ctx context.Context, db *sql.DB) error
+	DownNoTx func(ctx context.Context, db *sql.DB) error
 }
 
 func (d Definition) FileName() string {
 	return fmt.Sprintf("%d_%s", d.Timestamp, d.Name)
 }
 
+// nanosecondIDThreshold separates second-based migration IDs from nanosecond-based ones.
+// Second-based IDs won't reach it for millions of years, and every nanosecond ID since
+// January 2, 1970 is above it.
+const nanosecondIDThreshold = 1e14
+
+// NewMigrationID returns the ID of a migration created at t: its Unix time in nanoseconds.
+// Migrations created in the same second get different IDs, and the IDs sort after the
+// second-based timestamps of migrations created by older versions of Kat.
+func NewMigrationID(t time.Time) int64 {
+	return t.UTC().UnixNano()
+}
+
+// MigrationIDTime returns the creation time encoded in a migration ID, which is a Unix
+// time in either seconds or nanoseconds.
+func MigrationIDTime(id int64) time.Time {
+	if id >= nanosecondIDThreshold {
+		return time.Unix(0, id).UTC()
+	}
+	return time.Unix(id, 0).UTC()
+}
+
 // TemporaryMigrationInfo represents a temporary migration file definition for creation.
 type TemporaryMigrationInfo struct {
+	// Name is the name of the migration's directory, or of its file without the .sql
+	// extension for single-file migrations.
+	Name string
+
+	// Up, Down and Metadata are the paths of the migration's files. They are all the same
+	// file for single-file migrations.
 	Up        string
 	Down      string
 	Metadata  string
@@ -50,6 +99,111 @@ type MigrationMetadata struct {
 	// This is required for operations like CREATE INDEX CONCURRENTLY which cannot run
 	// inside a transaction block.
 	NoTransaction bool `yaml:"no_transaction,omitempty"`
+
+	// Irreversible indicates that this migration cannot be undone. Down operations stop
+	// before an irreversible migration unless they are explicitly forced.
+	Irreversible bool `yaml:"irreversible,omitempty"`
+
+	// Namespace is the namespace of the directory the migration was loaded from. It is
+	// empty when the project has a single migrations directory and is not stored in
+	// metadata.yaml.
+	Namespace string `yaml:"-"`
+
+	// ParentNamespaces holds the namespace of every parent written as
+	// `namespace:timestamp` in metadata.yaml, keyed by the parent's timestamp.
+	ParentNamespaces map[int64]string `yaml:"-"`
+}
+
+// QualifiedName returns the migration's file name prefixed with its namespace, if any.
+func (m MigrationMetadata) QualifiedName() string {
+	name := fmt.Sprintf("%d_%s", m.Timestamp, m.Name)
+	if m.Namespace == "" {
+		return name
+	}
+	return m.Namespace + ":" + name
+}
+
+// rawMigrationMetadata mirrors MigrationMetadata as stored in metadata.yaml, where a
+// parent is either a timestamp or a `namespace:timestamp` reference.
+type rawMigrationMetadata struct {
+	Name          string `yaml:"name"`
+	Timestamp     int64  `yaml:"timestamp"`
+	Description   string `yaml:"description,omitempty"`
+	Parents       []any  `yaml:"parents,omitempty,flow"`
+	NoTransaction bool   `yaml:"no_transaction,omitempty"`
+	Irreversible  bool   `yaml:"irreversible,omitempty"`
+}
+
+// MarshalYAML writes parents with a namespace as `namespace:timestamp`.
+func (m MigrationMetadata) MarshalYAML() (any, error) {
+	raw := rawMigrationMetadata{
+		Name:          m.Name,
+		Timestamp:     m.Timestamp,
+		Description:   m.Description,
+		NoTransaction: m.NoTransaction,
+		Irreversible:  m.Irreversible,
+	}
+	if m.Parents != nil {
+		raw.Parents = make([]any, 0, len(m.Parents))
+	}
+	for _, parent := range m.Parents {
+		if ns := m.ParentNamespaces[parent]; ns != "" {
+			raw.Parents = append(raw.Parents, fmt.Sprintf("%s:%d", ns, parent))
+			continue
+		}
+		raw.Parents = append(raw.Parents, parent)
+	}
+	return raw, nil
+}
+
+// UnmarshalYAML accepts parents written either as timestamps or as
+// `namespace:timestamp` references.
+func (m *MigrationMetadata) UnmarshalYAML(value *yaml.Node) error {
+	var raw rawMigrationMetadata
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	*m = MigrationMetadata{
+		Name:          raw.Name,
+		Timestamp:     raw.Timestamp,
+		Description:   raw.Description,
+		NoTransaction: raw.NoTransaction,
+		Irreversible:  raw.Irreversible,
+	}
+	if raw.Parents != nil {
+		m.Parents = make([]int64, 0, len(raw.Parents))
+	}
+	for _, ref := range raw.Parents {
+		ns, parent, err := ParseParentRef(fmt.Sprint(ref))
+		if err != nil {
+			return err
+		}
+		m.Parents = append(m.Parents, parent)
+		if ns != "" {
+			if m.ParentNamespaces == nil {
+				m.ParentNamespaces = map[int64]string{}
+			}
+			m.ParentNamespaces[parent] = ns
+		}
+	}
+	return nil
+}
+
+// ParseParentRef parses a parent reference: either a timestamp or `namespace:timestamp`.
+func ParseParentRef(ref string) (namespace string, timestamp int64, err error) {
+	ts := ref
+	if i := strings.LastIndex(ref, ":"); i >= 0 {
+		namespace, ts = ref[:i], ref[i+1:]
+		if namespace == "" {
+			return "", 0, errors.Newf("invalid parent %q: namespace cannot be empty", ref)
+		}
+	}
+	timestamp, err = strconv.ParseInt(strings.TrimSpace(ts), 10, 64)
+	if err != nil {
+		return "", 0, errors.Newf("invalid parent %q: must be a timestamp or namespace:timestamp", ref)
+	}
+	return namespace, timestamp, nil
 }
 
 // MigrationOperationType represents the type of migration operation.
@@ -7,4 +7,8 @@ type MigrationLog struct {
 	Name          string
 	MigrationTime time.Time
 	Duration      string
+
+	// Batch identifies the run that applied the migration; every migration applied by one
+	// `up` run shares it. It is 0 for migrations applied before kat recorded batches.
+	Batch int64
 }
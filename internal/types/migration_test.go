@@ -0,0 +1,85 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrationMetadataYAML(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		expected   MigrationMetadata
+		wantErr    string
+		roundTrips string
+	}{
+		{
+			name:       "timestamp parents",
+			input:      "name: add_email\ntimestamp: 1747578819\nparents: [1747578808]\n",
+			expected:   MigrationMetadata{Name: "add_email", Timestamp: 1747578819, Parents: []int64{1747578808}},
+			roundTrips: "name: add_email\ntimestamp: 1747578819\nparents: [1747578808]\n",
+		},
+		{
+			name:  "namespaced parents",
+			input: "name: create_orders\ntimestamp: 1747578900\nparents: [1747578850, core:1747578808]\n",
+			expected: MigrationMetadata{
+				Name:             "create_orders",
+				Timestamp:        1747578900,
+				Parents:          []int64{1747578850, 1747578808},
+				ParentNamespaces: map[int64]string{1747578808: "core"},
+			},
+			roundTrips: "name: create_orders\ntimestamp: 1747578900\nparents: [1747578850, 'core:1747578808']\n",
+		},
+		{
+			name:    "empty namespace",
+			input:   "name: create_orders\ntimestamp: 1747578900\nparents: [':1747578808']\n",
+			wantErr: "namespace cannot be empty",
+		},
+		{
+			name:    "invalid timestamp",
+			input:   "name: create_orders\ntimestamp: 1747578900\nparents: [core:latest]\n",
+			wantErr: "must be a timestamp or namespace:timestamp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var md MigrationMetadata
+			err := yaml.Unmarshal([]byte(tt.input), &md)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, md)
+
+			out, err := yaml.Marshal(md)
+			require.NoError(t, err)
+			require.Equal(t, tt.roundTrips, string(out))
+		})
+	}
+}
+
+func TestMigrationIDTime(t *testing.T) {
+	created := time.Date(2025, 5, 18, 14, 33, 28, 123456789, time.UTC)
+
+	tests := []struct {
+		name     string
+		id       int64
+		expected time.Time
+	}{
+		{name: "second-based ID", id: 1747578808, expected: time.Date(2025, 5, 18, 14, 33, 28, 0, time.UTC)},
+		{name: "nanosecond ID", id: NewMigrationID(created), expected: created},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, MigrationIDTime(tt.id))
+		})
+	}
+
+	require.Greater(t, NewMigrationID(created), int64(1747578808), "new IDs must sort after second-based ones")
+}
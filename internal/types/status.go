@@ -0,0 +1,37 @@
+package types
+
+import "time"
+
+// MigrationStatus describes whether a single migration definition has been applied.
+type MigrationStatus struct {
+	Definition Definition
+
+	// Log is the tracking table entry for the migration, or nil if it is still pending.
+	Log *MigrationLog
+
+	// Orphaned is true when Log has no matching definition, for example because a newer
+	// release applied it. Only the Timestamp and Name of Definition are set, parsed from
+	// the log entry.
+	Orphaned bool
+}
+
+// Applied reports whether the migration has been recorded in the tracking table.
+func (s MigrationStatus) Applied() bool {
+	return s.Log != nil
+}
+
+// MigrationResult describes the outcome of a single migration executed during a run.
+type MigrationResult struct {
+	Name      string
+	Operation MigrationOperationType
+	Duration  time.Duration
+
+	// RowsAffected is the number of rows the migration's SQL affected, as reported by the driver.
+	RowsAffected int64
+
+	// DryRun is true when the migration was only validated and not executed.
+	DryRun bool
+
+	// Err is set when the migration failed.
+	Err error
+}
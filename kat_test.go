@@ -1,17 +1,28 @@
 package kat
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
 	"time"
 
+	"github.com/cockroachdb/errors"
+	"github.com/keegancsmith/sqlf"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/BolajiOlajide/kat/internal/loggr"
 )
 
 var migrations = fstest.MapFS{
@@ -23,6 +34,15 @@ var migrations = fstest.MapFS{
 	"1651234568/metadata.yaml": {Data: []byte("name: create_posts\ntimestamp: 1651234568\nparents: [1651234567]\n")},
 }
 
+var sqliteMigrations = fstest.MapFS{
+	"1651234567/up.sql":        {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);\n")},
+	"1651234567/down.sql":      {Data: []byte("DROP TABLE users;\n")},
+	"1651234567/metadata.yaml": {Data: []byte("name: create_users\ntimestamp: 1651234567\nparents: []\n")},
+	"1651234568/up.sql":        {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id));\n")},
+	"1651234568/down.sql":      {Data: []byte("DROP TABLE posts;\n")},
+	"1651234568/metadata.yaml": {Data: []byte("name: create_posts\ntimestamp: 1651234568\nparents: [1651234567]\n")},
+}
+
 func TestValidateTableName(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -103,3 +123,338 @@ func TestScanMigrationLog(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, kmo.Up(ctx, 0)) // only applies the new pending one
 }
+
+func TestWithSlog(t *testing.T) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs", WithSlog(logger))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	require.NoError(t, m.Up(ctx, 0))
+
+	var executed []string
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(line, &record), "log records should be valid JSON")
+		if name, ok := record["migration"].(string); ok && record["duration"] != nil {
+			require.Equal(t, "up", record["operation"])
+			require.Equal(t, "sqlite", record["driver"])
+			executed = append(executed, name)
+		}
+	}
+	require.Equal(t, []string{"1651234567_create_users", "1651234568_create_posts"}, executed)
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	ctx := context.Background()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs", WithTracerProvider(tp))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	require.NoError(t, m.Up(ctx, 0))
+	require.NoError(t, m.Down(ctx, 1))
+
+	var runs, migrations []string
+	for _, span := range exporter.GetSpans() {
+		switch span.Name {
+		case "kat.up", "kat.down":
+			runs = append(runs, span.Name)
+		case "kat.migration":
+			for _, attr := range span.Attributes {
+				if attr.Key == "kat.migration.name" {
+					migrations = append(migrations, attr.Value.AsString())
+				}
+			}
+		}
+	}
+	require.Equal(t, []string{"kat.up", "kat.down"}, runs)
+	require.Equal(t, []string{"1651234567_create_users", "1651234568_create_posts", "1651234568_create_posts"}, migrations)
+
+	_, err = New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs", WithTracerProvider(nil))
+	require.Error(t, err, "a nil tracer provider should be rejected")
+}
+
+// recordingMetrics records the callbacks kat makes so tests can assert on them.
+type recordingMetrics struct {
+	pending   []int
+	started   []string
+	completed []string
+	failed    []string
+	lockWaits int
+}
+
+func (r *recordingMetrics) MigrationsPending(count int) { r.pending = append(r.pending, count) }
+func (r *recordingMetrics) MigrationStarted(name, _ string) {
+	r.started = append(r.started, name)
+}
+func (r *recordingMetrics) MigrationCompleted(name, _ string, _ time.Duration) {
+	r.completed = append(r.completed, name)
+}
+func (r *recordingMetrics) MigrationFailed(name, _ string, _ time.Duration, _ error) {
+	r.failed = append(r.failed, name)
+}
+func (r *recordingMetrics) LockWaited(string, time.Duration) { r.lockWaits++ }
+
+func TestWithMetrics(t *testing.T) {
+	ctx := context.Background()
+	rec := &recordingMetrics{}
+
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs", WithMetrics(rec))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	require.NoError(t, m.Up(ctx, 1))
+	require.NoError(t, m.Up(ctx, 0))
+
+	require.Equal(t, []int{2, 1}, rec.pending)
+	require.Equal(t, []string{"1651234567_create_users", "1651234568_create_posts"}, rec.started)
+	require.Equal(t, rec.started, rec.completed)
+	require.Empty(t, rec.failed)
+	require.Equal(t, 2, rec.lockWaits)
+}
+
+func TestWithHooks(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs", WithHooks(Hooks{
+		BeforeRun: func(_ context.Context, e RunEvent) error {
+			calls = append(calls, "before_run "+e.Operation.String())
+			return nil
+		},
+		BeforeMigration: func(_ context.Context, e MigrationEvent) error {
+			if e.Operation.IsUpMigration() {
+				return nil
+			}
+			return errors.New("rollbacks are disabled")
+		},
+		AfterMigration: func(ctx context.Context, e MigrationEvent) error {
+			calls = append(calls, "after "+e.Name)
+			var n int
+			return e.Tx.QueryRow(ctx, sqlf.Sprintf("SELECT COUNT(*) FROM migration_logs")).Scan(&n)
+		},
+		OnError: func(_ context.Context, e MigrationEvent) {
+			calls = append(calls, "error "+e.Name)
+		},
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	require.NoError(t, m.Up(ctx, 0))
+	require.ErrorContains(t, m.Down(ctx, 1), "rollbacks are disabled")
+
+	require.Equal(t, []string{
+		"before_run up",
+		"after 1651234567_create_users",
+		"after 1651234568_create_posts",
+		"before_run down",
+		"error 1651234568_create_posts",
+	}, calls)
+}
+
+func TestWithSource(t *testing.T) {
+	generated := MemorySource(MigrationSpec{
+		MigrationMetadata: MigrationMetadata{Name: "create_comments", Timestamp: 1651234569, Parents: []int64{1651234568}},
+		Up:                "CREATE TABLE comments (id INTEGER PRIMARY KEY);",
+		Down:              "DROP TABLE comments;",
+	})
+
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		options  []MigrationOption
+		expected []string
+		wantErr  string
+	}{
+		{
+			name:    "filesystem and source",
+			fsys:    sqliteMigrations,
+			options: []MigrationOption{WithSource(generated)},
+			expected: []string{
+				"1651234567_create_users",
+				"1651234568_create_posts",
+				"1651234569_create_comments",
+			},
+		},
+		{
+			name:     "sources only",
+			options:  []MigrationOption{WithSource(DirSource(sqliteMigrations)), WithSource(generated)},
+			expected: []string{"1651234567_create_users", "1651234568_create_posts", "1651234569_create_comments"},
+		},
+		{
+			name:    "duplicate migrations",
+			fsys:    sqliteMigrations,
+			options: []MigrationOption{WithSource(DirSource(sqliteMigrations))},
+			wantErr: "duplicate migration timestamp 1651234567",
+		},
+		{
+			name:    "no migrations",
+			wantErr: "migrations must be provided",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var fsys fs.FS
+			if tt.fsys != nil {
+				fsys = tt.fsys
+			}
+
+			m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), fsys, "migration_logs", tt.options...)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			t.Cleanup(func() { m.Close() })
+
+			require.NoError(t, m.Up(ctx, 0))
+			statuses, err := m.Status(ctx)
+			require.NoError(t, err)
+
+			var applied []string
+			for _, s := range statuses {
+				if s.Applied() {
+					applied = append(applied, s.Definition.FileName())
+				}
+			}
+			require.Equal(t, tt.expected, applied)
+		})
+	}
+}
+
+func TestWithOrphanPolicy(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "kat.db")
+
+	newer, err := New(SQLiteDriver, dbPath, sqliteMigrations, "migration_logs")
+	require.NoError(t, err)
+	require.NoError(t, newer.Up(ctx, 0))
+	require.NoError(t, newer.Close())
+
+	older := fstest.MapFS{}
+	for name, file := range sqliteMigrations {
+		if filepath.Dir(name) == "1651234567" {
+			older[name] = file
+		}
+	}
+
+	_, err = New(SQLiteDriver, dbPath, older, "migration_logs", WithOrphanPolicy("fail"))
+	require.ErrorContains(t, err, `invalid orphan policy "fail"`)
+
+	m, err := New(SQLiteDriver, dbPath, older, "migration_logs", WithOrphanPolicy(OrphanPolicyError))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	var orphanErr *OrphanedMigrationsError
+	require.True(t, errors.As(m.Up(ctx, 0), &orphanErr))
+	require.Equal(t, []string{"1651234568_create_posts"}, orphanErr.Names)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.True(t, statuses[1].Orphaned)
+	require.Equal(t, "1651234568_create_posts", statuses[1].Definition.FileName())
+}
+
+func TestDownBatch(t *testing.T) {
+	ctx := context.Background()
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs")
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	applied := func() []string {
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		var names []string
+		for _, s := range statuses {
+			if s.Applied() {
+				names = append(names, s.Definition.FileName())
+			}
+		}
+		return names
+	}
+
+	require.NoError(t, m.Up(ctx, 1))
+	require.NoError(t, m.Up(ctx, 0))
+
+	require.NoError(t, m.DownBatch(ctx))
+	require.Equal(t, []string{"1651234567_create_users"}, applied())
+
+	require.NoError(t, m.DownBatch(ctx))
+	require.Empty(t, applied())
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	// create_comments only depends on create_users, so it can ship before create_posts.
+	hotfix := MemorySource(MigrationSpec{
+		MigrationMetadata: MigrationMetadata{Name: "create_comments", Timestamp: 1651234569, Parents: []int64{1651234567}},
+		Up:                "CREATE TABLE comments (id INTEGER PRIMARY KEY);",
+		Down:              "DROP TABLE comments;",
+	})
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs", WithSource(hotfix))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	require.ErrorContains(t, m.Apply(ctx, 1651234569), "parent migration(s) 1651234567_create_users are not applied yet")
+
+	require.NoError(t, m.Up(ctx, 1))
+	require.NoError(t, m.Apply(ctx, 1651234569))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	applied := map[string]bool{}
+	for _, s := range statuses {
+		applied[s.Definition.FileName()] = s.Applied()
+	}
+	require.Equal(t, map[string]bool{
+		"1651234567_create_users":    true,
+		"1651234568_create_posts":    false,
+		"1651234569_create_comments": true,
+	}, applied)
+}
+
+func TestWithOutOfOrderPolicy(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "kat.db")
+
+	m, err := New(SQLiteDriver, dbPath, sqliteMigrations, "migration_logs")
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx, 0))
+	require.NoError(t, m.Close())
+
+	_, err = New(SQLiteDriver, dbPath, sqliteMigrations, "migration_logs", WithOutOfOrderPolicy("deny"))
+	require.ErrorContains(t, err, `invalid out-of-order policy "deny"`)
+
+	merged := MemorySource(MigrationSpec{
+		MigrationMetadata: MigrationMetadata{Name: "create_tags", Timestamp: 1651234500},
+		Up:                "CREATE TABLE tags (id INTEGER PRIMARY KEY);",
+		Down:              "DROP TABLE tags;",
+	})
+	m, err = New(SQLiteDriver, dbPath, sqliteMigrations, "migration_logs", WithSource(merged), WithOutOfOrderPolicy(OutOfOrderError))
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	var outOfOrderErr *OutOfOrderMigrationsError
+	require.True(t, errors.As(m.Up(ctx, 0), &outOfOrderErr))
+	require.Equal(t, []string{"1651234500_create_tags"}, outOfOrderErr.Names)
+	require.Equal(t, "1651234568_create_posts", outOfOrderErr.Latest)
+}
+
+func TestDefaultLoggerIsQuiet(t *testing.T) {
+	m, err := New(SQLiteDriver, filepath.Join(t.TempDir(), "kat.db"), sqliteMigrations, "migration_logs")
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+
+	require.IsType(t, loggr.NewNoop(), m.logger, "library users should get a logger that writes nothing")
+}
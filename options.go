@@ -1,9 +1,13 @@
 package kat
 
 import (
+	"log/slog"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/BolajiOlajide/kat/internal/loggr"
 )
 
 // MigrationOption is a function that configures migration settings.
@@ -13,6 +17,7 @@ type MigrationOption func(*migrationConfig) error
 
 // WithLogger configures the migration to use a custom logger implementation.
 // The logger must implement the Logger interface with Debug, Info, Warn, and Error methods.
+// Structured fields are appended to each message as "key=value" pairs.
 //
 // Example:
 //
@@ -20,6 +25,42 @@ type MigrationOption func(*migrationConfig) error
 //		kat.WithLogger(customLogger),
 //	)
 func WithLogger(logger Logger) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		cfg.logger = loggr.FromLogger(logger)
+		return nil
+	}
+}
+
+// WithStructuredLogger configures the migration to use a logger that receives
+// structured key/value fields with every message.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithStructuredLogger(customLogger),
+//	)
+func WithStructuredLogger(logger StructuredLogger) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if logger == nil {
+			return errors.New("logger cannot be nil")
+		}
+		cfg.logger = logger
+		return nil
+	}
+}
+
+// WithSlog configures the migration to log through a *slog.Logger. Fields such as
+// the migration name, operation, duration and driver are passed as slog attributes.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithSlog(slog.New(slog.NewJSONHandler(os.Stderr, nil))),
+//	)
+func WithSlog(logger *slog.Logger) MigrationOption {
 	return func(cfg *migrationConfig) error {
 		if logger == nil {
 			return errors.New("logger cannot be nil")
@@ -86,3 +127,153 @@ func WithPoolLimits(maxOpen, maxIdle int, connMaxLifetime time.Duration) Migrati
 		return nil
 	}
 }
+
+// WithForce allows Down to roll back migrations marked as irreversible.
+// Without this option, Down stops before an irreversible migration and returns
+// an *IrreversibleMigrationError.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithForce(),
+//	)
+func WithForce() MigrationOption {
+	return func(cfg *migrationConfig) error {
+		cfg.force = true
+		return nil
+	}
+}
+
+// WithOrphanPolicy sets what Up and Down do when the tracking table records migrations
+// that none of the loaded migrations match, which usually means a newer release of the
+// application already migrated the database. The default, OrphanPolicyWarn, logs the
+// orphaned migrations; OrphanPolicyError makes the run fail with an
+// *OrphanedMigrationsError before anything is executed. Status always reports them.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithOrphanPolicy(kat.OrphanPolicyError),
+//	)
+func WithOrphanPolicy(policy OrphanPolicy) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if policy == "" || !policy.Valid() {
+			return errors.Newf("invalid orphan policy %q: must be one of ignore, warn or error", policy)
+		}
+		cfg.orphans = policy
+		return nil
+	}
+}
+
+// WithOutOfOrderPolicy sets what Up and Apply do with out-of-order migrations: pending
+// migrations that come before the last applied one in dependency order, typically after
+// a branch with older migrations is merged. The default, OutOfOrderAllow, applies them
+// silently; OutOfOrderWarn logs them before applying them, and OutOfOrderError makes the
+// run fail with an *OutOfOrderMigrationsError before anything is executed.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithOutOfOrderPolicy(kat.OutOfOrderError),
+//	)
+func WithOutOfOrderPolicy(policy OutOfOrderPolicy) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if policy == "" || !policy.Valid() {
+			return errors.Newf("invalid out-of-order policy %q: must be one of allow, warn or error", policy)
+		}
+		cfg.outOfOrder = policy
+		return nil
+	}
+}
+
+// WithSource adds the migrations in src to the ones read from the filesystem passed to
+// New or NewWithDB. It can be given several times; the filesystem may be nil when every
+// migration comes from a source. Creating the Migration fails if two migrations share a
+// timestamp.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, migrationsFS, "migrations",
+//		kat.WithSource(kat.DirSource(shared.Migrations)),
+//		kat.WithSource(kat.MemorySource(generated.Specs()...)),
+//	)
+func WithSource(src Source) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if src == nil {
+			return errors.New("source cannot be nil")
+		}
+		cfg.sources = append(cfg.sources, src)
+		return nil
+	}
+}
+
+// WithDryRun makes Up and Down report the migrations they would run without executing
+// them or updating the tracking table. Go migrations are not called during dry runs.
+func WithDryRun() MigrationOption {
+	return func(cfg *migrationConfig) error {
+		cfg.dryRun = true
+		return nil
+	}
+}
+
+// WithTracerProvider enables OpenTelemetry tracing. Every Up and Down call creates a
+// span for the run, a child span for each migration and child spans for the tracking
+// table operations. Migration spans carry the migration name, timestamp, operation,
+// no_transaction flag, rows affected and error status.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithTracerProvider(otel.GetTracerProvider()),
+//	)
+func WithTracerProvider(tp trace.TracerProvider) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if tp == nil {
+			return errors.New("tracer provider cannot be nil")
+		}
+		cfg.tracerProvider = tp
+		return nil
+	}
+}
+
+// WithMetrics reports migration durations, failures, transaction wait time and the
+// pending count to m. The prometheus subpackage provides an implementation.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithMetrics(katprometheus.New(prometheus.DefaultRegisterer)),
+//	)
+func WithMetrics(m Metrics) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		if m == nil {
+			return errors.New("metrics cannot be nil")
+		}
+		cfg.metrics = m
+		return nil
+	}
+}
+
+// WithHooks registers callbacks invoked around each Up and Down run and around every
+// migration. A BeforeRun or BeforeMigration error aborts the run. For transactional
+// migrations the event's Tx runs extra SQL inside the migration's transaction, so it is
+// committed or rolled back together with the migration.
+//
+// Example:
+//
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithHooks(kat.Hooks{
+//			AfterMigration: func(ctx context.Context, e kat.MigrationEvent) error {
+//				if e.Tx == nil {
+//					return nil
+//				}
+//				return e.Tx.Exec(ctx, sqlf.Sprintf("INSERT INTO audit (migration) VALUES (%s)", e.Name))
+//			},
+//		}),
+//	)
+func WithHooks(h Hooks) MigrationOption {
+	return func(cfg *migrationConfig) error {
+		cfg.hooks = h
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+// Package prometheus provides a kat.Metrics implementation backed by Prometheus
+// collectors.
+//
+// Register the collectors with your registry and pass the value to kat.WithMetrics:
+//
+//	metrics := prometheus.New(prom.DefaultRegisterer)
+//	m, err := kat.New(kat.PostgresDriver, connStr, fsys, "migrations",
+//		kat.WithMetrics(metrics),
+//	)
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/BolajiOlajide/kat/internal/metrics"
+)
+
+const namespace = "kat"
+
+// Metrics records kat migration runs in Prometheus collectors. It implements kat.Metrics.
+type Metrics struct {
+	pending      prom.Gauge
+	started      *prom.CounterVec
+	completed    *prom.CounterVec
+	failed       *prom.CounterVec
+	duration     *prom.HistogramVec
+	lastDuration *prom.GaugeVec
+	lockWait     *prom.HistogramVec
+}
+
+var _ metrics.Metrics = (*Metrics)(nil)
+
+// New creates the kat collectors and registers them with reg. It panics if any of them
+// is already registered, like prometheus.MustRegister.
+func New(reg prom.Registerer) *Metrics {
+	m := &Metrics{
+		pending: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "migrations_pending",
+			Help:      "Number of migrations that had not been applied when the last run started.",
+		}),
+		started: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "migrations_started_total",
+			Help:      "Number of migrations started.",
+		}, []string{"operation"}),
+		completed: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "migrations_completed_total",
+			Help:      "Number of migrations that completed successfully.",
+		}, []string{"operation"}),
+		failed: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "migrations_failed_total",
+			Help:      "Number of migrations that failed.",
+		}, []string{"operation"}),
+		duration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "migration_duration_seconds",
+			Help:      "Time spent executing migrations.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 5, 15, 60, 300, 900},
+		}, []string{"operation", "status"}),
+		lastDuration: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "migration_last_duration_seconds",
+			Help:      "Duration of the most recent execution of each migration.",
+		}, []string{"migration", "operation", "status"}),
+		lockWait: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "lock_wait_seconds",
+			Help:      "Time migrations waited for their transaction to start.",
+			Buckets:   prom.DefBuckets,
+		}, []string{"operation"}),
+	}
+
+	reg.MustRegister(m.pending, m.started, m.completed, m.failed, m.duration, m.lastDuration, m.lockWait)
+	return m
+}
+
+// MigrationsPending implements kat.Metrics.
+func (m *Metrics) MigrationsPending(count int) {
+	m.pending.Set(float64(count))
+}
+
+// MigrationStarted implements kat.Metrics.
+func (m *Metrics) MigrationStarted(_, operation string) {
+	m.started.WithLabelValues(operation).Inc()
+}
+
+// MigrationCompleted implements kat.Metrics.
+func (m *Metrics) MigrationCompleted(name, operation string, duration time.Duration) {
+	m.completed.WithLabelValues(operation).Inc()
+	m.duration.WithLabelValues(operation, "success").Observe(duration.Seconds())
+	m.lastDuration.WithLabelValues(name, operation, "success").Set(duration.Seconds())
+}
+
+// MigrationFailed implements kat.Metrics.
+func (m *Metrics) MigrationFailed(name, operation string, duration time.Duration, _ error) {
+	m.failed.WithLabelValues(operation).Inc()
+	m.duration.WithLabelValues(operation, "failure").Observe(duration.Seconds())
+	m.lastDuration.WithLabelValues(name, operation, "failure").Set(duration.Seconds())
+}
+
+// LockWaited implements kat.Metrics.
+func (m *Metrics) LockWaited(operation string, wait time.Duration) {
+	m.lockWait.WithLabelValues(operation).Observe(wait.Seconds())
+}
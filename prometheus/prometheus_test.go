@@ -0,0 +1,56 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m := New(reg)
+
+	m.MigrationsPending(3)
+	m.MigrationStarted("1651234567_create_users", "up")
+	m.LockWaited("up", 5*time.Millisecond)
+	m.MigrationCompleted("1651234567_create_users", "up", 2*time.Second)
+	m.MigrationStarted("1651234568_create_posts", "up")
+	m.MigrationFailed("1651234568_create_posts", "up", time.Second, errors.New("boom"))
+
+	require.Equal(t, 3.0, testutil.ToFloat64(m.pending))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.started.WithLabelValues("up")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.completed.WithLabelValues("up")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.failed.WithLabelValues("up")))
+	require.Equal(t, 2.0, testutil.ToFloat64(m.lastDuration.WithLabelValues("1651234567_create_users", "up", "success")))
+
+	expected := `
+# HELP kat_lock_wait_seconds Time migrations waited for their transaction to start.
+# TYPE kat_lock_wait_seconds histogram
+kat_lock_wait_seconds_bucket{operation="up",le="0.005"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="0.01"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="0.025"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="0.05"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="0.1"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="0.25"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="0.5"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="1"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="2.5"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="5"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="10"} 1
+kat_lock_wait_seconds_bucket{operation="up",le="+Inf"} 1
+kat_lock_wait_seconds_sum{operation="up"} 0.005
+kat_lock_wait_seconds_count{operation="up"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "kat_lock_wait_seconds"))
+}
+
+func TestNew_RegistersOnce(t *testing.T) {
+	reg := prom.NewRegistry()
+	New(reg)
+	require.Panics(t, func() { New(reg) }, "registering the collectors twice should panic")
+}
@@ -0,0 +1,10 @@
+// Package schemas embeds the JSON schema of kat's configuration file so the CLI can
+// validate configuration files against the same schema editors use.
+package schemas
+
+import _ "embed"
+
+// Config is the JSON schema of kat.conf.yaml.
+//
+//go:embed kat.conf.schema.json
+var Config []byte
@@ -1,9 +1,15 @@
 package kat
 
 import (
+	"io/fs"
+
 	"github.com/BolajiOlajide/kat/internal/database"
 	dbdriver "github.com/BolajiOlajide/kat/internal/database/driver"
 	"github.com/BolajiOlajide/kat/internal/loggr"
+	"github.com/BolajiOlajide/kat/internal/metrics"
+	"github.com/BolajiOlajide/kat/internal/migration"
+	"github.com/BolajiOlajide/kat/internal/runner"
+	"github.com/BolajiOlajide/kat/internal/types"
 )
 
 // Driver represents a supported database driver type.
@@ -23,10 +29,78 @@ func ParseDriver(name string) (Driver, error) {
 	return dbdriver.ParseDBDriver(name)
 }
 
-// Logger is the interface used for logging within kat.
+// Logger is the message-only logging interface used by WithLogger.
 // Implement this interface to provide custom logging behavior.
 type Logger = loggr.Logger
 
+// StructuredLogger is a leveled logger that receives key/value fields alongside each
+// message, such as the migration name, operation, duration and driver. Its method set
+// matches *slog.Logger.
+type StructuredLogger = loggr.StructuredLogger
+
+// Metrics receives callbacks while migrations run: the pending count at the start of a
+// run, every migration started, completed or failed with its duration, and how long each
+// migration waited for its transaction. See the prometheus subpackage for an implementation.
+type Metrics = metrics.Metrics
+
+// Hooks are callbacks invoked around a run and each migration. See WithHooks.
+type Hooks = runner.Hooks
+
+// RunEvent describes a whole Up or Down run and is passed to BeforeRun and AfterRun.
+type RunEvent = runner.RunEvent
+
+// MigrationEvent describes a single migration and is passed to BeforeMigration,
+// AfterMigration and OnError.
+type MigrationEvent = runner.MigrationEvent
+
+// HookTx runs extra SQL inside a migration's transaction.
+type HookTx = runner.HookTx
+
+// MigrationMetadata is a migration's name, timestamp, parents and flags as read from
+// its metadata.yaml.
+type MigrationMetadata = types.MigrationMetadata
+
+// MigrationResult reports the outcome of a single migration in a run.
+type MigrationResult = types.MigrationResult
+
+// MigrationStatus describes a migration and whether it has been applied. See Status.
+type MigrationStatus = types.MigrationStatus
+
+// MigrationLog is a migration's entry in the tracking table.
+type MigrationLog = types.MigrationLog
+
+// Source provides migrations to New and NewWithDB through WithSource. Implement it to
+// load migrations from anywhere; DirSource, MemorySource and MergeSources cover the
+// common cases.
+type Source = migration.Source
+
+// MigrationSpec is a migration held in memory, for use with MemorySource.
+type MigrationSpec = migration.MigrationSpec
+
+// DirSource returns a Source for Kat's directory layout: one directory per migration
+// holding up.sql, down.sql and metadata.yaml.
+func DirSource(f fs.FS) Source {
+	return migration.DirSource(f)
+}
+
+// MemorySource returns a Source holding specs, which is handy in tests and for
+// migrations generated at build time.
+func MemorySource(specs ...MigrationSpec) Source {
+	return migration.MemorySource(specs...)
+}
+
+// NamespacedSource tags every migration of src with namespace. Parents in other sources
+// can refer to its migrations as `namespace:timestamp`.
+func NamespacedSource(namespace string, src Source) Source {
+	return migration.NamespacedSource(namespace, src)
+}
+
+// MergeSources combines sources into one. Listing its migrations fails if two of them
+// share a timestamp.
+func MergeSources(sources ...Source) Source {
+	return migration.MergeSources(sources...)
+}
+
 // DBConfig holds database connection configuration options.
 type DBConfig = database.DBConfig
 
@@ -34,3 +108,42 @@ type DBConfig = database.DBConfig
 func DefaultDBConfig(drv Driver) DBConfig {
 	return database.DefaultDBConfig(drv)
 }
+
+// IrreversibleMigrationError is returned by Down when it reaches a migration marked
+// with `irreversible: true` in its metadata. Use errors.As to inspect the migration name.
+type IrreversibleMigrationError = types.IrreversibleMigrationError
+
+// OrphanedMigrationsError is returned by Up and Down when the orphan policy is
+// OrphanPolicyError and the tracking table records migrations that none of the loaded
+// migrations match. Use errors.As to inspect their names.
+type OrphanedMigrationsError = types.OrphanedMigrationsError
+
+// OrphanPolicy decides what Up and Down do with orphaned migrations. See WithOrphanPolicy.
+type OrphanPolicy = types.OrphanPolicy
+
+const (
+	// OrphanPolicyIgnore runs migrations without looking for orphaned ones.
+	OrphanPolicyIgnore = types.OrphanPolicyIgnore
+	// OrphanPolicyWarn logs a warning naming the orphaned migrations. It is the default.
+	OrphanPolicyWarn = types.OrphanPolicyWarn
+	// OrphanPolicyError fails the run with an *OrphanedMigrationsError.
+	OrphanPolicyError = types.OrphanPolicyError
+)
+
+// OutOfOrderMigrationsError is returned by Up and Apply when the out-of-order policy is
+// OutOfOrderError and pending migrations come before an applied one. Use errors.As to
+// inspect their names.
+type OutOfOrderMigrationsError = types.OutOfOrderMigrationsError
+
+// OutOfOrderPolicy decides what Up and Apply do with out-of-order migrations. See
+// WithOutOfOrderPolicy.
+type OutOfOrderPolicy = types.OutOfOrderPolicy
+
+const (
+	// OutOfOrderAllow applies out-of-order migrations like any other. It is the default.
+	OutOfOrderAllow = types.OutOfOrderAllow
+	// OutOfOrderWarn logs a warning naming the out-of-order migrations and applies them.
+	OutOfOrderWarn = types.OutOfOrderWarn
+	// OutOfOrderError fails the run with an *OutOfOrderMigrationsError.
+	OutOfOrderError = types.OutOfOrderError
+)